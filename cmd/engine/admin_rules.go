@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// Limite de tamanho de um pack enviado pela API de gestão
+const maxRulePackSize = 1 << 20
+
+// registerRuleAdminRoutes regista a gestão de regras. Todas as rotas, incluindo as de
+// consulta, exigem a permissão rules.manage; Publish e Activate voltam a verificá-la no serviço.
+func registerRuleAdminRoutes(e *echo.Echo, admin interfaces.RulePackAdmin, authz interfaces.Authorizer) {
+	manage := requirePermission(authz, domain.PermissionManageRules)
+	e.GET("/admin/rules", handleListRulePacks(admin), manage)
+	e.POST("/admin/rules", handlePublishRulePack(admin), manage)
+	e.GET("/admin/rules/diff", handleDiffRulePacks(admin), manage)
+	e.GET("/admin/rules/audit", handleRulesAudit(admin), manage)
+	e.GET("/admin/rules/:version", handleGetRulePack(admin), manage)
+	e.GET("/admin/tenants/:tenant/rules", handleGetActiveRules(admin), manage)
+	e.PUT("/admin/tenants/:tenant/rules", handleActivateRules(admin), manage)
+}
+
+func handleListRulePacks(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		infos, err := admin.List(c.Request().Context())
+		if err != nil {
+			return ruleAdminError(c, err)
+		}
+		if infos == nil {
+			infos = []domain.RulePackInfo{}
+		}
+		return c.JSON(http.StatusOK, infos)
+	}
+}
+
+func handleGetRulePack(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		def, err := admin.Get(c.Request().Context(), c.Param("version"))
+		if err != nil {
+			return ruleAdminError(c, err)
+		}
+		c.Response().Header().Set("ETag", fmt.Sprintf("%q", def.ContentHash))
+		return c.JSON(http.StatusOK, def)
+	}
+}
+
+// handlePublishRulePack recebe o pack no corpo (JSON, ou YAML com Content-Type
+// application/yaml) e, opcionalmente, o ficheiro .sig em base64 em X-Rules-Signature.
+func handlePublishRulePack(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		content, err := io.ReadAll(io.LimitReader(c.Request().Body, maxRulePackSize+1))
+		if err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", err.Error())
+		}
+		if len(content) > maxRulePackSize {
+			return errorRFC7807(c, http.StatusRequestEntityTooLarge, "Pack Demasiado Grande", fmt.Sprintf("limite de %d bytes", maxRulePackSize))
+		}
+
+		upload := domain.RulePackUpload{Format: "json", Content: content}
+		if mediaType := strings.ToLower(c.Request().Header.Get(echo.HeaderContentType)); strings.Contains(mediaType, "yaml") {
+			upload.Format = "yaml"
+		}
+		if encoded := c.Request().Header.Get("X-Rules-Signature"); encoded != "" {
+			if upload.Signature, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return errorRFC7807(c, http.StatusBadRequest, "Assinatura Inválida", "X-Rules-Signature deve estar em base64")
+			}
+		}
+
+		def, err := admin.Publish(c.Request().Context(), upload)
+		if err != nil {
+			return ruleAdminError(c, err)
+		}
+		c.Echo().Logger.Infof("rulepack %s publicado por %s (%s)", def.Version, usecase.CallerFrom(c.Request().Context()).UserID, def.ContentHash)
+
+		c.Response().Header().Set(echo.HeaderLocation, "/admin/rules/"+def.Version)
+		c.Response().Header().Set("ETag", fmt.Sprintf("%q", def.ContentHash))
+		return c.JSON(http.StatusCreated, def)
+	}
+}
+
+func handleDiffRulePacks(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		from, to := c.QueryParam("from"), c.QueryParam("to")
+		if from == "" || to == "" {
+			return errorRFC7807(c, http.StatusBadRequest, "Parâmetros em Falta", "indique as versões em from e to")
+		}
+		diff, err := admin.Diff(c.Request().Context(), from, to)
+		if err != nil {
+			return ruleAdminError(c, err)
+		}
+		return c.JSON(http.StatusOK, diff)
+	}
+}
+
+func handleRulesAudit(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		entries, err := admin.History(c.Request().Context())
+		if err != nil {
+			return ruleAdminError(c, err)
+		}
+		if entries == nil {
+			entries = []domain.AuditEntry{}
+		}
+		return c.JSON(http.StatusOK, entries)
+	}
+}
+
+func handleGetActiveRules(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tenant := c.Param("tenant")
+		version, ok := admin.ActiveVersion(c.Request().Context(), tenant)
+		if !ok {
+			return errorRFC7807(c, http.StatusNotFound, "Sem Versão Ativa", fmt.Sprintf("o tenant %s não tem versão de regras ativa", tenant))
+		}
+		return c.JSON(http.StatusOK, map[string]string{"tenant": tenant, "version": version})
+	}
+}
+
+func handleActivateRules(admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req struct {
+			Version string `json:"version"`
+		}
+		if err := c.Bind(&req); err != nil || req.Version == "" {
+			return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", `esperado {"version": "..."}`)
+		}
+
+		tenant := c.Param("tenant")
+		if err := admin.Activate(c.Request().Context(), tenant, req.Version); err != nil {
+			return ruleAdminError(c, err)
+		}
+		c.Echo().Logger.Infof("rulepack %s ativo para o tenant %s (por %s)", req.Version, tenant, usecase.CallerFrom(c.Request().Context()).UserID)
+		return c.JSON(http.StatusOK, map[string]string{"tenant": tenant, "version": req.Version})
+	}
+}
+
+// activeRulesVersion aplica ao pedido a versão das regras ativa para o tenant. O cliente só
+// escolhe a versão quando o tenant não tem nenhuma ativa; uma versão diferente da ativa é
+// recusada com 409, para que um POS desatualizado não calcule com regras antigas. O bool
+// indica que a resposta de erro já foi escrita.
+func activeRulesVersion(c echo.Context, admin interfaces.RulePackAdmin, order *domain.Order) (error, bool) {
+	tenant := c.Request().Header.Get("X-Tenant-ID")
+	version, ok := admin.ActiveVersion(c.Request().Context(), tenant)
+	if !ok {
+		return nil, false
+	}
+	if order.RulesVersion != "" && strings.TrimPrefix(order.RulesVersion, "v") != strings.TrimPrefix(version, "v") {
+		return errorRFC7807Ext(c, http.StatusConflict, "Versão de Regras Não Ativa",
+			fmt.Sprintf("o tenant %s usa as regras %s, o pedido indica %s", tenant, version, order.RulesVersion),
+			map[string]interface{}{"activeVersion": version}), true
+	}
+	order.RulesVersion = version
+	return nil, false
+}
+
+func ruleAdminError(c echo.Context, err error) error {
+	if res, ok := authError(c, err); ok {
+		return res
+	}
+	var validationErr *domain.RulePackValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return errorRFC7807Ext(c, http.StatusUnprocessableEntity, "RulePack Inválido", err.Error(),
+			map[string]interface{}{"diagnostics": validationErr.Diagnostics})
+	case errors.Is(err, domain.ErrInvalidRulePack):
+		return errorRFC7807(c, http.StatusUnprocessableEntity, "RulePack Inválido", err.Error())
+	case errors.Is(err, domain.ErrRulePackNotFound):
+		return errorRFC7807(c, http.StatusNotFound, "RulePack Não Encontrado", err.Error())
+	case errors.Is(err, domain.ErrRulePackExists):
+		return errorRFC7807(c, http.StatusConflict, "Versão Já Publicada", err.Error()+"; as versões publicadas são imutáveis, publique uma nova versão")
+	case errors.Is(err, domain.ErrRulePacksReadOnly):
+		return errorRFC7807(c, http.StatusForbidden, "Repositório Só de Leitura", err.Error())
+	default:
+		return errorRFC7807(c, http.StatusInternalServerError, "Erro na Gestão de Regras", err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// authenticate identifica o utilizador pelo token em Authorization: Bearer e põe-no no
+// contexto do pedido. Sem token o pedido continua anónimo e só as operações que exigem
+// permissão o recusam (401); um token desconhecido é recusado logo aqui. Sem auth
+// (nenhum ficheiro de utilizadores) todos os pedidos são anónimos.
+func authenticate(auth interfaces.Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if header == "" {
+				return next(c)
+			}
+			scheme, token, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				return unauthorized(c, "use Authorization: Bearer <token>")
+			}
+			if auth == nil {
+				return unauthorized(c, "o servidor não tem utilizadores configurados")
+			}
+			caller, err := auth.Authenticate(c.Request().Context(), strings.TrimSpace(token))
+			switch {
+			case errors.Is(err, domain.ErrUnauthenticated):
+				return unauthorized(c, err.Error())
+			case err != nil:
+				return errorRFC7807(c, http.StatusInternalServerError, "Erro de Autenticação", err.Error())
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(usecase.WithCaller(req.Context(), caller)))
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context, detail string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return errorRFC7807(c, http.StatusUnauthorized, "Autenticação Necessária", detail)
+}
+
+// requirePermission só deixa passar os pedidos de utilizadores com a permissão action.
+func requirePermission(authz interfaces.Authorizer, action domain.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := usecase.Authorize(c.Request().Context(), authz, action); err != nil {
+				if res, ok := authError(c, err); ok {
+					return res
+				}
+				return errorRFC7807(c, http.StatusInternalServerError, "Erro de Autorização", err.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// authError responde às recusas da política de autorização; ok é falso para os outros erros.
+func authError(c echo.Context, err error) (response error, ok bool) {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return unauthorized(c, err.Error()), true
+	case errors.Is(err, domain.ErrForbidden):
+		return errorRFC7807(c, http.StatusForbidden, "Operação Não Permitida", err.Error()), true
+	}
+	return nil, false
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config reúne a configuração do servidor. Cada valor é resolvido por ordem de
+// prioridade crescente: omissão, ficheiro de configuração (-config ou ENGINE_CONFIG),
+// variáveis de ambiente e flags.
+type Config struct {
+	// RulesDir é o diretório dos RulePacks; vazio usa os packs embutidos no binário
+	RulesDir          string        `json:"rules_dir"`
+	RulesPollInterval time.Duration `json:"-"`
+	DataDir           string        `json:"data_dir"`
+	ListenAddr        string        `json:"listen_addr"`
+	CORSOrigins       []string      `json:"cors_origins"`
+	// TrustedKeys lista ficheiros PEM com as chaves públicas Ed25519 aceites nos packs
+	TrustedKeys []string `json:"trusted_keys"`
+	// Production recusa packs sem assinatura válida de uma chave confiável
+	Production bool `json:"production"`
+	// DeltaTolerance é a tolerância por omissão abaixo da qual as diferenças numéricas
+	// do servidor são cosméticas; cada pedido pode indicar outra com ?tolerance=
+	DeltaTolerance float64 `json:"delta_tolerance"`
+	// PatchPolicy é o ficheiro com os caminhos alteráveis em /orders/patch; vazio usa a
+	// política por omissão
+	PatchPolicy string `json:"patch_policy"`
+	// Users é o ficheiro com os utilizadores, os SHA-256 dos seus tokens e as permissões e
+	// atributos de cada um; sem ele todos os pedidos são anónimos
+	Users string `json:"users"`
+	// AuthzPolicy é a política de autorização em Rego; vazio usa policies/authz.rego
+	// embutida no binário
+	AuthzPolicy string `json:"authz_policy"`
+	// OrderStore é onde ficam os pedidos em rascunho: "memory" ou "file" (em data_dir/orders)
+	OrderStore string `json:"order_store"`
+	// OrderTTL é o tempo sem alterações após o qual um rascunho expira
+	OrderTTL time.Duration `json:"-"`
+	// IdempotencyStore é onde ficam as respostas por Idempotency-Key: "file"
+	// (data_dir/idempotency.jsonl, sobrevive a reinícios) ou "memory"
+	IdempotencyStore      string        `json:"idempotency_store"`
+	IdempotencyTTL        time.Duration `json:"-"`
+	IdempotencyMaxEntries int           `json:"idempotency_max_entries"`
+	// SalesStore é o repositório de vendas: "file" (data_dir/sales.jsonl) ou "kv"
+	// (base de dados embutida em data_dir/sales.db, para volumes maiores)
+	SalesStore string `json:"sales_store"`
+	// FiscalSeries é o ficheiro com as séries de documentos por loja, terminal e tipo;
+	// vazio usa uma série "<LOJA>-<TERMINAL>" por ponto de emissão
+	FiscalSeries string `json:"fiscal_series"`
+	// SigningKey é a chave privada RSA (PEM) que assina os documentos fiscais; sem ela os
+	// documentos ficam por assinar. SigningKeyVersion é gravada em cada documento
+	SigningKey        string `json:"signing_key"`
+	SigningKeyVersion string `json:"signing_key_version"`
+	// SAFTSchema é o XSD do SAF-T (AO) usado para validar as exportações; vazio usa o XSD
+	// embutido. Sem xmllint as exportações falham, salvo com SAFTSkipValidation
+	SAFTSchema         string `json:"saft_xsd"`
+	SAFTSkipValidation bool   `json:"saft_skip_validation"`
+}
+
+// configFile espelha Config com o intervalo em texto ("5s"), como aparece no JSON.
+type configFile struct {
+	*Config
+	RulesPollInterval string `json:"rules_poll_interval"`
+	OrderTTL          string `json:"order_ttl"`
+	IdempotencyTTL    string `json:"idempotency_ttl"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		RulesDir:              "data/rules",
+		RulesPollInterval:     5 * time.Second,
+		DataDir:               "data/db",
+		ListenAddr:            ":8080",
+		CORSOrigins:           []string{"*"},
+		OrderStore:            "memory",
+		OrderTTL:              24 * time.Hour,
+		IdempotencyStore:      "file",
+		IdempotencyTTL:        24 * time.Hour,
+		IdempotencyMaxEntries: 100000,
+		SalesStore:            "file",
+		SigningKeyVersion:     "1",
+		SAFTSchema:            "data/saft/SAFTAO1.01_01.xsd",
+	}
+}
+
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	flags := flag.NewFlagSet("engine", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("ENGINE_CONFIG"), "ficheiro de configuração JSON (opcional)")
+	flags.String("rules-dir", cfg.RulesDir, "diretório dos RulePacks; vazio usa os packs embutidos")
+	flags.Duration("rules-poll-interval", cfg.RulesPollInterval, "intervalo de verificação de alterações em rules-dir")
+	flags.String("data-dir", cfg.DataDir, "diretório de dados (vendas)")
+	flags.String("listen-addr", cfg.ListenAddr, "endereço HTTP do servidor")
+	flags.String("cors-origins", strings.Join(cfg.CORSOrigins, ","), "origens CORS permitidas, separadas por vírgula")
+	flags.String("trusted-keys", "", "chaves públicas PEM confiáveis para assinatura de packs, separadas por vírgula")
+	flags.Bool("production", cfg.Production, "modo produção: só aceita packs assinados")
+	flags.Float64("delta-tolerance", cfg.DeltaTolerance, "diferença numérica abaixo da qual uma alteração do servidor é cosmética")
+	flags.String("patch-policy", cfg.PatchPolicy, "ficheiro JSON com os caminhos alteráveis pelo cliente em /orders/patch")
+	flags.String("users", cfg.Users, "ficheiro JSON com os utilizadores e os SHA-256 dos seus tokens")
+	flags.String("authz-policy", cfg.AuthzPolicy, "política de autorização em Rego; vazio usa a embutida")
+	flags.String("order-store", cfg.OrderStore, "armazenamento dos pedidos em rascunho: memory ou file")
+	flags.Duration("order-ttl", cfg.OrderTTL, "tempo sem alterações após o qual um pedido em rascunho expira")
+	flags.String("idempotency-store", cfg.IdempotencyStore, "armazenamento das respostas por Idempotency-Key: file ou memory")
+	flags.Duration("idempotency-ttl", cfg.IdempotencyTTL, "tempo durante o qual uma resposta idempotente é repetida")
+	flags.Int("idempotency-max-entries", cfg.IdempotencyMaxEntries, "número máximo de respostas idempotentes guardadas")
+	flags.String("sales-store", cfg.SalesStore, "repositório de vendas: file ou kv")
+	flags.String("fiscal-series", cfg.FiscalSeries, "ficheiro JSON com as séries de documentos fiscais por loja e terminal")
+	flags.String("signing-key", cfg.SigningKey, "chave privada RSA (PEM) para assinar os documentos fiscais")
+	flags.String("signing-key-version", cfg.SigningKeyVersion, "versão da chave de assinatura gravada em cada documento")
+	flags.String("saft-xsd", cfg.SAFTSchema, "XSD do SAF-T (AO) usado para validar as exportações")
+	flags.Bool("saft-skip-validation", cfg.SAFTSkipValidation, "exporta o SAF-T sem o validar contra o XSD")
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configPath != "" {
+		raw, err := os.ReadFile(*configPath)
+		if err != nil {
+			return cfg, fmt.Errorf("falha ao ler configuração: %w", err)
+		}
+		file := configFile{Config: &cfg}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return cfg, fmt.Errorf("configuração inválida em %s: %w", *configPath, err)
+		}
+		if file.RulesPollInterval != "" {
+			if err := cfg.set("rules-poll-interval", file.RulesPollInterval); err != nil {
+				return cfg, err
+			}
+		}
+		for name, value := range map[string]string{"order-ttl": file.OrderTTL, "idempotency-ttl": file.IdempotencyTTL} {
+			if value == "" {
+				continue
+			}
+			if err := cfg.set(name, value); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	for name, env := range map[string]string{
+		"rules-dir":               "ENGINE_RULES_DIR",
+		"rules-poll-interval":     "ENGINE_RULES_POLL_INTERVAL",
+		"data-dir":                "ENGINE_DATA_DIR",
+		"listen-addr":             "ENGINE_LISTEN_ADDR",
+		"cors-origins":            "ENGINE_CORS_ORIGINS",
+		"trusted-keys":            "ENGINE_TRUSTED_KEYS",
+		"production":              "ENGINE_PRODUCTION",
+		"delta-tolerance":         "ENGINE_DELTA_TOLERANCE",
+		"patch-policy":            "ENGINE_PATCH_POLICY",
+		"users":                   "ENGINE_USERS",
+		"authz-policy":            "ENGINE_AUTHZ_POLICY",
+		"order-store":             "ENGINE_ORDER_STORE",
+		"order-ttl":               "ENGINE_ORDER_TTL",
+		"idempotency-store":       "ENGINE_IDEMPOTENCY_STORE",
+		"idempotency-ttl":         "ENGINE_IDEMPOTENCY_TTL",
+		"idempotency-max-entries": "ENGINE_IDEMPOTENCY_MAX_ENTRIES",
+		"sales-store":             "ENGINE_SALES_STORE",
+		"fiscal-series":           "ENGINE_FISCAL_SERIES",
+		"signing-key":             "ENGINE_SIGNING_KEY",
+		"signing-key-version":     "ENGINE_SIGNING_KEY_VERSION",
+		"saft-xsd":                "ENGINE_SAFT_XSD",
+		"saft-skip-validation":    "ENGINE_SAFT_SKIP_VALIDATION",
+	} {
+		if value, ok := os.LookupEnv(env); ok {
+			if err := cfg.set(name, value); err != nil {
+				return cfg, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && flagErr == nil {
+			flagErr = cfg.set(f.Name, f.Value.String())
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	if cfg.Production && len(cfg.TrustedKeys) == 0 {
+		return cfg, fmt.Errorf("modo produção exige pelo menos uma chave em trusted-keys")
+	}
+	if cfg.Production && cfg.SigningKey == "" {
+		return cfg, fmt.Errorf("modo produção exige signing-key para assinar os documentos fiscais")
+	}
+	return cfg, nil
+}
+
+// resolveRulesDir verifica rules_dir. O valor por omissão é relativo ao diretório de
+// trabalho: se não existir (ex: o binário corre fora do repositório), o servidor usa os
+// packs embutidos e o bool indica-o, para que o arranque o registe. Um diretório indicado
+// na configuração que não exista é um erro.
+func (c *Config) resolveRulesDir() (bool, error) {
+	if c.RulesDir == "" {
+		return false, nil
+	}
+	info, err := os.Stat(c.RulesDir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s não é um diretório", c.RulesDir)
+	}
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, fs.ErrNotExist) && c.RulesDir == defaultConfig().RulesDir:
+		c.RulesDir = ""
+		return true, nil
+	default:
+		return false, fmt.Errorf("rules-dir: %w", err)
+	}
+}
+
+// resolveSAFTSchema troca o XSD por omissão pelo embutido quando o ficheiro não existe
+// (ex: binário a correr fora do repositório), como resolveRulesDir faz com as regras.
+// Devolve true se a troca foi feita; um caminho indicado explicitamente tem de existir.
+func (c *Config) resolveSAFTSchema() (bool, error) {
+	if c.SAFTSchema == "" || c.SAFTSkipValidation {
+		return false, nil
+	}
+	_, err := os.Stat(c.SAFTSchema)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, fs.ErrNotExist) && c.SAFTSchema == defaultConfig().SAFTSchema:
+		c.SAFTSchema = ""
+		return true, nil
+	default:
+		return false, fmt.Errorf("saft-xsd: %w", err)
+	}
+}
+
+func (c *Config) set(name, value string) error {
+	switch name {
+	case "rules-dir":
+		c.RulesDir = value
+	case "rules-poll-interval":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("rules-poll-interval inválido: %w", err)
+		}
+		c.RulesPollInterval = d
+	case "data-dir":
+		c.DataDir = value
+	case "listen-addr":
+		c.ListenAddr = value
+	case "cors-origins":
+		c.CORSOrigins = splitList(value)
+	case "trusted-keys":
+		c.TrustedKeys = splitList(value)
+	case "production":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("production inválido: %w", err)
+		}
+		c.Production = b
+	case "delta-tolerance":
+		tolerance, err := parseTolerance(value)
+		if err != nil {
+			return fmt.Errorf("delta-tolerance inválido: %w", err)
+		}
+		c.DeltaTolerance = tolerance
+	case "patch-policy":
+		c.PatchPolicy = value
+	case "users":
+		c.Users = value
+	case "authz-policy":
+		c.AuthzPolicy = value
+	case "order-store":
+		if value != "memory" && value != "file" {
+			return fmt.Errorf("order-store inválido: %q (use memory ou file)", value)
+		}
+		c.OrderStore = value
+	case "order-ttl":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("order-ttl inválido: %w", err)
+		}
+		c.OrderTTL = d
+	case "idempotency-store":
+		if value != "memory" && value != "file" {
+			return fmt.Errorf("idempotency-store inválido: %q (use memory ou file)", value)
+		}
+		c.IdempotencyStore = value
+	case "idempotency-ttl":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("idempotency-ttl inválido: %w", err)
+		}
+		c.IdempotencyTTL = d
+	case "idempotency-max-entries":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("idempotency-max-entries inválido: %q", value)
+		}
+		c.IdempotencyMaxEntries = n
+	case "sales-store":
+		if value != "file" && value != "kv" {
+			return fmt.Errorf("sales-store inválido: %q (use file ou kv)", value)
+		}
+		c.SalesStore = value
+	case "fiscal-series":
+		c.FiscalSeries = value
+	case "signing-key":
+		c.SigningKey = value
+	case "signing-key-version":
+		if value == "" {
+			return fmt.Errorf("signing-key-version não pode ser vazio")
+		}
+		c.SigningKeyVersion = value
+	case "saft-xsd":
+		c.SAFTSchema = value
+	case "saft-skip-validation":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("saft-skip-validation inválido: %w", err)
+		}
+		c.SAFTSkipValidation = b
+	}
+	return nil
+}
+
+func parseTolerance(value string) (float64, error) {
+	tolerance, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if tolerance < 0 {
+		return 0, fmt.Errorf("não pode ser negativa")
+	}
+	return tolerance, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
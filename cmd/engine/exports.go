@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/labstack/echo/v4"
+)
+
+func registerExportRoutes(e *echo.Echo, saft interfaces.SAFTExporter) {
+	e.GET("/exports/saft", handleSAFTExport(saft))
+}
+
+// handleSAFTExport devolve o SAF-T (AO) do período ?from=&to= (datas como em GET /sales).
+// X-SAFT-Schema-Validated indica se o ficheiro foi validado contra o XSD; sem o XSD ou o
+// xmllint a exportação é recusada com 503, salvo com saft_skip_validation.
+func handleSAFTExport(saft interfaces.SAFTExporter) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		from, err := parseSalesDate(c, "from")
+		if err == nil && from == nil {
+			err = fmt.Errorf("from é obrigatório")
+		}
+		if err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Período Inválido", err.Error())
+		}
+		to, err := parseSalesDate(c, "to")
+		if err == nil && to == nil {
+			err = fmt.Errorf("to é obrigatório")
+		}
+		if err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Período Inválido", err.Error())
+		}
+
+		export, err := saft.Export(c.Request().Context(), *from, *to)
+		switch {
+		case errors.Is(err, domain.ErrInvalidSalesQuery):
+			return errorRFC7807(c, http.StatusBadRequest, "Período Inválido", err.Error())
+		case errors.Is(err, domain.ErrInvalidSAFT):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "SAF-T Inválido", err.Error())
+		case errors.Is(err, domain.ErrSchemaUnavailable):
+			return errorRFC7807(c, http.StatusServiceUnavailable, "Validação SAF-T Indisponível", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro na Exportação", err.Error())
+		}
+
+		name := fmt.Sprintf("SAFT_AO_%s_%s.xml", from.Format("20060102"), to.Add(-time.Nanosecond).Format("20060102"))
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`"`)
+		c.Response().Header().Set("X-SAFT-Schema-Validated", strconv.FormatBool(export.SchemaValidated))
+		return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, export.XML)
+	}
+}
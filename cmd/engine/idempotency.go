@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// Tempo máximo que um pedido repetido espera pela resposta do original ainda em execução
+const idempotencyWait = 30 * time.Second
+
+// Cabeçalhos do pedido que mudam o resultado e entram na identificação do pedido
+var idempotencyRequestHeaders = []string{"X-Store-ID", "X-Terminal-ID", "If-Match"}
+
+// Cabeçalhos da resposta guardados com ela e devolvidos na repetição
+var idempotencyResponseHeaders = []string{"ETag", echo.HeaderLocation}
+
+// idempotency repete a resposta guardada quando o pedido traz um Idempotency-Key já
+// usado pelo mesmo tenant. Só as respostas 2xx são guardadas: um pedido que falhou
+// pode ser repetido com a mesma chave.
+func idempotency(store interfaces.IdempotencyStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get("Idempotency-Key")
+			if key == "" {
+				return next(c)
+			}
+			tenant := c.Request().Header.Get("X-Tenant-ID")
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", err.Error())
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			fingerprint := requestFingerprint(c.Request(), body)
+			ctx, cancel := context.WithTimeout(c.Request().Context(), idempotencyWait)
+			defer cancel()
+			record, err := store.Begin(ctx, tenant, key, fingerprint)
+			switch {
+			case errors.Is(err, domain.ErrIdempotencyKeyReused):
+				return errorRFC7807(c, http.StatusUnprocessableEntity, "Chave de Idempotência Reutilizada",
+					"o Idempotency-Key "+key+" já foi usado com um pedido diferente")
+			case errors.Is(err, domain.ErrIdempotencyInFlight):
+				c.Response().Header().Set("Retry-After", "1")
+				return errorRFC7807(c, http.StatusConflict, "Pedido em Curso", "um pedido com o mesmo Idempotency-Key ainda está a ser processado")
+			case err != nil:
+				return errorRFC7807(c, http.StatusInternalServerError, "Erro de Idempotência", err.Error())
+			case record != nil:
+				for name, value := range record.Headers {
+					c.Response().Header().Set(name, value)
+				}
+				c.Response().Header().Set("Idempotent-Replayed", "true")
+				return c.Blob(record.Status, record.ContentType, record.Body)
+			}
+
+			// Liberta a chave também se o handler entrar em pânico
+			completed := false
+			defer func() {
+				if !completed {
+					store.Abort(context.Background(), tenant, key)
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			err = next(c)
+
+			status := c.Response().Status
+			if err != nil || !c.Response().Committed || status < 200 || status > 299 {
+				return err
+			}
+			completed = true
+			headers := make(map[string]string)
+			for _, name := range idempotencyResponseHeaders {
+				if value := c.Response().Header().Get(name); value != "" {
+					headers[name] = value
+				}
+			}
+			if err := store.Complete(c.Request().Context(), domain.IdempotencyRecord{
+				Tenant:      tenant,
+				Key:         key,
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Headers:     headers,
+				Body:        recorder.body.Bytes(),
+			}); err != nil {
+				c.Logger().Errorf("falha ao guardar resposta idempotente %s: %v", key, err)
+			}
+			return nil
+		}
+	}
+}
+
+// requestFingerprint identifica o pedido pelo método, caminho, query, cabeçalhos que mudam
+// o resultado, utilizador autenticado e corpo: a mesma chave com outra loja, outro terminal
+// ou outro utilizador é um pedido diferente. Um corpo JSON é normalizado primeiro, para que
+// espaços ou a ordem das chaves não contem como diferença, tal como a ordem da query.
+func requestFingerprint(req *http.Request, body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if decoder.Decode(&doc) == nil {
+		if canonical, err := json.Marshal(doc); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.New()
+	io.WriteString(sum, req.Method+" "+req.URL.Path+"?"+req.URL.Query().Encode()+"\n")
+	for _, name := range idempotencyRequestHeaders {
+		io.WriteString(sum, name+": "+req.Header.Get(name)+"\n")
+	}
+	io.WriteString(sum, "caller: "+usecase.CallerFrom(req.Context()).UserID+"\n")
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
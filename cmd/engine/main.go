@@ -1,23 +1,24 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"io/fs"
+	"log"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"sync"
-	"time"
 
+	"github.com/Victor-armando18/service-commercial/data"
 	"github.com/Victor-armando18/service-commercial/internal/domain"
 	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
 	"github.com/Victor-armando18/service-commercial/internal/interfaces"
 	"github.com/Victor-armando18/service-commercial/internal/usecase"
+	"github.com/Victor-armando18/service-commercial/policies"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-)
-
-var (
-	idempotencyStorage = make(map[string][]byte)
-	idempotencyMu      sync.RWMutex
+	gommonlog "github.com/labstack/gommon/log"
 )
 
 type PatchRequest struct {
@@ -26,126 +27,290 @@ type PatchRequest struct {
 }
 
 func main() {
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("configuração: %v", err)
+	}
+
 	e := echo.New()
+	// O nível por omissão do Echo (ERROR) esconderia os avisos de recarga e de regras alteradas
+	e.Logger.SetLevel(gommonlog.INFO)
+
+	embeddedRules, err := cfg.resolveRulesDir()
+	if err != nil {
+		log.Fatalf("configuração: %v", err)
+	}
+	if embeddedRules {
+		wd, _ := os.Getwd()
+		e.Logger.Warnf("diretório de regras %s inexistente em %s: a usar os packs embutidos (só de leitura)", defaultConfig().RulesDir, wd)
+	}
+	embeddedSchema, err := cfg.resolveSAFTSchema()
+	if err != nil {
+		log.Fatalf("configuração: %v", err)
+	}
+	if embeddedSchema {
+		wd, _ := os.Getwd()
+		e.Logger.Warnf("XSD do SAF-T %s inexistente em %s: a usar o XSD embutido", defaultConfig().SAFTSchema, wd)
+	}
 
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodPost, http.MethodPatch, http.MethodOptions, http.MethodGet},
-		AllowHeaders: []string{echo.HeaderContentType, echo.HeaderAccept, "X-Tenant-ID", "Idempotency-Key", "X-Correlation-ID"},
+		AllowOrigins:  cfg.CORSOrigins,
+		AllowMethods:  []string{http.MethodPost, http.MethodPatch, http.MethodOptions, http.MethodGet},
+		AllowHeaders:  []string{echo.HeaderContentType, echo.HeaderAccept, "X-Tenant-ID", "Idempotency-Key", "X-Correlation-ID", "If-Match", echo.HeaderAuthorization, "X-Store-ID", "X-Terminal-ID"},
+		ExposeHeaders: []string{"ETag", echo.HeaderLocation, "Idempotent-Replayed", echo.HeaderContentDisposition, "X-SAFT-Schema-Validated", echo.HeaderWWWAuthenticate},
 	}))
 
-	loader := infrastructure.NewFileRuleLoader()
-	executor := infrastructure.NewJsonLogicExecutor()
-	executor.RegisterCustomOperator("allocate", infrastructure.CustomAllocate)
-	executor.RegisterCustomOperator("round", infrastructure.CustomRound)
-
-	engineSvc := usecase.NewEngineService(loader, executor)
+	var auth interfaces.Authenticator
+	if cfg.Users != "" {
+		if auth, err = infrastructure.LoadUserTokens(cfg.Users); err != nil {
+			log.Fatalf("utilizadores: %v", err)
+		}
+	}
+	e.Use(authenticate(auth))
+	authz, err := infrastructure.NewRegoAuthorizer("policies/authz.rego", policies.Authz)
+	if cfg.AuthzPolicy != "" {
+		authz, err = infrastructure.LoadRegoAuthorizer(cfg.AuthzPolicy)
+	}
+	if err != nil {
+		log.Fatalf("política de autorização: %v", err)
+	}
 
-	e.POST("/orders", handleCalculate(engineSvc))
-	e.POST("/orders/patch", handlePatch(engineSvc))
-	e.POST("/sales", handleSale(engineSvc))
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	validator := usecase.NewRulePackValidator(executor)
 
-	e.Logger.Fatal(e.Start(":8080"))
-}
+	audit := infrastructure.NewFileAuditLog(filepath.Join(cfg.DataDir, "rules_audit.jsonl"))
+	loaderOpts := []infrastructure.LoaderOption{infrastructure.WithPublished(audit)}
+	if len(cfg.TrustedKeys) > 0 {
+		keys, err := infrastructure.LoadTrustedKeys(cfg.TrustedKeys)
+		if err != nil {
+			log.Fatalf("chaves confiáveis: %v", err)
+		}
+		loaderOpts = append(loaderOpts, infrastructure.WithVerifier(infrastructure.NewSignatureVerifier(keys, cfg.Production)))
+	}
 
-func handlePatch(svc interfaces.EngineFacade) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		tenantID := c.Request().Header.Get("X-Tenant-ID")
-		idempotencyKey := c.Request().Header.Get("Idempotency-Key")
-
-		if idempotencyKey != "" {
-			idempotencyMu.RLock()
-			if val, ok := idempotencyStorage[idempotencyKey]; ok {
-				idempotencyMu.RUnlock()
-				return c.JSONBlob(http.StatusOK, val)
-			}
-			idempotencyMu.RUnlock()
+	var loader interfaces.RulePackRepository
+	if cfg.RulesDir == "" {
+		embedded, err := fs.Sub(data.RulePacks, "rules")
+		if err != nil {
+			log.Fatalf("packs embutidos: %v", err)
 		}
+		loader = infrastructure.NewFSRuleLoader(embedded, "embed:rules", validator, loaderOpts...)
+	} else {
+		loader = infrastructure.NewFileRuleLoader(cfg.RulesDir, validator, loaderOpts...)
+	}
 
-		var req PatchRequest
-		if err := c.Bind(&req); err != nil {
-			return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", err.Error())
+	engineSvc := usecase.NewEngineService(loader, executor)
+	schemas, err := fs.Sub(data.Schemas, "schema")
+	if err != nil {
+		log.Fatalf("schemas embutidos: %v", err)
+	}
+	var sales interfaces.SalesRepository
+	if cfg.SalesStore == "kv" {
+		sales, err = infrastructure.NewBoltSalesRepository(cfg.DataDir)
+	} else {
+		sales, err = infrastructure.NewFileSalesRepository(cfg.DataDir)
+	}
+	if err != nil {
+		log.Fatalf("repositório de vendas: %v", err)
+	}
+	defer sales.Close()
+	checkSeries(e, sales)
+	var seriesCatalog domain.SeriesCatalog
+	if cfg.FiscalSeries != "" {
+		if seriesCatalog, err = infrastructure.LoadSeriesCatalog(cfg.FiscalSeries); err != nil {
+			log.Fatalf("séries de documentos: %v", err)
 		}
+	}
+	var signer interfaces.DocumentSigner
+	if cfg.SigningKey != "" {
+		if signer, err = infrastructure.LoadDocumentSigner(cfg.SigningKey, cfg.SigningKeyVersion); err != nil {
+			log.Fatalf("chave de assinatura: %v", err)
+		}
+	}
 
-		patchBytes, _ := json.Marshal(req.Patch)
-		updatedOrder, err := infrastructure.ApplyOrderPatch(req.Order, patchBytes)
-		if err != nil {
-			return errorRFC7807(c, http.StatusUnprocessableEntity, "Erro no Patch", err.Error())
+	activations, err := infrastructure.NewFileActivationStore(filepath.Join(cfg.DataDir, "rule_activations.json"))
+	if err != nil {
+		log.Fatalf("ativações de regras: %v", err)
+	}
+	patchPolicy := domain.DefaultPatchPolicy()
+	if cfg.PatchPolicy != "" {
+		if patchPolicy, err = infrastructure.LoadPatchPolicy(cfg.PatchPolicy); err != nil {
+			log.Fatalf("política de patch: %v", err)
+		}
+	}
+	orderStore := infrastructure.NewMemoryOrderSessionStore(cfg.OrderTTL)
+	if cfg.OrderStore == "file" {
+		if orderStore, err = infrastructure.NewFileOrderSessionStore(filepath.Join(cfg.DataDir, "orders"), cfg.OrderTTL); err != nil {
+			log.Fatalf("sessões de pedidos: %v", err)
 		}
+	}
+	orderSessions := usecase.NewOrderSessionService(engineSvc, orderStore, patchPolicy, authz)
 
-		ctx := c.Request().Context()
-		result, err := svc.RunEngine(ctx, updatedOrder, updatedOrder.RulesVersion)
+	idempotencyStore := infrastructure.NewMemoryIdempotencyStore(cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
+	if cfg.IdempotencyStore == "file" {
+		idempotencyStore, err = infrastructure.NewFileIdempotencyStore(filepath.Join(cfg.DataDir, "idempotency.jsonl"), cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
 		if err != nil {
-			return errorRFC7807(c, http.StatusInternalServerError, "Erro de Execução", err.Error())
+			log.Fatalf("idempotência: %v", err)
 		}
+	}
+	idem := idempotency(idempotencyStore)
+	ruleAdmin := usecase.NewRulePackAdminService(loader, activations, audit, authz)
 
-		result.StateFragment["tenantId"] = tenantID
+	// Carga inicial de todos os packs e, com diretório em disco, recarga automática quando muda
+	if report, err := loader.Reload(context.Background()); err != nil {
+		e.Logger.Errorf("falha na carga inicial de regras: %v", err)
+	} else {
+		logReloadReport(e, report)
+		warnRulesDrift(e, loader, sales)
+	}
+	if cfg.RulesDir != "" && cfg.RulesPollInterval > 0 {
+		go loader.Watch(context.Background(), cfg.RulesPollInterval, func(report domain.ReloadReport, err error) {
+			if err != nil {
+				e.Logger.Errorf("falha ao recarregar regras: %v", err)
+				return
+			}
+			logReloadReport(e, report)
+			if report.Changed() {
+				warnRulesDrift(e, loader, sales)
+			}
+		})
+	}
 
-		if idempotencyKey != "" {
-			respBytes, _ := json.Marshal(result)
-			idempotencyMu.Lock()
-			idempotencyStorage[idempotencyKey] = respBytes
-			idempotencyMu.Unlock()
-		}
+	e.Use(deltaTolerance(cfg.DeltaTolerance))
 
-		return c.JSON(http.StatusOK, result)
+	e.POST("/admin/rules/reload", handleRulesReload(loader, sales), requirePermission(authz, domain.PermissionManageRules))
+	e.StaticFS("/schemas", schemas)
+	registerOrderRoutes(e, orderSessions, ruleAdmin, idem)
+	// Devoluções e anulações verificam e gravam as correções de uma venda sob o mesmo lock
+	corrections := &sync.Mutex{}
+	returns := usecase.NewReturnService(engineSvc, sales, seriesCatalog, signer, corrections)
+	voids := usecase.NewVoidService(sales, authz, corrections)
+	registerSalesRoutes(e, engineSvc, ruleAdmin, authz, sales, returns, voids, seriesCatalog, signer, idem)
+	var saftValidator interfaces.XMLValidator
+	if cfg.SAFTSkipValidation {
+		e.Logger.Warnf("saft_skip_validation ativo: as exportações SAF-T não são validadas contra o XSD")
+	} else {
+		saftValidator = infrastructure.NewXMLLintValidatorFS(data.SAFTSchema, "saft/SAFTAO1.01_01.xsd")
+		if cfg.SAFTSchema != "" {
+			saftValidator = infrastructure.NewXMLLintValidator(cfg.SAFTSchema)
+		}
+		if _, err := exec.LookPath("xmllint"); err != nil {
+			e.Logger.Errorf("xmllint indisponível (%v): as exportações SAF-T serão recusadas", err)
+		}
 	}
+	masterData := infrastructure.NewFileMasterData(cfg.DataDir)
+	registerExportRoutes(e, usecase.NewSAFTService(sales, masterData, saftValidator))
+	registerReceiptRoutes(e, usecase.NewReceiptService(sales, masterData, map[domain.ReceiptFormat]interfaces.ReceiptRenderer{
+		domain.ReceiptText:   infrastructure.NewTextReceiptRenderer(),
+		domain.ReceiptESCPOS: infrastructure.NewESCPOSReceiptRenderer(),
+		domain.ReceiptPDF:    infrastructure.NewPDFReceiptRenderer(),
+	}))
+	registerRuleAdminRoutes(e, ruleAdmin, authz)
+
+	e.Logger.Fatal(e.Start(cfg.ListenAddr))
 }
 
-func handleCalculate(svc interfaces.EngineFacade) echo.HandlerFunc {
+func handleRulesReload(loader interfaces.ReloadableRuleLoader, sales interfaces.SalesRepository) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var order domain.Order
-		if err := c.Bind(&order); err != nil {
-			return errorRFC7807(c, http.StatusBadRequest, "Erro de Parsing", err.Error())
+		report, err := loader.Reload(c.Request().Context())
+		if err != nil {
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Recarregar Regras", err.Error())
+		}
+		logReloadReport(c.Echo(), report)
+		if report.Changed() {
+			warnRulesDrift(c.Echo(), loader, sales)
 		}
 
-		result, err := svc.RunEngine(c.Request().Context(), order, order.RulesVersion)
-		if err != nil {
-			return errorRFC7807(c, http.StatusInternalServerError, "Erro no Motor", err.Error())
+		status := http.StatusOK
+		if len(report.Errors) > 0 {
+			// Os packs válidos foram ativados; os inválidos mantêm a versão anterior
+			status = http.StatusMultiStatus
 		}
-		return c.JSON(http.StatusOK, result)
+		return c.JSON(status, report)
 	}
 }
 
-func handleSale(svc interfaces.EngineFacade) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		var order domain.Order
-		if err := c.Bind(&order); err != nil {
-			return errorRFC7807(c, http.StatusBadRequest, "Venda Inválida", err.Error())
-		}
+func logReloadReport(e *echo.Echo, report domain.ReloadReport) {
+	if report.Changed() {
+		e.Logger.Infof("regras recarregadas: ativas %v, removidas %v", report.Loaded, report.Removed)
+	}
+	for version, msg := range report.Errors {
+		e.Logger.Errorf("rulepack %s rejeitado, mantida versão anterior: %s", version, msg)
+	}
+}
 
-		result, _ := svc.RunEngine(c.Request().Context(), order, order.RulesVersion)
-		if len(result.GuardsHit) > 0 {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"type":   "https://dolphin.com/err/guard-violation",
-				"title":  "Venda Bloqueada por Guardas",
-				"status": 403,
-				"detail": result.GuardsHit[0].Context,
-			})
-		}
+// warnRulesDrift regista as versões cujo conteúdo mudou desde que as vendas gravadas
+// foram calculadas: essas vendas deixam de ser reproduzíveis com o pack atual.
+func warnRulesDrift(e *echo.Echo, loader interfaces.RulePackLoader, sales interfaces.SalesRepository) {
+	all, err := sales.All(context.Background())
+	if err != nil {
+		e.Logger.Errorf("falha ao ler vendas: %v", err)
+		return
+	}
+	orders := make([]domain.Order, 0, len(all))
+	for _, sale := range all {
+		orders = append(orders, sale.Order)
+	}
 
-		order.ID = "SALE-" + time.Now().Format("20060102150405")
+	for _, drift := range usecase.DetectRulesDrift(context.Background(), loader, orders) {
+		current := drift.CurrentHash
+		if current == "" {
+			current = "indisponível"
+		}
+		e.Logger.Warnf("rulepack %s alterado: %d venda(s) calculadas com %s, pack atual %s (%v)",
+			drift.Version, len(drift.SaleIDs), drift.StoredHash, current, drift.SaleIDs)
+	}
+}
 
-		saveToJSON("data/db/sales.json", order)
+// checkSeries regista as séries de documentos com falhas ou números repetidos. O
+// repositório nunca as produz; só aparecem se os ficheiros de dados forem alterados à mão.
+func checkSeries(e *echo.Echo, sales interfaces.SalesRepository) {
+	all, err := sales.All(context.Background())
+	if err != nil {
+		e.Logger.Errorf("falha ao ler vendas: %v", err)
+		return
+	}
+	for _, gap := range usecase.CheckSeries(all) {
+		e.Logger.Errorf("série %s não sequencial: números em falta %v, repetidos %v", gap.Series, gap.Missing, gap.Repeated)
+	}
+}
 
-		return c.JSON(http.StatusCreated, order)
+// deltaTolerance põe no contexto do pedido a tolerância das alterações cosméticas:
+// a do parâmetro ?tolerance= ou, sem ele, a configurada.
+func deltaTolerance(defaultTolerance float64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tolerance := defaultTolerance
+			if raw := c.QueryParam("tolerance"); raw != "" {
+				t, err := parseTolerance(raw)
+				if err != nil {
+					return errorRFC7807(c, http.StatusBadRequest, "Tolerância Inválida", err.Error())
+				}
+				tolerance = t
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(usecase.WithDeltaTolerance(req.Context(), tolerance)))
+			return next(c)
+		}
 	}
 }
 
 func errorRFC7807(c echo.Context, status int, title, detail string) error {
-	return c.JSON(status, map[string]interface{}{
+	return errorRFC7807Ext(c, status, title, detail, nil)
+}
+
+// errorRFC7807Ext acrescenta membros de extensão ao problema (ex: diagnostics).
+func errorRFC7807Ext(c echo.Context, status int, title, detail string, extensions map[string]interface{}) error {
+	problem := map[string]interface{}{
 		"type":   "https://dolphin.com/errors",
 		"title":  title,
 		"status": status,
 		"detail": detail,
-	})
-}
-
-func saveToJSON(path string, data interface{}) {
-	var list []interface{}
-	file, _ := os.ReadFile(path)
-	json.Unmarshal(file, &list)
-	list = append(list, data)
-	newContent, _ := json.MarshalIndent(list, "", "  ")
-	os.WriteFile(path, newContent, 0644)
+	}
+	for k, v := range extensions {
+		problem[k] = v
+	}
+	return c.JSON(status, problem)
 }
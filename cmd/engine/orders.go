@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/labstack/echo/v4"
+)
+
+// Limite de tamanho de um patch enviado para uma sessão de pedido
+const maxOrderPatchSize = 1 << 20
+
+func registerOrderRoutes(e *echo.Echo, sessions interfaces.OrderSessions, admin interfaces.RulePackAdmin, idem echo.MiddlewareFunc) {
+	e.POST("/orders", handleCreateOrder(sessions, admin))
+	e.POST("/orders/patch", handlePatch(sessions, admin), idem)
+	e.GET("/orders/:id", handleGetOrder(sessions))
+	e.PATCH("/orders/:id", handlePatchOrder(sessions))
+}
+
+// handleCreateOrder calcula o pedido e guarda-o como rascunho no servidor; os patches
+// seguintes podem ser enviados para /orders/{id} sem reenviar o pedido completo.
+func handleCreateOrder(sessions interfaces.OrderSessions, admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var order domain.Order
+		if err := c.Bind(&order); err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Erro de Parsing", err.Error())
+		}
+		if response, ok := activeRulesVersion(c, admin, &order); ok {
+			return response
+		}
+
+		result, err := sessions.Create(c.Request().Context(), order)
+		if err != nil {
+			return orderError(c, err)
+		}
+
+		id, _ := result.StateFragment["id"].(string)
+		c.Response().Header().Set("Location", "/orders/"+id)
+		c.Response().Header().Set("ETag", formatETag(result.Revision))
+		return c.JSON(http.StatusCreated, result)
+	}
+}
+
+func handleGetOrder(sessions interfaces.OrderSessions) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, err := sessions.Get(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return orderError(c, err)
+		}
+		c.Response().Header().Set("ETag", formatETag(session.Revision))
+		return c.JSON(http.StatusOK, session.Result)
+	}
+}
+
+// handlePatchOrder aplica um patch à sessão: RFC 6902 (application/json-patch+json) ou
+// RFC 7386 (application/merge-patch+json). Com application/json o formato é deduzido
+// do corpo: um array é um JSON Patch, um objeto é um merge patch.
+func handlePatchOrder(sessions interfaces.OrderSessions) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxOrderPatchSize+1))
+		if err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", err.Error())
+		}
+		if len(body) > maxOrderPatchSize {
+			return errorRFC7807(c, http.StatusRequestEntityTooLarge, "Patch Demasiado Grande", "o limite é 1 MiB")
+		}
+
+		format, err := patchFormat(c.Request().Header.Get(echo.HeaderContentType), body)
+		if err != nil {
+			c.Response().Header().Set("Accept-Patch", domain.PatchFormatJSON+", "+domain.PatchFormatMerge)
+			return errorRFC7807(c, http.StatusUnsupportedMediaType, "Formato de Patch Não Suportado", err.Error())
+		}
+
+		result, err := sessions.PatchSession(c.Request().Context(), c.Param("id"), format, body, parseETag(c.Request().Header.Get("If-Match")))
+		if err != nil {
+			return orderError(c, err)
+		}
+		c.Response().Header().Set("ETag", formatETag(result.Revision))
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func patchFormat(contentType string, body []byte) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case domain.PatchFormatJSON, domain.PatchFormatMerge:
+		return mediaType, nil
+	case "", echo.MIMEApplicationJSON:
+		if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+			return domain.PatchFormatJSON, nil
+		}
+		return domain.PatchFormatMerge, nil
+	}
+	return "", errors.New("use " + domain.PatchFormatJSON + " ou " + domain.PatchFormatMerge)
+}
+
+// handlePatch mantém /orders/patch, em que o POS envia o pedido completo com o patch.
+func handlePatch(sessions interfaces.OrderSessions, admin interfaces.RulePackAdmin) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req PatchRequest
+		if err := c.Bind(&req); err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Payload Inválido", err.Error())
+		}
+
+		patchBytes, _ := json.Marshal(req.Patch)
+		if response, ok := activeRulesVersion(c, admin, &req.Order); ok {
+			return response
+		}
+
+		result, err := sessions.Patch(c.Request().Context(), req.Order, patchBytes, parseETag(c.Request().Header.Get("If-Match")))
+		if err != nil {
+			return orderError(c, err)
+		}
+
+		result.StateFragment["tenantId"] = c.Request().Header.Get("X-Tenant-ID")
+
+		c.Response().Header().Set("ETag", formatETag(result.Revision))
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func orderError(c echo.Context, err error) error {
+	if response, ok := authError(c, err); ok {
+		return response
+	}
+	var precondition *domain.PreconditionError
+	var forbidden *domain.ForbiddenPatchError
+	switch {
+	case errors.As(err, &precondition):
+		current := precondition.Current
+		extensions := map[string]interface{}{"revision": current.Revision, "order": current.Order}
+		if current.Result != nil {
+			extensions["stateFragment"] = current.Result.StateFragment
+		}
+		c.Response().Header().Set("ETag", formatETag(current.Revision))
+		return errorRFC7807Ext(c, http.StatusPreconditionFailed, "Revisão Desatualizada", err.Error(), extensions)
+	case errors.As(err, &forbidden):
+		return errorRFC7807Ext(c, http.StatusForbidden, "Operações Não Permitidas", err.Error(), map[string]interface{}{
+			"violations": forbidden.Violations,
+		})
+	case errors.Is(err, domain.ErrConcurrentUpdate):
+		return errorRFC7807(c, http.StatusConflict, "Alteração Concorrente", err.Error())
+	case errors.Is(err, domain.ErrOrderNotFound):
+		return errorRFC7807(c, http.StatusNotFound, "Pedido Não Encontrado", "o pedido não existe ou a sessão expirou")
+	case errors.Is(err, domain.ErrInvalidPatch):
+		return errorRFC7807(c, http.StatusUnprocessableEntity, "Erro no Patch", err.Error())
+	default:
+		return errorRFC7807(c, http.StatusInternalServerError, "Erro de Execução", err.Error())
+	}
+}
+
+// parseETag extrai a revisão de um cabeçalho If-Match ("abc", W/"abc" ou *).
+func parseETag(header string) string {
+	header = strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	return strings.Trim(header, `"`)
+}
+
+func formatETag(revision string) string {
+	return `"` + revision + `"`
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/labstack/echo/v4"
+)
+
+func registerReceiptRoutes(e *echo.Echo, receipts interfaces.Receipts) {
+	e.GET("/sales/:id/receipt", handleReceipt(receipts))
+}
+
+// handleReceipt devolve o talão da venda no formato ?format=: text (omissão, 48 colunas),
+// escpos (bytes para impressoras térmicas) ou pdf (fatura A4).
+func handleReceipt(receipts interfaces.Receipts) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format := domain.ReceiptFormat(c.QueryParam("format"))
+		if format == "" {
+			format = domain.ReceiptText
+		}
+		receipt, err := receipts.Render(c.Request().Context(), c.Param("id"), format)
+		switch {
+		case errors.Is(err, domain.ErrUnknownReceiptFormat):
+			return errorRFC7807(c, http.StatusBadRequest, "Formato Inválido", err.Error())
+		case errors.Is(err, domain.ErrSaleNotFound):
+			return errorRFC7807(c, http.StatusNotFound, "Venda Inexistente", err.Error())
+		case errors.Is(err, fs.ErrNotExist):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Dados do Emissor em Falta", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Gerar Talão", err.Error())
+		}
+		disposition := "inline"
+		if format == domain.ReceiptESCPOS {
+			disposition = "attachment"
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, disposition+`; filename="`+receipt.Filename+`"`)
+		return c.Blob(http.StatusOK, receipt.ContentType, receipt.Body)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+func registerSalesRoutes(e *echo.Echo, svc interfaces.EngineFacade, admin interfaces.RulePackAdmin, authz interfaces.Authorizer, sales interfaces.SalesRepository, returns interfaces.SaleReturns, voids interfaces.SaleVoids, series domain.SeriesCatalog, signer interfaces.DocumentSigner, idem echo.MiddlewareFunc) {
+	e.POST("/sales", handleSale(svc, admin, authz, sales, series, signer), idem)
+	e.GET("/sales", handleListSales(sales))
+	e.GET("/sales/:id", handleGetSale(sales))
+	e.POST("/sales/:id/returns", handleReturn(returns), idem)
+	e.POST("/sales/:id/void", handleVoid(voids), idem)
+}
+
+// handleSale grava a venda calculada pelo motor; o pedido enviado pelo cliente serve
+// apenas de entrada do cálculo. O documento (?documentType=FT, omissão, ou FR) é numerado
+// na série da loja e terminal em X-Store-ID e X-Terminal-ID e, com signer, assinado. O
+// desconto tem de estar dentro do limite do utilizador autenticado.
+func handleSale(svc interfaces.EngineFacade, admin interfaces.RulePackAdmin, authz interfaces.Authorizer, sales interfaces.SalesRepository, series domain.SeriesCatalog, signer interfaces.DocumentSigner) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		docType := domain.DocumentType(c.QueryParam("documentType"))
+		if docType == "" {
+			docType = domain.DocumentInvoice
+		}
+		if docType == domain.DocumentCreditNote {
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Tipo de Documento Inválido", "uma nota de crédito é emitida a partir da venda original, em POST /sales/{id}/returns")
+		}
+		doc, err := series.Document(docType, c.Request().Header.Get("X-Store-ID"), c.Request().Header.Get("X-Terminal-ID"))
+		if err != nil {
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Série Inexistente", err.Error())
+		}
+
+		var order domain.Order
+		if err := c.Bind(&order); err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Venda Inválida", err.Error())
+		}
+		if response, ok := activeRulesVersion(c, admin, &order); ok {
+			return response
+		}
+		if err := usecase.AuthorizeDiscount(c.Request().Context(), authz, order); err != nil {
+			if response, ok := authError(c, err); ok {
+				return response
+			}
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro de Autorização", err.Error())
+		}
+
+		result, err := svc.RunEngine(c.Request().Context(), order, order.RulesVersion)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Versão de Regras Inexistente", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro no Motor", err.Error())
+		}
+		if len(result.GuardsHit) > 0 {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"type":   "https://dolphin.com/err/guard-violation",
+				"title":  "Venda Bloqueada por Guardas",
+				"status": 403,
+				"detail": result.GuardsHit[0].Context,
+			})
+		}
+
+		sale, err := usecase.NewSale(result, c.Request().Header.Get("X-Tenant-ID"), time.Now())
+		if err != nil {
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro no Motor", err.Error())
+		}
+		sale.Document = &doc
+		if sale, err = sales.Issue(c.Request().Context(), sale, signer); err != nil {
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Gravar Venda", err.Error())
+		}
+		return c.JSON(http.StatusCreated, sale)
+	}
+}
+
+// handleReturn emite a nota de crédito de uma devolução, total ou parcial, da venda. A
+// nota é numerada na série NC da loja e terminal do pedido (omissão: os da venda).
+func handleReturn(returns interfaces.SaleReturns) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req domain.ReturnRequest
+		if err := c.Bind(&req); err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Devolução Inválida", err.Error())
+		}
+		req.StoreID = c.Request().Header.Get("X-Store-ID")
+		req.TerminalID = c.Request().Header.Get("X-Terminal-ID")
+
+		note, err := returns.Return(c.Request().Context(), c.Param("id"), req)
+		switch {
+		case errors.Is(err, domain.ErrSaleNotFound):
+			return errorRFC7807(c, http.StatusNotFound, "Venda Inexistente", err.Error())
+		case errors.Is(err, domain.ErrSaleVoided):
+			return errorRFC7807(c, http.StatusConflict, "Venda Anulada", err.Error())
+		case errors.Is(err, domain.ErrInvalidReturn):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Devolução Inválida", err.Error())
+		case errors.Is(err, domain.ErrOverReturn):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Quantidade Devolvida Excedida", err.Error())
+		case errors.Is(err, domain.ErrUnknownSeries):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Série Inexistente", err.Error())
+		case errors.Is(err, fs.ErrNotExist):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Versão de Regras Inexistente", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Emitir Nota de Crédito", err.Error())
+		}
+		c.Response().Header().Set(echo.HeaderLocation, "/sales/"+note.ID)
+		return c.JSON(http.StatusCreated, note)
+	}
+}
+
+// handleVoid anula a venda em nome do utilizador autenticado, se a política lhe der a
+// permissão sales.void. A venda continua gravada, com o documento e a assinatura.
+func handleVoid(voids interfaces.SaleVoids) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Anulação Inválida", err.Error())
+		}
+		sale, err := voids.Void(c.Request().Context(), c.Param("id"), req.Reason)
+		if response, ok := authError(c, err); ok {
+			return response
+		}
+		switch {
+		case errors.Is(err, domain.ErrSaleNotFound):
+			return errorRFC7807(c, http.StatusNotFound, "Venda Inexistente", err.Error())
+		case errors.Is(err, domain.ErrSaleVoided):
+			return errorRFC7807(c, http.StatusConflict, "Venda Anulada", err.Error())
+		case errors.Is(err, domain.ErrInvalidVoid):
+			return errorRFC7807(c, http.StatusUnprocessableEntity, "Anulação Inválida", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Anular Venda", err.Error())
+		}
+		return c.JSON(http.StatusOK, sale)
+	}
+}
+
+func handleGetSale(sales interfaces.SalesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sale, err := sales.Get(c.Request().Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, domain.ErrSaleNotFound):
+			return errorRFC7807(c, http.StatusNotFound, "Venda Inexistente", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Ler Venda", err.Error())
+		}
+		return c.JSON(http.StatusOK, sale)
+	}
+}
+
+// handleListSales devolve uma página de vendas; a página seguinte pede-se repetindo os
+// mesmos filtros com cursor=nextCursor.
+func handleListSales(sales interfaces.SalesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		q, err := parseSalesQuery(c)
+		if err != nil {
+			return errorRFC7807(c, http.StatusBadRequest, "Consulta Inválida", err.Error())
+		}
+		page, err := sales.Query(c.Request().Context(), q)
+		switch {
+		case errors.Is(err, domain.ErrInvalidSalesQuery):
+			return errorRFC7807(c, http.StatusBadRequest, "Consulta Inválida", err.Error())
+		case err != nil:
+			return errorRFC7807(c, http.StatusInternalServerError, "Erro ao Ler Vendas", err.Error())
+		}
+		return c.JSON(http.StatusOK, page)
+	}
+}
+
+func parseSalesQuery(c echo.Context) (domain.SalesQuery, error) {
+	q := domain.SalesQuery{
+		Currency:       c.QueryParam("currency"),
+		TenantID:       c.QueryParam("tenant"),
+		SKU:            c.QueryParam("sku"),
+		RulesVersion:   c.QueryParam("rulesVersion"),
+		OriginalSaleID: c.QueryParam("originalSaleId"),
+		Status:         c.QueryParam("status"),
+		Sort:           c.QueryParam("sort"),
+		Cursor:         c.QueryParam("cursor"),
+	}
+	var err error
+	if q.From, err = parseSalesDate(c, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseSalesDate(c, "to"); err != nil {
+		return q, err
+	}
+	if q.MinTotal, err = parseSalesTotal(c, "minTotal"); err != nil {
+		return q, err
+	}
+	if q.MaxTotal, err = parseSalesTotal(c, "maxTotal"); err != nil {
+		return q, err
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("limit deve ser um inteiro positivo: %q", raw)
+		}
+	}
+	return q, nil
+}
+
+// parseSalesDate aceita RFC 3339 ou só a data (AAAA-MM-DD, à meia-noite UTC). Com "to"
+// só com a data, o dia indicado fica incluído.
+func parseSalesDate(c echo.Context, name string) (*time.Time, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s deve ser RFC 3339 ou AAAA-MM-DD: %q", name, raw)
+	}
+	if name == "to" {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func parseSalesTotal(c echo.Context, name string) (*float64, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s deve ser numérico: %q", name, raw)
+	}
+	return &v, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/pkg/engine"
+	"github.com/Victor-armando18/service-commercial/pkg/expr"
+)
+
+// runDiffCommand compara duas versões: regras adicionadas, removidas ou movidas de fase,
+// alterações estruturais da lógica e das mensagens das guardas. Com -orders executa
+// também os pedidos de exemplo nas duas versões e resume o impacto monetário.
+func runDiffCommand(loader *LocalFileLoader, args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	ordersPath := flags.String("orders", "", "ficheiro JSON com uma lista de pedidos de exemplo")
+	withTests := flags.Bool("tests", false, "usa também os pedidos dos casos de teste embutidos nos dois packs")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Println("uso: diff [-orders pedidos.json] [-tests] <versão-origem> <versão-destino>")
+		return 2
+	}
+
+	ctx := context.Background()
+	from, err := loader.Load(ctx, flags.Arg(0))
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	to, err := loader.Load(ctx, flags.Arg(1))
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	diff := engine.DiffPacks(from, to)
+	displayPackDiff(diff)
+
+	var orders []engine.Order
+	if *ordersPath != "" {
+		raw, err := os.ReadFile(*ordersPath)
+		if err == nil {
+			err = json.Unmarshal(raw, &orders)
+		}
+		if err != nil {
+			fmt.Printf("\n❌ ERRO CRÍTICO: pedidos de exemplo: %v\n", err)
+			return 1
+		}
+	}
+	if *withTests {
+		for _, pack := range []*engine.RulePack{from, to} {
+			for _, tc := range pack.Tests {
+				order := tc.Order
+				order.ID = pack.Version + ": " + tc.Name
+				orders = append(orders, order)
+			}
+		}
+	}
+	if len(orders) > 0 {
+		service := engine.NewEngineService(loader, loader.Executor)
+		impacts, summary := engine.CompareOrders(ctx, service, from.Version, to.Version, orders)
+		displayImpact(impacts, summary)
+	}
+	return 0
+}
+
+func displayPackDiff(d engine.PackDiff) {
+	fmt.Printf("\n[DIFF %s → %s]\n", d.From, d.To)
+	if d.Empty() {
+		fmt.Println("   Sem diferenças nas regras.")
+		return
+	}
+
+	if len(d.Added) > 0 {
+		fmt.Printf("\n   ➕ Regras adicionadas (%d)\n", len(d.Added))
+		for _, r := range d.Added {
+			fmt.Printf("      %-24s [%s] %s\n", r.ID, r.Phase, describeRule(r))
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Printf("\n   ➖ Regras removidas (%d)\n", len(d.Removed))
+		for _, r := range d.Removed {
+			fmt.Printf("      %-24s [%s] %s\n", r.ID, r.Phase, describeRule(r))
+		}
+	}
+	if len(d.Moved) > 0 {
+		fmt.Printf("\n   🔀 Regras movidas de fase (%d)\n", len(d.Moved))
+		for _, m := range d.Moved {
+			fmt.Printf("      %-24s %s → %s\n", m.RuleID, m.From, m.To)
+		}
+	}
+	if len(d.LogicChanges) > 0 {
+		fmt.Printf("\n   ✏️  Lógica alterada (%d)\n", len(d.LogicChanges))
+		for _, c := range d.LogicChanges {
+			fmt.Printf("      %s\n", c.RuleID)
+			fmt.Printf("         antes:  %s\n", formatLogic(c.Before))
+			fmt.Printf("         depois: %s\n", formatLogic(c.After))
+			if len(c.Edits) == 1 && c.Edits[0].Path == "" {
+				continue // A regra foi reescrita por inteiro; antes/depois já o mostram
+			}
+			for _, e := range c.Edits {
+				path := e.Path
+				switch e.Kind {
+				case engine.EditAdded:
+					fmt.Printf("         + %s: %s\n", path, formatLogic(e.New))
+				case engine.EditRemoved:
+					fmt.Printf("         - %s: %s\n", path, formatLogic(e.Old))
+				default:
+					fmt.Printf("         ~ %s: %s → %s\n", path, formatLogic(e.Old), formatLogic(e.New))
+				}
+			}
+		}
+	}
+	if len(d.OutputChanges) > 0 {
+		fmt.Printf("\n   🎯 Destino alterado (%d)\n", len(d.OutputChanges))
+		for _, c := range d.OutputChanges {
+			fmt.Printf("      %-24s %s → %s\n", c.RuleID, c.From, c.To)
+		}
+	}
+	if len(d.GuardMessages) > 0 {
+		fmt.Printf("\n   🛡️  Mensagens de guarda alteradas (%d)\n", len(d.GuardMessages))
+		for _, c := range d.GuardMessages {
+			fmt.Printf("      %s\n         antes:  %q\n         depois: %q\n", c.RuleID, c.From, c.To)
+		}
+	}
+}
+
+func describeRule(r engine.RuleConfig) string {
+	if r.Phase == "guards" {
+		return fmt.Sprintf("%s ⇒ %q", formatLogic(r.Logic), r.ErrorMessage)
+	}
+	return fmt.Sprintf("%s = %s", r.OutputKey, formatLogic(r.Logic))
+}
+
+// formatLogic mostra a lógica como fórmula; se não for possível, como JSON.
+func formatLogic(logic interface{}) string {
+	if text, err := expr.Format(logic); err == nil {
+		return text
+	}
+	raw, _ := json.Marshal(logic)
+	return string(raw)
+}
+
+func displayImpact(impacts []engine.OrderImpact, summary []engine.CurrencyImpact) {
+	fmt.Println("\n[IMPACTO NOS PEDIDOS DE EXEMPLO]")
+	for _, i := range impacts {
+		if i.Err != "" {
+			fmt.Printf("   ❌ %-40s erro: %s\n", i.OrderID, i.Err)
+			continue
+		}
+		marker := "  "
+		if i.Changed() {
+			marker = "⚠️"
+		}
+		fmt.Printf("   %s %-40s %s %12.2f → %12.2f (%+.2f)\n", marker, i.OrderID, i.Currency, i.Before, i.After, i.Delta)
+		if strings.Join(i.GuardsBefore, ",") != strings.Join(i.GuardsAfter, ",") {
+			fmt.Printf("      guardas: %v → %v\n", i.GuardsBefore, i.GuardsAfter)
+		}
+	}
+
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	for _, s := range summary {
+		pct := 0.0
+		if s.Before != 0 {
+			pct = s.Delta / s.Before * 100
+		}
+		fmt.Printf("   %s: %d pedido(s), %d alterado(s), total %.2f → %.2f (%+.2f, %+.2f%%)\n",
+			s.Currency, s.Orders, s.Changed, s.Before, s.After, s.Delta, pct)
+	}
+	fmt.Println(strings.Repeat("=", 60))
+}
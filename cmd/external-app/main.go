@@ -9,21 +9,46 @@ import (
 	"strings"
 
 	"github.com/Victor-armando18/service-commercial/pkg/engine"
+	"github.com/Victor-armando18/service-commercial/pkg/packfmt"
 )
 
 type LocalFileLoader struct {
 	BasePath string
+	Executor *engine.JsonLogicExecutor // Valida os operadores customizados de cada pack lido
 }
 
 func (l *LocalFileLoader) Load(ctx context.Context, version string) (*engine.RulePack, error) {
-	path := filepath.Join(l.BasePath, fmt.Sprintf("%s_rules.json", version))
+	var path string
+	for _, ext := range packfmt.Extensions {
+		candidate := filepath.Join(l.BasePath, version+"_rules"+ext)
+		if _, err := os.Stat(candidate); err == nil {
+			path = candidate
+			break
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("ficheiro de regras não encontrado [%s]", filepath.Join(l.BasePath, version+"_rules.json"))
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("ficheiro de regras não encontrado [%s]: %w", path, err)
 	}
 
+	doc, err := packfmt.ToJSON(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao parsear regras [%s]: %w", path, err)
+	}
 	var pack engine.RulePack
-	if err := json.Unmarshal(data, &pack); err != nil {
+	if err := packfmt.DecodeStrict(doc, &pack); err != nil {
+		return nil, fmt.Errorf("erro ao parsear JSON de regras: %w", err)
+	}
+	if err := engine.CompileExpressions(&pack); err != nil {
+		return nil, err
+	}
+	if err := l.Executor.ValidatePack(&pack); err != nil {
+		return nil, err
+	}
+	if pack.ContentHash, err = engine.ContentHash(doc); err != nil {
 		return nil, fmt.Errorf("erro ao parsear JSON de regras: %w", err)
 	}
 
@@ -35,8 +60,37 @@ func main() {
 	fmt.Println("   RULE ENGINE CLI - DIAGNOSTIC TOOL")
 	fmt.Println(strings.Repeat("=", 60))
 
-	loader := &LocalFileLoader{BasePath: "data/rules"}
 	executor := engine.NewJsonLogicExecutor()
+	loader := &LocalFileLoader{BasePath: "data/rules", Executor: executor}
+
+	// Subcomando "test [versões...]": valida os casos de teste embutidos nos packs
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTestCommand(loader, os.Args[2:]))
+	}
+
+	// Subcomandos "keygen <prefixo>" e "sign -key <chave> <packs...>": assinatura de packs
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		os.Exit(runKeygenCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sign" {
+		os.Exit(runSignCommand(os.Args[2:]))
+	}
+
+	// Subcomando "verify-chain -key <chave>": numeração e assinaturas das vendas gravadas
+	if len(os.Args) > 1 && os.Args[1] == "verify-chain" {
+		os.Exit(runVerifyChainCommand(os.Args[2:]))
+	}
+
+	// Subcomando "saft -from <data> -to <data>": exportação SAF-T (AO) das vendas gravadas
+	if len(os.Args) > 1 && os.Args[1] == "saft" {
+		os.Exit(runSAFTCommand(os.Args[2:]))
+	}
+
+	// Subcomando "diff [-orders f] [-tests] <origem> <destino>": comparação semântica de versões
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiffCommand(loader, os.Args[2:]))
+	}
+
 	service := engine.NewEngineService(loader, executor)
 
 	// Exemplo de pedido para teste da v1.2
@@ -89,6 +143,7 @@ func displayExecutionSummary(res *engine.EngineResult) {
 	fmt.Printf("   Status:      %s\n", map[bool]string{true: "BLOQUEADO", false: "APROVADO"}[len(res.GuardsHit) > 0])
 	fmt.Printf("   Delta:       %v (Alterações feitas pelo servidor)\n", res.ServerDelta)
 	fmt.Printf("   Versão Rule: %s\n", res.RulesVersion)
+	fmt.Printf("   Hash Rule:   %s\n", res.RulesHash)
 
 	fmt.Println(strings.Repeat("=", 60))
 }
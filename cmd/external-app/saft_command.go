@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/data"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+)
+
+// runSAFTCommand grava o SAF-T (AO) do período indicado; o servidor tem de estar parado
+// (ou usar uma cópia de data-dir), como em verify-chain.
+func runSAFTCommand(args []string) int {
+	flags := flag.NewFlagSet("saft", flag.ContinueOnError)
+	from := flags.String("from", "", "primeiro dia do período (AAAA-MM-DD)")
+	to := flags.String("to", "", "último dia do período, inclusivo (AAAA-MM-DD)")
+	dataDir := flags.String("data-dir", "data/db", "diretório de dados do servidor")
+	store := flags.String("store", "file", "repositório de vendas: file ou kv")
+	xsd := flags.String("xsd", "", "XSD do SAF-T (AO) (omissão: o XSD embutido)")
+	skipValidation := flags.Bool("skip-validation", false, "não valida o ficheiro contra o XSD")
+	out := flags.String("out", "", "ficheiro de saída (omissão: SAFT_AO_<de>_<até>.xml)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	start, err := time.Parse(time.DateOnly, *from)
+	end, err2 := time.Parse(time.DateOnly, *to)
+	if err != nil || err2 != nil || (*store != "file" && *store != "kv") {
+		fmt.Println("uso: saft -from AAAA-MM-DD -to AAAA-MM-DD [-data-dir data/db] [-store file|kv] [-xsd ficheiro.xsd | -skip-validation] [-out saft.xml]")
+		return 2
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("SAFT_AO_%s_%s.xml", start.Format("20060102"), end.Format("20060102"))
+	}
+
+	var sales interfaces.SalesRepository
+	if *store == "kv" {
+		sales, err = infrastructure.NewBoltSalesRepository(*dataDir)
+	} else {
+		sales, err = infrastructure.NewFileSalesRepository(*dataDir)
+	}
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	defer sales.Close()
+
+	var validator interfaces.XMLValidator
+	if !*skipValidation {
+		validator = infrastructure.NewXMLLintValidatorFS(data.SAFTSchema, "saft/SAFTAO1.01_01.xsd")
+		if *xsd != "" {
+			validator = infrastructure.NewXMLLintValidator(*xsd)
+		}
+	}
+	exporter := usecase.NewSAFTService(sales, infrastructure.NewFileMasterData(*dataDir), validator)
+	export, err := exporter.Export(context.Background(), start, end.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*out, export.XML, 0o644); err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n[SAF-T] %d documento(s) de %s a %s\n", export.Documents, *from, *to)
+	if !export.SchemaValidated {
+		fmt.Println("   ⚠️  -skip-validation: o ficheiro não foi validado contra o XSD.")
+	}
+	fmt.Printf("   ✅ %s\n", *out)
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Victor-armando18/service-commercial/pkg/rulesign"
+)
+
+// runKeygenCommand gera um par de chaves Ed25519 em <prefixo>.pub.pem e <prefixo>.key.pem.
+func runKeygenCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("uso: keygen <prefixo>")
+		return 2
+	}
+	prefix := args[0]
+
+	pub, priv, err := rulesign.GenerateKey()
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	pubPEM, err := rulesign.MarshalPublicKey(pub)
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	privPEM, err := rulesign.MarshalPrivateKey(priv)
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	// A chave privada só deve ser legível pelo dono
+	if err := os.WriteFile(prefix+".key.pem", privPEM, 0600); err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(prefix+".pub.pem", pubPEM, 0644); err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("   ✅ Chaves geradas (key_id %s): %s.pub.pem, %s.key.pem\n", rulesign.KeyID(pub), prefix, prefix)
+	return 0
+}
+
+// runSignCommand grava "<pack>.sig" ao lado de cada pack indicado.
+func runSignCommand(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "chave privada PEM usada para assinar")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyPath == "" || flags.NArg() == 0 {
+		fmt.Println("uso: sign -key <chave.key.pem> <pack_rules.json>...")
+		return 2
+	}
+
+	raw, err := os.ReadFile(*keyPath)
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	priv, err := rulesign.ParsePrivateKey(raw)
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, path := range flags.Args() {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			var sig []byte
+			if sig, err = rulesign.Sign(priv, content); err == nil {
+				err = os.WriteFile(path+rulesign.SignatureSuffix, sig, 0644)
+			}
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("   ❌ %s: %v\n", path, err)
+			continue
+		}
+		fmt.Printf("   ✅ %s%s\n", path, rulesign.SignatureSuffix)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
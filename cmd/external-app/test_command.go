@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/pkg/engine"
+	"github.com/Victor-armando18/service-commercial/pkg/packfmt"
+)
+
+// runTestCommand executa os casos de teste embutidos nos packs indicados
+// (ou em todos os de BasePath) e devolve o exit code: 0 se todos passarem.
+func runTestCommand(loader *LocalFileLoader, versions []string) int {
+	if len(versions) == 0 {
+		found, err := listVersions(loader.BasePath)
+		if err != nil {
+			fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+			return 1
+		}
+		versions = found
+	}
+
+	service := engine.NewEngineService(loader, loader.Executor)
+	ctx := context.Background()
+	var passed, failed int
+
+	for _, version := range versions {
+		pack, err := loader.Load(ctx, version)
+		if err != nil {
+			fmt.Printf("\n❌ %s: %v\n", version, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("\n[%s] %d caso(s)\n", pack.Version, len(pack.Tests))
+		if len(pack.Tests) == 0 {
+			fmt.Println("   ⚠️  Pack sem casos de teste.")
+		}
+
+		for _, outcome := range engine.RunPackTests(ctx, service, pack) {
+			switch {
+			case outcome.Err != nil:
+				failed++
+				fmt.Printf("   ❌ %s\n      erro: %v\n", outcome.Name, outcome.Err)
+			case !outcome.Pass:
+				failed++
+				fmt.Printf("   ❌ %s\n", outcome.Name)
+				for _, d := range outcome.Diffs {
+					fmt.Printf("      %s\n", d)
+				}
+			default:
+				passed++
+				fmt.Printf("   ✅ %s\n", outcome.Name)
+			}
+		}
+	}
+
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Printf("   Resultado: %d passou, %d falhou\n", passed, failed)
+	fmt.Println(strings.Repeat("=", 60))
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func listVersions(basePath string) ([]string, error) {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, ext := range packfmt.Extensions {
+		files, err := filepath.Glob(filepath.Join(basePath, "*_rules"+ext))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			version := strings.TrimSuffix(filepath.Base(f), "_rules"+ext)
+			if !seen[version] {
+				seen[version] = true
+				versions = append(versions, version)
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
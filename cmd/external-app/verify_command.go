@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/internal/usecase"
+)
+
+// runVerifyChainCommand percorre as vendas gravadas e confirma a numeração e a cadeia
+// de assinaturas de cada série; devolve 0 se não houver quebras.
+func runVerifyChainCommand(args []string) int {
+	flags := flag.NewFlagSet("verify-chain", flag.ContinueOnError)
+	dataDir := flags.String("data-dir", "data/db", "diretório de dados do servidor")
+	store := flags.String("store", "file", "repositório de vendas: file ou kv")
+	keyPath := flags.String("key", "", "chave pública RSA (PEM) do emissor")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *keyPath == "" || (*store != "file" && *store != "kv") {
+		fmt.Println("uso: verify-chain -key <chave.pub.pem> [-data-dir data/db] [-store file|kv]")
+		return 2
+	}
+
+	verifier, err := infrastructure.LoadDocumentVerifier(*keyPath)
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	var sales interfaces.SalesRepository
+	if *store == "kv" {
+		sales, err = infrastructure.NewBoltSalesRepository(*dataDir)
+	} else {
+		sales, err = infrastructure.NewFileSalesRepository(*dataDir)
+	}
+	if errors.Is(err, domain.ErrSalesLocked) {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v (pare o servidor ou verifique uma cópia do diretório)\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+	defer sales.Close()
+	all, err := sales.All(context.Background())
+	if err != nil {
+		fmt.Printf("\n❌ ERRO CRÍTICO: %v\n", err)
+		return 1
+	}
+
+	documents := 0
+	for _, sale := range all {
+		if sale.Document != nil {
+			documents++
+		}
+	}
+	fmt.Printf("\n[VERIFICAÇÃO] %d documento(s) em %s\n", documents, *dataDir)
+
+	gaps := usecase.CheckSeries(all)
+	for _, gap := range gaps {
+		fmt.Printf("   ❌ série %s: em falta %v, repetidos %v\n", gap.Series, gap.Missing, gap.Repeated)
+	}
+	breaks := usecase.VerifySignatureChain(all, verifier)
+	for _, b := range breaks {
+		fmt.Printf("   ❌ %s (venda %s): %s\n", b.DocumentNo, b.SaleID, b.Reason)
+	}
+	if len(gaps) > 0 || len(breaks) > 0 {
+		return 1
+	}
+	fmt.Println("   ✅ Numeração e cadeia de assinaturas íntegras.")
+	return 0
+}
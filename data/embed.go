@@ -0,0 +1,18 @@
+// Package data expõe os RulePacks publicados e os esquemas embutidos no binário, para
+// que o servidor possa arrancar sem depender do diretório de trabalho.
+package data
+
+import "embed"
+
+//go:embed rules
+var RulePacks embed.FS
+
+// Schemas contém o JSON Schema publicado do formato dos RulePacks.
+//
+//go:embed schema/*.schema.json
+var Schemas embed.FS
+
+// SAFTSchema contém o XSD do SAF-T (AO) usado para validar as exportações.
+//
+//go:embed saft/*.xsd
+var SAFTSchema embed.FS
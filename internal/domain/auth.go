@@ -0,0 +1,45 @@
+package domain
+
+import "fmt"
+
+var (
+	// Pedido sem credencial, ou com uma credencial desconhecida, numa operação que exige utilizador
+	ErrUnauthenticated = fmt.Errorf("authentication required")
+	// Utilizador sem a permissão exigida pela operação
+	ErrForbidden = fmt.Errorf("operation not permitted")
+)
+
+// Permission é uma ação da política de autorização (policies/authz.rego).
+type Permission string
+
+const (
+	// Aplicar desconto a um pedido, até ao limite max_discount_pct da política
+	PermissionApplyDiscount Permission = "order.discount.apply"
+	// Anular uma venda (POST /sales/{id}/void)
+	PermissionVoidSale Permission = "sales.void"
+	// Publicar RulePacks e ativá-los por tenant (POST /admin/rules, PUT /admin/tenants/{tenant}/rules)
+	PermissionManageRules Permission = "rules.manage"
+)
+
+// Restrição da política com o desconto máximo, em percentagem (15 = 15%)
+const ConstraintMaxDiscountPct = "max_discount_pct"
+
+// Caller é o utilizador autenticado que faz o pedido, com as permissões e os atributos
+// que a política de autorização avalia. Um Caller vazio é um pedido anónimo.
+type Caller struct {
+	UserID      string                 `json:"id"`
+	Permissions []Permission           `json:"permissions"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Anonymous indica que o pedido não trouxe credencial.
+func (c Caller) Anonymous() bool {
+	return c.UserID == ""
+}
+
+// Decision é a resposta da política de autorização a uma ação.
+type Decision struct {
+	Allowed bool
+	// Constraints são os limites da ação para o utilizador, ex: max_discount_pct
+	Constraints map[string]float64
+}
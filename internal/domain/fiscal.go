@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// Loja, terminal ou tipo de documento sem série configurada
+	ErrUnknownSeries = fmt.Errorf("no document series configured")
+	// Numeração de uma série com falhas ou números repetidos
+	ErrSeriesGap = fmt.Errorf("document series is not sequential")
+)
+
+// DocumentType é o tipo de documento fiscal emitido pela venda.
+type DocumentType string
+
+const (
+	DocumentInvoice        DocumentType = "FT" // Fatura
+	DocumentInvoiceReceipt DocumentType = "FR" // Fatura-recibo
+	DocumentCreditNote     DocumentType = "NC" // Nota de crédito
+)
+
+// Valid indica se o tipo é um dos documentos suportados.
+func (t DocumentType) Valid() bool {
+	return t == DocumentInvoice || t == DocumentInvoiceReceipt || t == DocumentCreditNote
+}
+
+// Loja e terminal usados quando o pedido não traz X-Store-ID ou X-Terminal-ID
+const (
+	DefaultStoreID    = "main"
+	DefaultTerminalID = "1"
+)
+
+// FiscalDocument identifica o documento fiscal de uma venda. O número é atribuído pelo
+// repositório de vendas na gravação: Number 0 indica um documento ainda por numerar.
+type FiscalDocument struct {
+	Type       DocumentType `json:"type"`
+	Series     string       `json:"series"`
+	Number     int64        `json:"number"`
+	StoreID    string       `json:"storeId"`
+	TerminalID string       `json:"terminalId"`
+	// No é o número legível do documento, ex: "FT MAIN-1/42"
+	No string `json:"no"`
+	// Hash é a assinatura RSA (base64) do documento, encadeada com a do anterior da série;
+	// vazio quando o servidor não tem chave de assinatura configurada
+	Hash string `json:"hash,omitempty"`
+	// HashControl identifica a versão da chave que assinou
+	HashControl string `json:"hashControl,omitempty"`
+}
+
+// SeriesKey identifica a sequência de numeração: cada tipo tem a sua dentro da série.
+func (d FiscalDocument) SeriesKey() string {
+	return string(d.Type) + " " + d.Series
+}
+
+// Numbered devolve o documento com o número indicado e o respetivo No.
+func (d FiscalDocument) Numbered(n int64) FiscalDocument {
+	d.Number = n
+	d.No = fmt.Sprintf("%s/%d", d.SeriesKey(), n)
+	return d
+}
+
+// SignatureMessage devolve o texto assinado de um documento: data, data e hora de
+// emissão, número, total bruto e o hash do documento anterior da série, separados por ";".
+func SignatureMessage(sale Sale, previousHash string) string {
+	var no string
+	if sale.Document != nil {
+		no = sale.Document.No
+	}
+	return fmt.Sprintf("%s;%s;%s;%.2f;%s", sale.CreatedAt.Format(time.DateOnly),
+		sale.CreatedAt.Format("2006-01-02T15:04:05"), no, sale.TotalValue, previousHash)
+}
+
+// DocumentSeries associa uma loja, um terminal e um tipo de documento a uma série.
+type DocumentSeries struct {
+	StoreID    string       `json:"store"`
+	TerminalID string       `json:"terminal"`
+	Type       DocumentType `json:"type"`
+	Series     string       `json:"series"`
+}
+
+// SeriesCatalog lista as séries configuradas. Um catálogo vazio atribui a cada loja e
+// terminal a série "<LOJA>-<TERMINAL>", igual para todos os tipos de documento.
+type SeriesCatalog struct {
+	Series []DocumentSeries `json:"series"`
+}
+
+// Document devolve o documento (por numerar) de um tipo emitido na loja e terminal indicados.
+func (c SeriesCatalog) Document(docType DocumentType, storeID, terminalID string) (FiscalDocument, error) {
+	if storeID == "" {
+		storeID = DefaultStoreID
+	}
+	if terminalID == "" {
+		terminalID = DefaultTerminalID
+	}
+	doc := FiscalDocument{Type: docType, StoreID: storeID, TerminalID: terminalID}
+	if !docType.Valid() {
+		return doc, fmt.Errorf("%w: tipo de documento %q desconhecido (use FT, FR ou NC)", ErrUnknownSeries, docType)
+	}
+	if len(c.Series) == 0 {
+		doc.Series = strings.ToUpper(storeID + "-" + terminalID)
+		if !ValidSeriesName(doc.Series) {
+			return doc, fmt.Errorf("%w: loja %q e terminal %q não formam um nome de série válido", ErrUnknownSeries, storeID, terminalID)
+		}
+		return doc, nil
+	}
+	for _, s := range c.Series {
+		if s.StoreID == storeID && s.TerminalID == terminalID && s.Type == docType {
+			doc.Series = s.Series
+			return doc, nil
+		}
+	}
+	return doc, fmt.Errorf("%w: %s na loja %q, terminal %q", ErrUnknownSeries, docType, storeID, terminalID)
+}
+
+// ValidSeriesName recusa nomes que tornariam o número do documento ambíguo ("/" e espaços).
+func ValidSeriesName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/ \t\r\n")
+}
+
+// SeriesGap descreve uma série cuja numeração não é 1, 2, 3, … sem falhas nem repetições.
+type SeriesGap struct {
+	Series   string  `json:"series"` // SeriesKey, ex: "FT MAIN-1"
+	Missing  []int64 `json:"missing,omitempty"`
+	Repeated []int64 `json:"repeated,omitempty"`
+}
+
+// ChainBreak é um documento cuja assinatura não confere com a cadeia da sua série.
+type ChainBreak struct {
+	SaleID     string `json:"saleId"`
+	DocumentNo string `json:"documentNo"`
+	Reason     string `json:"reason"`
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	// Chave de idempotência reutilizada com um pedido diferente
+	ErrIdempotencyKeyReused = fmt.Errorf("idempotency key reused with a different request")
+	// Pedido com a mesma chave ainda em execução quando a espera terminou
+	ErrIdempotencyInFlight = fmt.Errorf("request with the same idempotency key in progress")
+)
+
+// IdempotencyRecord é a resposta guardada para uma chave de idempotência de um tenant.
+// Fingerprint identifica o pedido original (método, caminho, query, cabeçalhos que mudam o
+// resultado, utilizador e corpo). Headers guarda os cabeçalhos da resposta que a repetição
+// devolve, como ETag e Location.
+type IdempotencyRecord struct {
+	Tenant      string            `json:"tenant"`
+	Key         string            `json:"key"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      int               `json:"status"`
+	ContentType string            `json:"contentType,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// ArgType identifica o tipo esperado de um argumento de operador customizado.
+type ArgType string
+
+const (
+	ArgAny    ArgType = "any"
+	ArgNumber ArgType = "number"
+	ArgString ArgType = "string"
+	ArgBool   ArgType = "bool"
+	ArgList   ArgType = "list"
+)
+
+// Variadic indica que o operador aceita um número ilimitado de argumentos.
+const Variadic = -1
+
+// OperatorFunc é a assinatura de um operador customizado. Os argumentos chegam
+// já avaliados e convertidos para os tipos declarados no OperatorSpec.
+type OperatorFunc func(ctx context.Context, args OperatorArgs) (interface{}, error)
+
+// OperatorSpec descreve um operador customizado: nome, aridade e tipos dos argumentos.
+type OperatorSpec struct {
+	Name     string
+	MinArgs  int
+	MaxArgs  int       // Variadic para sem limite
+	ArgTypes []ArgType // Tipo por posição; o último repete-se nos argumentos excedentes
+	Returns  ArgType   // Tipo do resultado, usado na validação estática (ArgAny se desconhecido)
+	Fn       OperatorFunc
+}
+
+// TypeOf devolve o tipo declarado para o argumento na posição i.
+func (s OperatorSpec) TypeOf(i int) ArgType {
+	if len(s.ArgTypes) == 0 {
+		return ArgAny
+	}
+	if i >= len(s.ArgTypes) {
+		return s.ArgTypes[len(s.ArgTypes)-1]
+	}
+	return s.ArgTypes[i]
+}
+
+// CheckArity valida o número de argumentos recebidos contra a aridade declarada.
+func (s OperatorSpec) CheckArity(n int) error {
+	if n < s.MinArgs || (s.MaxArgs != Variadic && n > s.MaxArgs) {
+		return fmt.Errorf("%w: espera %s argumentos, recebeu %d", ErrInvalidOperatorCall, s.arityLabel(), n)
+	}
+	return nil
+}
+
+func (s OperatorSpec) arityLabel() string {
+	switch {
+	case s.MaxArgs == Variadic:
+		return fmt.Sprintf("pelo menos %d", s.MinArgs)
+	case s.MinArgs == s.MaxArgs:
+		return fmt.Sprintf("%d", s.MinArgs)
+	}
+	return fmt.Sprintf("entre %d e %d", s.MinArgs, s.MaxArgs)
+}
+
+// OperatorError identifica uma falha originada num operador customizado
+// (chamada inválida ou erro devolvido pelo próprio operador).
+type OperatorError struct {
+	Operator string
+	Err      error
+}
+
+func (e *OperatorError) Error() string {
+	return fmt.Sprintf("operador %s: %v", e.Operator, e.Err)
+}
+
+func (e *OperatorError) Unwrap() error {
+	return e.Err
+}
+
+// OperatorArgs são os argumentos já tipados entregues a um OperatorFunc.
+// Os acessores devolvem o valor zero quando o argumento opcional não foi passado.
+type OperatorArgs []interface{}
+
+func (a OperatorArgs) Has(i int) bool {
+	return i < len(a) && a[i] != nil
+}
+
+func (a OperatorArgs) Number(i int) float64 {
+	if !a.Has(i) {
+		return 0
+	}
+	f, _ := a[i].(float64)
+	return f
+}
+
+func (a OperatorArgs) String(i int) string {
+	if !a.Has(i) {
+		return ""
+	}
+	s, _ := a[i].(string)
+	return s
+}
+
+func (a OperatorArgs) Bool(i int) bool {
+	if !a.Has(i) {
+		return false
+	}
+	b, _ := a[i].(bool)
+	return b
+}
+
+func (a OperatorArgs) List(i int) []interface{} {
+	if !a.Has(i) {
+		return nil
+	}
+	l, _ := a[i].([]interface{})
+	return l
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	// Patch RFC 6902 mal formado ou que não se aplica ao pedido
+	ErrInvalidPatch = fmt.Errorf("invalid order patch")
+	// If-Match ou operação test do patch não corresponde ao estado atual
+	ErrPreconditionFailed = fmt.Errorf("precondition failed")
+	// Sessão de pedido inexistente ou expirada
+	ErrOrderNotFound = fmt.Errorf("order not found")
+	// Patch sem If-Match que perdeu sempre a corrida com outras alterações à sessão
+	ErrConcurrentUpdate = fmt.Errorf("concurrent order update")
+)
+
+// Formatos de patch aceites em PATCH /orders/{id}.
+const (
+	PatchFormatJSON  = "application/json-patch+json"  // RFC 6902
+	PatchFormatMerge = "application/merge-patch+json" // RFC 7386
+)
+
+// OrderSession é um pedido em rascunho guardado no servidor: o pedido de entrada, o
+// último resultado do motor para ele e a revisão (ETag) desse resultado.
+type OrderSession struct {
+	OrderID   string        `json:"orderId"`
+	Revision  string        `json:"revision"`
+	Order     Order         `json:"order"`
+	Result    *EngineResult `json:"result"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// PreconditionError indica que o cliente editou uma revisão que já não é a atual;
+// Current é o estado sobre o qual deve refazer a alteração.
+type PreconditionError struct {
+	Reason  string
+	Current OrderSession
+}
+
+func (e *PreconditionError) Error() string {
+	return fmt.Sprintf("precondição falhou: %s", e.Reason)
+}
+
+func (e *PreconditionError) Unwrap() error {
+	return ErrPreconditionFailed
+}
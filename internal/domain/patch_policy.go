@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Patch com operações sobre campos do servidor ou fora dos limites do utilizador
+var ErrForbiddenPatch = fmt.Errorf("patch operation not allowed")
+
+// PatchPolicy define que caminhos (JSON Pointer) do pedido o cliente pode alterar;
+// os restantes (totais, impostos, versão das regras) pertencem ao servidor. O limite do
+// desconto de cada utilizador é o da política de autorização.
+type PatchPolicy struct {
+	Writable []WritablePath `json:"writable"`
+}
+
+// WritablePath é um caminho alterável pelo cliente. "*" corresponde a um segmento
+// qualquer, incluindo o "-" de fim de array.
+type WritablePath struct {
+	Path string   `json:"path"`
+	Ops  []string `json:"ops,omitempty"` // Vazio permite add, remove, replace, move e copy
+}
+
+// DefaultPatchPolicy deixa o cliente alterar itens, quantidades, moeda e desconto.
+func DefaultPatchPolicy() PatchPolicy {
+	return PatchPolicy{
+		Writable: []WritablePath{
+			{Path: "/items"},
+			{Path: "/items/*"},
+			{Path: "/items/*/sku", Ops: []string{"replace"}},
+			{Path: "/items/*/value", Ops: []string{"replace"}},
+			{Path: "/items/*/qty", Ops: []string{"replace"}},
+			{Path: "/currency", Ops: []string{"replace"}},
+			{Path: "/discountPercentage", Ops: []string{"add", "replace"}},
+		},
+	}
+}
+
+// Allows indica se a operação op pode escrever em path.
+func (p PatchPolicy) Allows(op, path string) bool {
+	for _, w := range p.Writable {
+		if !matchPointer(w.Path, path) {
+			continue
+		}
+		if len(w.Ops) == 0 {
+			return true
+		}
+		for _, allowed := range w.Ops {
+			if allowed == op {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchPointer(pattern, path string) bool {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != "*" && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PatchViolation é uma operação do patch recusada pela política.
+type PatchViolation struct {
+	Index  int    `json:"index"` // Posição da operação no patch (-1 em merge patches)
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ForbiddenPatchError lista todas as operações recusadas, não apenas a primeira.
+type ForbiddenPatchError struct {
+	Violations []PatchViolation
+}
+
+func (e *ForbiddenPatchError) Error() string {
+	paths := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		paths = append(paths, v.Op+" "+v.Path)
+	}
+	return fmt.Sprintf("operações não permitidas: %s", strings.Join(paths, ", "))
+}
+
+func (e *ForbiddenPatchError) Unwrap() error {
+	return ErrForbiddenPatch
+}
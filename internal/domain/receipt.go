@@ -0,0 +1,68 @@
+package domain
+
+import "fmt"
+
+// Formato de talão não suportado em GET /sales/{id}/receipt
+var ErrUnknownReceiptFormat = fmt.Errorf("unknown receipt format")
+
+// ReceiptFormat é o formato de impressão de um talão.
+type ReceiptFormat string
+
+const (
+	ReceiptText   ReceiptFormat = "text"   // Texto simples com 48 colunas
+	ReceiptESCPOS ReceiptFormat = "escpos" // Comandos ESC/POS para impressoras térmicas
+	ReceiptPDF    ReceiptFormat = "pdf"    // Fatura em A4
+)
+
+// Receipt é o conteúdo do talão de uma venda, independente do formato de impressão.
+// Os valores são os gravados na venda; numa nota de crédito são os creditados.
+type Receipt struct {
+	Company    Company
+	Title      string // Ex: "Fatura", "Nota de Crédito"
+	DocumentNo string // Vazio nas vendas anteriores à numeração; usa-se então o ID
+	SaleID     string
+	IssuedAt   string // Data e hora de emissão, "AAAA-MM-DD hh:mm"
+	Currency   string
+	Lines      []ReceiptLine
+	// Gross é a soma dos itens antes do desconto e Discount a diferença para a base tributável
+	Gross              float64
+	DiscountPercentage float64
+	Discount           float64
+	BaseValue          float64
+	Taxes              []ReceiptTax
+	Total              float64
+	// Numa nota de crédito: o documento retificado e o motivo
+	OriginalDocumentNo string
+	ReturnReason       string
+	Void               *SaleVoid
+	// SignatureExcerpt é o extrato do hash impresso no documento (1.º, 11.º, 21.º e 31.º
+	// caracteres); vazio num documento não assinado
+	SignatureExcerpt string
+	// Certification é a menção ao programa certificado, ex: "Processado por programa válido n.º 1/AGT/2026"
+	Certification string
+	QRPayload     string
+}
+
+// ReceiptLine é um item do talão.
+type ReceiptLine struct {
+	SKU         string
+	Description string
+	Qty         int
+	UnitPrice   float64
+	Amount      float64
+}
+
+// ReceiptTax é um imposto de AppliedTaxes com a taxa efetiva sobre a base tributável.
+type ReceiptTax struct {
+	ID     string
+	Name   string
+	Rate   float64 // Percentagem: 14 = 14%
+	Amount float64
+}
+
+// RenderedReceipt é o talão já no formato pedido.
+type RenderedReceipt struct {
+	ContentType string
+	Filename    string
+	Body        []byte
+}
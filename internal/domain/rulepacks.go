@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/pkg/engine"
+)
+
+var (
+	// Versão já publicada; os packs publicados são imutáveis
+	ErrRulePackExists = fmt.Errorf("rule pack version already published")
+	// Versão inexistente no repositório de packs
+	ErrRulePackNotFound = fmt.Errorf("rule pack not found")
+	// Repositório sem escrita (ex: packs embutidos no binário)
+	ErrRulePacksReadOnly = fmt.Errorf("rule pack repository is read-only")
+)
+
+// versionPattern é o formato aceite para rótulos de versão (igual ao do JSON Schema);
+// impede também que uma versão enviada pela API escape do diretório de regras.
+var versionPattern = regexp.MustCompile(`^v[0-9A-Za-z._-]+$`)
+
+func ValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// RulePackUpload é uma nova versão submetida para publicação.
+type RulePackUpload struct {
+	Format    string // "json" ou "yaml"
+	Content   []byte
+	Signature []byte // Conteúdo do ficheiro .sig, se existir
+}
+
+// RulePackInfo resume uma versão disponível para a listagem da API de gestão.
+type RulePackInfo struct {
+	Version     string     `json:"version"`
+	Description string     `json:"description,omitempty"`
+	ContentHash string     `json:"contentHash"`
+	Rules       int        `json:"rules"`
+	PublishedBy string     `json:"publishedBy,omitempty"` // Vazio para packs instalados fora da API
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	ActiveFor   []string   `json:"activeFor,omitempty"` // Tenants com esta versão ativa
+}
+
+// RulePackDiff descreve as diferenças entre duas versões, regra a regra (por ID): a mesma
+// comparação semântica do comando diff (engine.DiffPacks), com o hash de cada versão.
+type RulePackDiff struct {
+	engine.PackDiff
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+}
+
+// Ações registadas no histórico de auditoria dos RulePacks.
+const (
+	AuditRulePackPublished = "rulepack.published"
+	AuditRulePackActivated = "rulepack.activated"
+)
+
+// AuditEntry regista quem alterou os RulePacks e quando.
+type AuditEntry struct {
+	At          time.Time `json:"at"`
+	Actor       string    `json:"actor"`
+	Action      string    `json:"action"`
+	Version     string    `json:"version"`
+	ContentHash string    `json:"contentHash,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Previous    string    `json:"previous,omitempty"` // Versão ativa anterior, na ativação
+}
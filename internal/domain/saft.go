@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var (
+	// Ficheiro SAF-T que não passa nas validações (estrutura, totais ou XSD)
+	ErrInvalidSAFT = fmt.Errorf("invalid SAF-T file")
+	// XSD ou validador indisponível: o ficheiro é gerado, mas não validado contra o esquema
+	ErrSchemaUnavailable = fmt.Errorf("XML schema validation unavailable")
+)
+
+// Versão do SAF-T (AO) gerado e o respetivo namespace
+const (
+	SAFTVersion   = "1.01_01"
+	SAFTNamespace = "urn:OECD:StandardAuditFile-Tax:AO_1.01_01"
+)
+
+// Company identifica o emissor e o programa de faturação no cabeçalho do SAF-T.
+type Company struct {
+	TaxID        string `json:"taxId"` // NIF
+	Name         string `json:"name"`
+	BusinessName string `json:"businessName,omitempty"`
+	Address      string `json:"address"`
+	City         string `json:"city"`
+	PostalCode   string `json:"postalCode,omitempty"`
+	Province     string `json:"province,omitempty"`
+	// Dados do programa certificado: número de validação da AGT e produtor
+	SoftwareValidationNumber string `json:"softwareValidationNumber"`
+	ProductCompanyTaxID      string `json:"productCompanyTaxId"`
+	ProductID                string `json:"productId"`
+	ProductVersion           string `json:"productVersion"`
+}
+
+// Product é um artigo de data/db/products.json.
+type Product struct {
+	SKU      string  `json:"sku"`
+	Name     string  `json:"name"`
+	Value    float64 `json:"value"`
+	Category string  `json:"category"`
+}
+
+// Tax é um imposto de data/db/taxs.json; ID é a chave usada em Order.AppliedTaxes.
+type Tax struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Rate float64 `json:"rate"` // Fração: 0.14 = 14%
+}
+
+// SAFTExport é o resultado de uma exportação.
+type SAFTExport struct {
+	XML       []byte
+	Documents int
+	// SchemaValidated é false quando a validação contra o XSD foi desativada (saft_skip_validation)
+	SchemaValidated bool
+}
+
+// Money é um valor monetário do SAF-T, sempre com duas casas decimais.
+type Money float64
+
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatFloat(math.Round(float64(m)*100)/100, 'f', 2, 64)), nil
+}
+
+// Decimal é uma quantidade ou preço unitário, com até seis casas decimais.
+type Decimal float64
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatFloat(math.Round(float64(d)*1e6)/1e6, 'f', -1, 64)), nil
+}
+
+// AuditFile é a raiz do SAF-T (AO). A ordem dos campos segue a das sequências do XSD.
+type AuditFile struct {
+	XMLName         xml.Name             `xml:"AuditFile"`
+	Xmlns           string               `xml:"xmlns,attr"`
+	Header          SAFTHeader           `xml:"Header"`
+	MasterFiles     SAFTMasterFiles      `xml:"MasterFiles"`
+	SourceDocuments *SAFTSourceDocuments `xml:"SourceDocuments,omitempty"`
+}
+
+type SAFTHeader struct {
+	AuditFileVersion         string      `xml:"AuditFileVersion"`
+	CompanyID                string      `xml:"CompanyID"`
+	TaxRegistrationNumber    string      `xml:"TaxRegistrationNumber"`
+	TaxAccountingBasis       string      `xml:"TaxAccountingBasis"` // F: faturação
+	CompanyName              string      `xml:"CompanyName"`
+	BusinessName             string      `xml:"BusinessName,omitempty"`
+	CompanyAddress           SAFTAddress `xml:"CompanyAddress"`
+	FiscalYear               int         `xml:"FiscalYear"`
+	StartDate                string      `xml:"StartDate"`
+	EndDate                  string      `xml:"EndDate"`
+	CurrencyCode             string      `xml:"CurrencyCode"`
+	DateCreated              string      `xml:"DateCreated"`
+	TaxEntity                string      `xml:"TaxEntity"`
+	ProductCompanyTaxID      string      `xml:"ProductCompanyTaxID"`
+	SoftwareValidationNumber string      `xml:"SoftwareValidationNumber"`
+	ProductID                string      `xml:"ProductID"`
+	ProductVersion           string      `xml:"ProductVersion"`
+}
+
+type SAFTAddress struct {
+	AddressDetail string `xml:"AddressDetail"`
+	City          string `xml:"City"`
+	PostalCode    string `xml:"PostalCode,omitempty"`
+	Province      string `xml:"Province,omitempty"`
+	Country       string `xml:"Country"`
+}
+
+type SAFTMasterFiles struct {
+	Customers []SAFTCustomer `xml:"Customer"`
+	Products  []SAFTProduct  `xml:"Product"`
+	TaxTable  *SAFTTaxTable  `xml:"TaxTable,omitempty"`
+}
+
+type SAFTCustomer struct {
+	CustomerID           string      `xml:"CustomerID"`
+	AccountID            string      `xml:"AccountID"`
+	CustomerTaxID        string      `xml:"CustomerTaxID"`
+	CompanyName          string      `xml:"CompanyName"`
+	BillingAddress       SAFTAddress `xml:"BillingAddress"`
+	SelfBillingIndicator int         `xml:"SelfBillingIndicator"`
+}
+
+type SAFTProduct struct {
+	ProductType        string `xml:"ProductType"` // P: produto, S: serviço
+	ProductCode        string `xml:"ProductCode"`
+	ProductGroup       string `xml:"ProductGroup,omitempty"`
+	ProductDescription string `xml:"ProductDescription"`
+	ProductNumberCode  string `xml:"ProductNumberCode"`
+}
+
+type SAFTTaxTable struct {
+	Entries []SAFTTaxTableEntry `xml:"TaxTableEntry"`
+}
+
+type SAFTTaxTableEntry struct {
+	TaxType          string  `xml:"TaxType"` // IVA, IS ou NS
+	TaxCountryRegion string  `xml:"TaxCountryRegion"`
+	TaxCode          string  `xml:"TaxCode"`
+	Description      string  `xml:"Description"`
+	TaxPercentage    Decimal `xml:"TaxPercentage"`
+}
+
+type SAFTSourceDocuments struct {
+	SalesInvoices SAFTSalesInvoices `xml:"SalesInvoices"`
+}
+
+type SAFTSalesInvoices struct {
+	NumberOfEntries int           `xml:"NumberOfEntries"`
+	TotalDebit      Money         `xml:"TotalDebit"`  // Soma de NetTotal das notas de crédito
+	TotalCredit     Money         `xml:"TotalCredit"` // Soma de NetTotal das faturas
+	Invoices        []SAFTInvoice `xml:"Invoice"`
+}
+
+type SAFTInvoice struct {
+	InvoiceNo       string             `xml:"InvoiceNo"`
+	DocumentStatus  SAFTDocumentStatus `xml:"DocumentStatus"`
+	Hash            string             `xml:"Hash"`
+	HashControl     string             `xml:"HashControl"`
+	Period          int                `xml:"Period"`
+	InvoiceDate     string             `xml:"InvoiceDate"`
+	InvoiceType     string             `xml:"InvoiceType"`
+	SpecialRegimes  SAFTSpecialRegimes `xml:"SpecialRegimes"`
+	SourceID        string             `xml:"SourceID"`
+	SystemEntryDate string             `xml:"SystemEntryDate"`
+	CustomerID      string             `xml:"CustomerID"`
+	Lines           []SAFTLine         `xml:"Line"`
+	DocumentTotals  SAFTDocumentTotals `xml:"DocumentTotals"`
+}
+
+type SAFTDocumentStatus struct {
+	InvoiceStatus     string `xml:"InvoiceStatus"` // N: normal, A: anulado
+	InvoiceStatusDate string `xml:"InvoiceStatusDate"`
+	Reason            string `xml:"Reason,omitempty"`
+	SourceID          string `xml:"SourceID"`
+	SourceBilling     string `xml:"SourceBilling"` // P: produzido no programa
+}
+
+type SAFTSpecialRegimes struct {
+	SelfBillingIndicator         int `xml:"SelfBillingIndicator"`
+	CashVATSchemeIndicator       int `xml:"CashVATSchemeIndicator"`
+	ThirdPartiesBillingIndicator int `xml:"ThirdPartiesBillingIndicator"`
+}
+
+type SAFTLine struct {
+	LineNumber         int     `xml:"LineNumber"`
+	ProductCode        string  `xml:"ProductCode"`
+	ProductDescription string  `xml:"ProductDescription"`
+	Quantity           Decimal `xml:"Quantity"`
+	UnitOfMeasure      string  `xml:"UnitOfMeasure"`
+	UnitPrice          Decimal `xml:"UnitPrice"`
+	TaxPointDate       string  `xml:"TaxPointDate"`
+	// References liga a linha de uma nota de crédito ao documento que retifica
+	References         []SAFTReference `xml:"References,omitempty"`
+	Description        string          `xml:"Description"`
+	DebitAmount        *Money          `xml:"DebitAmount,omitempty"`  // Notas de crédito
+	CreditAmount       *Money          `xml:"CreditAmount,omitempty"` // Faturas
+	Tax                SAFTTax         `xml:"Tax"`
+	TaxExemptionReason string          `xml:"TaxExemptionReason,omitempty"`
+	TaxExemptionCode   string          `xml:"TaxExemptionCode,omitempty"` // Código AGT do motivo (ex: M02)
+}
+
+type SAFTReference struct {
+	Reference string `xml:"Reference"` // Número do documento retificado
+	Reason    string `xml:"Reason,omitempty"`
+}
+
+type SAFTTax struct {
+	TaxType          string  `xml:"TaxType"`
+	TaxCountryRegion string  `xml:"TaxCountryRegion"`
+	TaxCode          string  `xml:"TaxCode"`
+	TaxPercentage    Decimal `xml:"TaxPercentage"`
+}
+
+type SAFTDocumentTotals struct {
+	TaxPayable Money `xml:"TaxPayable"`
+	NetTotal   Money `xml:"NetTotal"`
+	GrossTotal Money `xml:"GrossTotal"`
+}
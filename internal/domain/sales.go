@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// Venda inexistente no repositório
+	ErrSaleNotFound = fmt.Errorf("sale not found")
+	// Já existe uma venda com o mesmo ID; as vendas não são substituídas
+	ErrSaleExists = fmt.Errorf("sale already exists")
+	// Outro processo tem o repositório de vendas aberto
+	ErrSalesLocked = fmt.Errorf("sales repository locked by another process")
+	// Filtro, ordenação ou cursor inválido numa consulta de vendas
+	ErrInvalidSalesQuery = fmt.Errorf("invalid sales query")
+	// Devolução sem motivo, de artigos que não constam da venda ou de uma nota de crédito
+	ErrInvalidReturn = fmt.Errorf("invalid return")
+	// Devolução de mais unidades do que as vendidas e ainda não devolvidas
+	ErrOverReturn = fmt.Errorf("return exceeds sold quantity")
+	// Anulação sem motivo ou utilizador, ou de uma venda com notas de crédito por anular
+	ErrInvalidVoid = fmt.Errorf("invalid void")
+	// Venda já anulada: não pode voltar a ser anulada nem devolvida
+	ErrSaleVoided = fmt.Errorf("sale already voided")
+)
+
+// Sale é a venda gravada: o pedido tal como o motor o calculou (nunca o enviado pelo
+// cliente) e os metadados para a reproduzir e auditar. Os campos de Order ficam ao
+// primeiro nível do JSON, pelo que as vendas antigas, só com o pedido, continuam legíveis.
+type Sale struct {
+	Order
+	TenantID  string    `json:"tenantId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Document é o documento fiscal emitido (ausente nas vendas anteriores à numeração)
+	Document *FiscalDocument `json:"document,omitempty"`
+	// Revision é o ETag do estado calculado (ver EngineResult.Revision)
+	Revision string `json:"revision,omitempty"`
+	// ExecutionLogDigest é o SHA-256 do log de execução, para confirmar uma reexecução
+	ExecutionLogDigest string        `json:"executionLogDigest,omitempty"`
+	Guards             []GuardResult `json:"guards,omitempty"`
+	Warnings           []string      `json:"warnings,omitempty"`
+	// Numa nota de crédito: a venda e o documento corrigidos e o motivo da devolução
+	OriginalSaleID     string `json:"originalSaleId,omitempty"`
+	OriginalDocumentNo string `json:"originalDocumentNo,omitempty"`
+	ReturnReason       string `json:"returnReason,omitempty"`
+	// Void regista a anulação; a venda anulada continua gravada, com o mesmo documento
+	Void *SaleVoid `json:"void,omitempty"`
+}
+
+// SaleVoid indica quem anulou a venda, quando e porquê.
+type SaleVoid struct {
+	Reason string    `json:"reason"`
+	UserID string    `json:"userId"`
+	At     time.Time `json:"at"`
+}
+
+// ReturnRequest pede a devolução de parte de uma venda; sem itens, devolve tudo o que
+// ainda não foi devolvido.
+type ReturnRequest struct {
+	Items  []ReturnItem `json:"items"`
+	Reason string       `json:"reason"`
+	// Ponto de emissão da nota de crédito (omissão: o do documento original)
+	StoreID    string `json:"-"`
+	TerminalID string `json:"-"`
+}
+
+// ReturnItem é a quantidade devolvida de um SKU; se o SKU aparecer em várias linhas da
+// venda, as unidades são tiradas das linhas por ordem.
+type ReturnItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+// Estados de uma venda, para filtrar a consulta
+const (
+	SaleStatusActive = "active"
+	SaleStatusVoided = "voided"
+)
+
+// Ordenações aceites na consulta de vendas; o prefixo "-" inverte a ordem.
+const (
+	SortSalesByCreatedAt  = "createdAt"
+	SortSalesByTotalValue = "totalValue"
+	SortSalesByID         = "id"
+)
+
+// SalesQuery filtra e pagina as vendas. Os filtros vazios (ou nil) não se aplicam.
+type SalesQuery struct {
+	From         *time.Time // Inclusivo
+	To           *time.Time // Exclusivo
+	Currency     string
+	TenantID     string
+	SKU          string // Vendas com pelo menos um item deste SKU
+	RulesVersion string
+	// OriginalSaleID seleciona as notas de crédito de uma venda
+	OriginalSaleID string
+	Status         string // SaleStatusActive ou SaleStatusVoided
+	MinTotal       *float64
+	MaxTotal       *float64
+	Sort           string // Ex: "-createdAt" (omissão), "totalValue", "id"
+	Limit          int
+	Cursor         string // NextCursor da página anterior
+}
+
+// normalizeRulesVersion acrescenta o prefixo "v" como o motor, para que rulesVersion=1.2
+// encontre as vendas feitas com v1.2.
+func normalizeRulesVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+// SalesPage é uma página de resultados; NextCursor vazio indica a última página.
+type SalesPage struct {
+	Sales      []Sale `json:"sales"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Matches indica se a venda satisfaz os filtros (ignora ordenação e paginação).
+func (q SalesQuery) Matches(sale Sale) bool {
+	switch {
+	case q.From != nil && sale.CreatedAt.Before(*q.From),
+		q.To != nil && !sale.CreatedAt.Before(*q.To),
+		q.Currency != "" && sale.Currency != q.Currency,
+		q.TenantID != "" && sale.TenantID != q.TenantID,
+		q.RulesVersion != "" && normalizeRulesVersion(sale.RulesVersion) != normalizeRulesVersion(q.RulesVersion),
+		q.OriginalSaleID != "" && sale.OriginalSaleID != q.OriginalSaleID,
+		q.Status == SaleStatusActive && sale.Void != nil,
+		q.Status == SaleStatusVoided && sale.Void == nil,
+		q.MinTotal != nil && sale.TotalValue < *q.MinTotal,
+		q.MaxTotal != nil && sale.TotalValue > *q.MaxTotal:
+		return false
+	}
+	if q.SKU == "" {
+		return true
+	}
+	for _, item := range sale.Items {
+		if item.SKU == q.SKU {
+			return true
+		}
+	}
+	return false
+}
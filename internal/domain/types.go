@@ -3,6 +3,8 @@ package domain
 import (
 	"fmt"
 	"time"
+
+	"github.com/Victor-armando18/service-commercial/pkg/jsondiff"
 )
 
 type Order struct {
@@ -15,6 +17,7 @@ type Order struct {
 	DiscountPercentage float64            `json:"discountPercentage"`
 	TotalValue         float64            `json:"totalValue"`
 	RulesVersion       string             `json:"rulesVersion"`
+	RulesHash          string             `json:"rulesHash,omitempty"` // Hash do conteúdo do pack com que a venda foi calculada
 	CorrelationID      string             `json:"correlationId"`
 }
 
@@ -39,27 +42,77 @@ type EngineContext struct {
 
 // RulePackDefinition define a estrutura de um conjunto de regras carregado.
 type RulePackDefinition struct {
-	Version     string       `json:"version"`
-	Rules       []RuleConfig `json:"rules"`
-	Description string       `json:"description,omitempty"`
+	Schema      string         `json:"$schema,omitempty"` // Referência ao JSON Schema, usada pelos editores
+	Version     string         `json:"version"`
+	Rules       []RuleConfig   `json:"rules"`
+	Description string         `json:"description,omitempty"`
+	Tests       []RuleTestCase `json:"tests,omitempty"` // Exemplos verificados pelo comando "test" da CLI
+	ContentHash string         `json:"-"`               // Hash canónico do ficheiro, calculado pelo loader
+}
+
+// RuleTestCase é um pedido de exemplo com o StateFragment parcial e as guardas esperadas.
+type RuleTestCase struct {
+	Name           string                 `json:"name"`
+	Order          Order                  `json:"order"`
+	ExpectedState  map[string]interface{} `json:"expected_state"`
+	ExpectedGuards []string               `json:"expected_guards,omitempty"`
+}
+
+// ReloadReport resume uma recarga do diretório de RulePacks.
+type ReloadReport struct {
+	At      time.Time         `json:"at"`
+	Loaded  []string          `json:"loaded"`           // Versões novas ou alteradas, já ativas
+	Removed []string          `json:"removed"`          // Versões cujo ficheiro desapareceu
+	Errors  map[string]string `json:"errors,omitempty"` // Versões inválidas ou publicadas alteradas; mantêm o último pack bom
+}
+
+func (r *ReloadReport) AddError(version string, err error) {
+	if r.Errors == nil {
+		r.Errors = make(map[string]string)
+	}
+	r.Errors[version] = err.Error()
+}
+
+// Changed indica se a recarga alterou o conjunto de packs ativos.
+func (r ReloadReport) Changed() bool {
+	return len(r.Loaded) > 0 || len(r.Removed) > 0
 }
 
 type RuleConfig struct {
 	ID           string                 `json:"id"`
-	Phase        string                 `json:"phase"`      // Ex: "baseline", "allocation", "tax", "guards"
-	Logic        map[string]interface{} `json:"logic"`      // JsonLogic structure
-	OutputKey    string                 `json:"output_key"` // Onde armazenar o resultado (Ex: order.AppliedTaxes.VAT)
+	Phase        string                 `json:"phase"`          // Ex: "baseline", "allocation", "tax", "guards"
+	Logic        map[string]interface{} `json:"logic"`          // JsonLogic structure
+	Expr         string                 `json:"expr,omitempty"` // Alternativa textual a Logic, compilada pelo loader (pkg/expr)
+	OutputKey    string                 `json:"output_key"`     // Onde armazenar o resultado (Ex: order.AppliedTaxes.VAT)
 	ErrorMessage string                 `json:"error_message,omitempty"`
 }
 
 type EngineResult struct {
 	StateFragment map[string]interface{} `json:"stateFragment"`
-	ServerDelta   bool                   `json:"serverDelta"`
+	ServerDelta   bool                   `json:"serverDelta"` // Há alterações do servidor além das cosméticas
+	Changes       []FieldChange          `json:"changes"`     // Patch RFC 6902 do pedido do cliente para o StateFragment
 	RulesVersion  string                 `json:"rulesVersion"`
+	RulesHash     string                 `json:"rulesHash"`
+	Revision      string                 `json:"revision"` // ETag do estado calculado
 	ExecutionLog  []ExecutionStep        `json:"executionLog"`
 	GuardsHit     []GuardViolation       `json:"guardsHit"`
+	Guards        []GuardResult          `json:"guards"` // Todas as guardas avaliadas, incluindo as que passaram
+	Warnings      []string               `json:"warnings,omitempty"`
+}
+
+// RulesDrift assinala vendas gravadas com um conteúdo de pack diferente do que hoje
+// responde pela mesma versão: já não são reproduzíveis com as regras atuais.
+type RulesDrift struct {
+	Version     string   `json:"version"`
+	StoredHash  string   `json:"storedHash"`
+	CurrentHash string   `json:"currentHash"` // Vazio se a versão já não existir
+	SaleIDs     []string `json:"saleIds"`
 }
 
+// FieldChange é uma operação RFC 6902 com o valor enviado pelo cliente (clientValue) e,
+// em replace numéricos, a marca cosmetic quando a diferença está dentro da tolerância.
+type FieldChange = jsondiff.Operation
+
 type ExecutionStep struct {
 	Phase   string `json:"phase"`
 	RuleID  string `json:"ruleId"`
@@ -73,8 +126,18 @@ type GuardViolation struct {
 	Context string `json:"context"`
 }
 
+// GuardResult regista o resultado de uma guarda avaliada (Passed = não bloqueou).
+type GuardResult struct {
+	RuleID string `json:"ruleId"`
+	Passed bool   `json:"passed"`
+}
+
 // --- Constantes e Erros ---
 var (
 	// Erro definido no domínio, mas acessível via interfaces
 	ErrRuleExecutionFailed = fmt.Errorf("rule execution failed")
+	// Chamada a operador customizado com aridade ou tipos de argumento inválidos
+	ErrInvalidOperatorCall = fmt.Errorf("invalid operator call")
+	// RulePack rejeitado pela validação estática
+	ErrInvalidRulePack = fmt.Errorf("invalid rule pack")
 )
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic descreve um problema encontrado na validação estática de um RulePack.
+// Path segue a notação JSONPath relativa ao ficheiro do pack (ex: $.rules[0].logic.round[0]).
+type Diagnostic struct {
+	RuleID   string   `json:"ruleId,omitempty"`
+	Path     string   `json:"path"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s (%s): %s", d.Severity, d.RuleID, d.Path, d.Message)
+}
+
+// HasErrors indica se algum diagnóstico impede o uso do pack.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// RulePackValidationError é devolvido pelo loader quando um pack tem diagnósticos de erro.
+type RulePackValidationError struct {
+	Version     string
+	Diagnostics []Diagnostic
+}
+
+func (e *RulePackValidationError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			lines = append(lines, d.String())
+		}
+	}
+	return fmt.Sprintf("rulepack %s inválido: %s", e.Version, strings.Join(lines, "; "))
+}
+
+func (e *RulePackValidationError) Unwrap() error {
+	return ErrInvalidRulePack
+}
@@ -0,0 +1,74 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// Pacote Rego da política de autorização (policies/authz.rego)
+const authzQuery = "data.dolphin.authz"
+
+// RegoAuthorizer avalia a política de autorização em Rego: allow decide se o utilizador
+// pode fazer a ação e capabilities dá os limites (ex: max_discount_pct) que tem nela.
+type RegoAuthorizer struct {
+	query rego.PreparedEvalQuery
+}
+
+// NewRegoAuthorizer compila a política; um erro de sintaxe falha aqui, no arranque, e não
+// no primeiro pedido.
+func NewRegoAuthorizer(name, module string) (interfaces.Authorizer, error) {
+	query, err := rego.New(rego.Query(authzQuery), rego.Module(name, module)).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("política de autorização inválida em %s: %w", name, err)
+	}
+	return &RegoAuthorizer{query: query}, nil
+}
+
+// LoadRegoAuthorizer lê a política de autorização de um ficheiro .rego.
+func LoadRegoAuthorizer(path string) (interfaces.Authorizer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	return NewRegoAuthorizer(path, string(raw))
+}
+
+// authzResult é a parte do pacote avaliado que interessa à decisão.
+type authzResult struct {
+	Allow        bool `json:"allow"`
+	Capabilities map[string]struct {
+		Constraints map[string]float64 `json:"constraints"`
+	} `json:"capabilities"`
+}
+
+func (a *RegoAuthorizer) Authorize(ctx context.Context, caller domain.Caller, action domain.Permission) (domain.Decision, error) {
+	// A política lê as permissões como lista: um utilizador sem nenhuma tem uma lista vazia
+	if caller.Permissions == nil {
+		caller.Permissions = []domain.Permission{}
+	}
+	input := map[string]interface{}{"subject": caller, "action": action}
+	results, err := a.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return domain.Decision{}, fmt.Errorf("falha ao avaliar a política de autorização: %w", err)
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return domain.Decision{}, nil
+	}
+
+	// O resultado traz os números como json.Number; passa por JSON para os converter
+	raw, err := json.Marshal(results[0].Expressions[0].Value)
+	if err != nil {
+		return domain.Decision{}, err
+	}
+	var result authzResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return domain.Decision{}, fmt.Errorf("resultado inesperado da política de autorização: %w", err)
+	}
+	return domain.Decision{Allowed: result.Allow, Constraints: result.Capabilities[string(action)].Constraints}, nil
+}
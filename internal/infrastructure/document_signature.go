@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// RSADocumentSigner assina os documentos fiscais com RSA PKCS#1 v1.5 sobre SHA-1, o
+// algoritmo exigido aos programas de faturação certificados.
+type RSADocumentSigner struct {
+	key     *rsa.PrivateKey
+	version string
+}
+
+// LoadDocumentSigner lê a chave privada RSA (PEM, PKCS#1 ou PKCS#8) do emissor.
+func LoadDocumentSigner(path, version string) (interfaces.DocumentSigner, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler chave %s: %w", path, err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("chave %s inválida: sem bloco PEM", path)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, perr := x509.ParsePKCS8PrivateKey(block.Bytes)
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if perr != nil || !ok {
+			return nil, fmt.Errorf("chave %s inválida: não é uma chave privada RSA", path)
+		}
+		key = rsaKey
+	}
+	return &RSADocumentSigner{key: key, version: version}, nil
+}
+
+func (s *RSADocumentSigner) Sign(message string) (string, error) {
+	digest := sha1.Sum([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+func (s *RSADocumentSigner) KeyVersion() string {
+	return s.version
+}
+
+// RSADocumentVerifier confirma as assinaturas de um RSADocumentSigner.
+type RSADocumentVerifier struct {
+	key *rsa.PublicKey
+}
+
+// LoadDocumentVerifier lê a chave pública RSA (PEM, PKIX ou PKCS#1); uma chave privada
+// também serve, usando a parte pública.
+func LoadDocumentVerifier(path string) (interfaces.DocumentVerifier, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler chave %s: %w", path, err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("chave %s inválida: sem bloco PEM", path)
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return &RSADocumentVerifier{key: key}, nil
+	}
+	if parsed, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if key, ok := parsed.(*rsa.PublicKey); ok {
+			return &RSADocumentVerifier{key: key}, nil
+		}
+	}
+	signer, err := LoadDocumentSigner(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("chave %s inválida: não é uma chave RSA", path)
+	}
+	return &RSADocumentVerifier{key: &signer.(*RSADocumentSigner).key.PublicKey}, nil
+}
+
+func (v *RSADocumentVerifier) Verify(message, hash string) error {
+	sig, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return fmt.Errorf("hash inválido: %w", err)
+	}
+	digest := sha1.Sum([]byte(message))
+	return rsa.VerifyPKCS1v15(v.key, crypto.SHA1, digest[:], sig)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package infrastructure
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile só garante a exclusão entre processos em sistemas Unix; nos restantes
+// cria o ficheiro de lock sem o bloquear.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao abrir %s: %w", path, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// lockFile obtém um lock exclusivo (flock) sobre path, libertado pelo sistema se o
+// processo terminar; devolve domain.ErrSalesLocked se outro processo o tiver.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao abrir %s: %w", path, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrSalesLocked, path)
+		}
+		return nil, fmt.Errorf("falha ao bloquear %s: %w", path, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,82 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// LoadSeriesCatalog lê as séries de documentos de um ficheiro JSON. Cada loja, terminal e
+// tipo tem uma única série, e uma série não pode ser partilhada por duas lojas ou terminais:
+// os números deixariam de identificar o ponto de emissão.
+func LoadSeriesCatalog(path string) (domain.SeriesCatalog, error) {
+	var catalog domain.SeriesCatalog
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return catalog, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&catalog); err != nil {
+		return catalog, fmt.Errorf("séries inválidas em %s: %w", path, err)
+	}
+
+	points := make(map[string]bool)
+	owners := make(map[string]string)
+	for _, s := range catalog.Series {
+		if s.StoreID == "" || s.TerminalID == "" || !s.Type.Valid() || !domain.ValidSeriesName(s.Series) {
+			return catalog, fmt.Errorf("séries inválidas em %s: %+v (loja, terminal, tipo FT/FR/NC e série sem espaços nem \"/\")", path, s)
+		}
+		point := s.StoreID + "\x00" + s.TerminalID
+		if points[point+"\x00"+string(s.Type)] {
+			return catalog, fmt.Errorf("séries inválidas em %s: %s repetido na loja %q, terminal %q", path, s.Type, s.StoreID, s.TerminalID)
+		}
+		points[point+"\x00"+string(s.Type)] = true
+		if owner, ok := owners[s.Series]; ok && owner != point {
+			return catalog, fmt.Errorf("séries inválidas em %s: série %q usada por mais de um terminal", path, s.Series)
+		}
+		owners[s.Series] = point
+	}
+	return catalog, nil
+}
+
+// nextDocument numera o documento da venda a seguir a last (Issue) ou confirma que o
+// número já atribuído é o seguinte (Save), para que a série nunca tenha falhas. Com
+// signer, o documento numerado é assinado e encadeado com o hash de last.
+func nextDocument(sale domain.Sale, last domain.FiscalDocument, issue bool, signer interfaces.DocumentSigner) (domain.Sale, error) {
+	if sale.Document == nil {
+		if issue {
+			return sale, fmt.Errorf("%w: venda %s sem documento fiscal", domain.ErrUnknownSeries, sale.ID)
+		}
+		return sale, nil
+	}
+	doc := *sale.Document
+	if !domain.ValidSeriesName(doc.Series) || !doc.Type.Valid() {
+		return sale, fmt.Errorf("%w: série %q inválida", domain.ErrUnknownSeries, doc.SeriesKey())
+	}
+	next := last.Number + 1
+	if !issue {
+		if doc.Number != next {
+			return sale, fmt.Errorf("%w: %s/%d, esperado o número %d", domain.ErrSeriesGap, doc.SeriesKey(), doc.Number, next)
+		}
+		doc = doc.Numbered(next)
+		sale.Document = &doc
+		return sale, nil
+	}
+
+	doc = doc.Numbered(next)
+	doc.Hash, doc.HashControl = "", ""
+	sale.Document = &doc
+	if signer != nil {
+		hash, err := signer.Sign(domain.SignatureMessage(sale, last.Hash))
+		if err != nil {
+			return sale, fmt.Errorf("falha ao assinar %s: %w", doc.No, err)
+		}
+		doc.Hash, doc.HashControl = hash, signer.KeyVersion()
+	}
+	return sale, nil
+}
@@ -0,0 +1,214 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// IdempotencyCache guarda as respostas por ordem de gravação: tanto as expiradas (ttl)
+// como as excedentes (maxEntries) saem pela frente da lista. Com persistência, cada
+// resposta é acrescentada a um ficheiro JSONL que é compactado quando acumula entradas
+// já descartadas.
+type IdempotencyCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element // Elementos com domain.IdempotencyRecord
+	order      *list.List
+	inFlight   map[string]*pendingRequest
+	now        func() time.Time
+
+	path     string   // Vazio sem persistência
+	log      *os.File // Aberto em append
+	logLines int
+}
+
+type pendingRequest struct {
+	fingerprint string
+	done        chan struct{}
+}
+
+func NewMemoryIdempotencyStore(ttl time.Duration, maxEntries int) interfaces.IdempotencyStore {
+	return newIdempotencyCache(ttl, maxEntries)
+}
+
+// NewFileIdempotencyStore carrega as respostas ainda válidas de path e passa a gravar nele.
+func NewFileIdempotencyStore(path string, ttl time.Duration, maxEntries int) (interfaces.IdempotencyStore, error) {
+	s := newIdempotencyCache(ttl, maxEntries)
+	s.path = path
+
+	file, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		for scanner.Scan() {
+			var record domain.IdempotencyRecord
+			// Uma linha truncada por uma queda a meio da escrita é ignorada
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				s.add(record)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+		}
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newIdempotencyCache(ttl time.Duration, maxEntries int) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		inFlight:   make(map[string]*pendingRequest),
+		now:        time.Now,
+	}
+}
+
+func idempotencyKey(tenant, key string) string {
+	return tenant + "\x00" + key
+}
+
+func (s *IdempotencyCache) Begin(ctx context.Context, tenant, key, fingerprint string) (*domain.IdempotencyRecord, error) {
+	id := idempotencyKey(tenant, key)
+	for {
+		s.mu.Lock()
+		s.evict()
+		if elem, ok := s.entries[id]; ok {
+			record := elem.Value.(domain.IdempotencyRecord)
+			s.mu.Unlock()
+			if record.Fingerprint != fingerprint {
+				return nil, domain.ErrIdempotencyKeyReused
+			}
+			return &record, nil
+		}
+
+		pending, ok := s.inFlight[id]
+		if !ok {
+			s.inFlight[id] = &pendingRequest{fingerprint: fingerprint, done: make(chan struct{})}
+			s.mu.Unlock()
+			return nil, nil
+		}
+		s.mu.Unlock()
+
+		if pending.fingerprint != fingerprint {
+			return nil, domain.ErrIdempotencyKeyReused
+		}
+		// O primeiro pedido ainda está em execução: espera pela resposta dele
+		select {
+		case <-pending.done:
+		case <-ctx.Done():
+			return nil, domain.ErrIdempotencyInFlight
+		}
+	}
+}
+
+func (s *IdempotencyCache) Complete(ctx context.Context, record domain.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.release(idempotencyKey(record.Tenant, record.Key))
+
+	record.CreatedAt = s.now()
+	s.add(record)
+	s.evict()
+	if s.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("falha ao gravar %s: %w", s.path, err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("falha ao gravar %s: %w", s.path, err)
+	}
+	s.logLines++
+	if s.logLines > 2*s.order.Len()+100 {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *IdempotencyCache) Abort(ctx context.Context, tenant, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.release(idempotencyKey(tenant, key))
+}
+
+func (s *IdempotencyCache) release(id string) {
+	if pending, ok := s.inFlight[id]; ok {
+		close(pending.done)
+		delete(s.inFlight, id)
+	}
+}
+
+func (s *IdempotencyCache) add(record domain.IdempotencyRecord) {
+	id := idempotencyKey(record.Tenant, record.Key)
+	if elem, ok := s.entries[id]; ok {
+		s.order.Remove(elem)
+	}
+	s.entries[id] = s.order.PushBack(record)
+}
+
+func (s *IdempotencyCache) evict() {
+	now := s.now()
+	for front := s.order.Front(); front != nil; front = s.order.Front() {
+		record := front.Value.(domain.IdempotencyRecord)
+		if !expired(record.CreatedAt, s.ttl, now) && (s.maxEntries <= 0 || s.order.Len() <= s.maxEntries) {
+			return
+		}
+		s.order.Remove(front)
+		delete(s.entries, idempotencyKey(record.Tenant, record.Key))
+	}
+}
+
+// compact reescreve o ficheiro só com as respostas em memória e reabre-o para append.
+func (s *IdempotencyCache) compact() error {
+	s.evict()
+	var buf bytes.Buffer
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		line, err := json.Marshal(elem.Value.(domain.IdempotencyRecord))
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := writeFileAtomic(s.path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	if s.log != nil {
+		s.log.Close()
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir %s: %w", s.path, err)
+	}
+	s.log = file
+	s.logLines = s.order.Len()
+	return nil
+}
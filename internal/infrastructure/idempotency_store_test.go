@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+func TestFileIdempotencyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	ctx := context.Background()
+	store, err := NewFileIdempotencyStore(path, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record, err := store.Begin(ctx, "T1", "K1", "fp-a"); record != nil || err != nil {
+		t.Fatalf("a primeira utilização deveria reservar a chave: %v, %v", record, err)
+	}
+
+	// Um duplicado em execução espera pela resposta do original
+	replayed := make(chan *domain.IdempotencyRecord)
+	go func() {
+		record, _ := store.Begin(ctx, "T1", "K1", "fp-a")
+		replayed <- record
+	}()
+	if _, err := store.Begin(ctx, "T1", "K1", "fp-b"); !errors.Is(err, domain.ErrIdempotencyKeyReused) {
+		t.Fatalf("corpo diferente com a mesma chave deveria ser recusado, recebido %v", err)
+	}
+	store.Complete(ctx, domain.IdempotencyRecord{Tenant: "T1", Key: "K1", Fingerprint: "fp-a", Status: 201, Body: []byte(`{"id":"S1"}`)})
+	select {
+	case record := <-replayed:
+		if record == nil || record.Status != 201 || string(record.Body) != `{"id":"S1"}` {
+			t.Fatalf("o duplicado deveria receber a resposta original: %+v", record)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("o duplicado não foi libertado")
+	}
+
+	// A mesma chave noutro tenant é independente
+	if record, _ := store.Begin(ctx, "T2", "K1", "fp-b"); record != nil {
+		t.Fatal("as chaves deveriam ser separadas por tenant")
+	}
+	store.Abort(ctx, "T2", "K1")
+
+	// Sobrevive a um reinício; o limite de 2 entradas descarta a mais antiga
+	for _, key := range []string{"K2", "K3"} {
+		store.Begin(ctx, "T1", key, "fp")
+		store.Complete(ctx, domain.IdempotencyRecord{Tenant: "T1", Key: key, Fingerprint: "fp", Status: 200})
+	}
+	reopened, err := NewFileIdempotencyStore(path, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := reopened.Begin(ctx, "T1", "K3", "fp"); record == nil {
+		t.Fatal("a resposta deveria persistir após reinício")
+	}
+	if record, _ := reopened.Begin(ctx, "T1", "K1", "fp-a"); record != nil {
+		t.Fatal("a entrada mais antiga deveria ter sido descartada")
+	}
+
+	expiring := reopened.(*IdempotencyCache)
+	expiring.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if record, _ := expiring.Begin(ctx, "T1", "K3", "fp"); record != nil {
+		t.Fatal("respostas expiradas não deveriam ser repetidas")
+	}
+}
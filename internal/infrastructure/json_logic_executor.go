@@ -4,36 +4,64 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"math"
 	"strings"
 
+	"github.com/Victor-armando18/service-commercial/internal/domain"
 	"github.com/diegoholiveira/jsonlogic/v3"
 )
 
+// RoundOperator arredonda um número para a precisão indicada (0 casas por omissão).
+var RoundOperator = domain.OperatorSpec{
+	Name:     "round",
+	MinArgs:  1,
+	MaxArgs:  2,
+	ArgTypes: []domain.ArgType{domain.ArgNumber, domain.ArgNumber},
+	Returns:  domain.ArgNumber,
+	Fn:       CustomRound,
+}
+
+// AllocateOperator divide um valor pelo número de partes.
+var AllocateOperator = domain.OperatorSpec{
+	Name:     "allocate",
+	MinArgs:  2,
+	MaxArgs:  2,
+	ArgTypes: []domain.ArgType{domain.ArgNumber, domain.ArgNumber},
+	Returns:  domain.ArgNumber,
+	Fn:       CustomAllocate,
+}
+
 type JsonLogicExecutor struct {
-	customOps map[string]func(args ...interface{}) interface{}
+	customOps map[string]domain.OperatorSpec
 }
 
 func NewJsonLogicExecutor() *JsonLogicExecutor {
 	return &JsonLogicExecutor{
-		customOps: make(map[string]func(args ...interface{}) interface{}),
+		customOps: make(map[string]domain.OperatorSpec),
 	}
 }
 
-func (j *JsonLogicExecutor) RegisterCustomOperator(name string, logic func(args ...interface{}) interface{}) {
-	j.customOps[name] = logic
+func (j *JsonLogicExecutor) RegisterCustomOperator(spec domain.OperatorSpec) {
+	j.customOps[spec.Name] = spec
+}
+
+// Operator devolve a especificação de um operador customizado registado.
+func (j *JsonLogicExecutor) Operator(name string) (domain.OperatorSpec, bool) {
+	spec, ok := j.customOps[name]
+	return spec, ok
 }
 
 func (j *JsonLogicExecutor) Execute(ctx context.Context, ruleData map[string]interface{}, contextVars map[string]interface{}) (interface{}, error) {
 	// Se for foreach, tratamos manualmente
 	if _, ok := ruleData["foreach"]; ok {
-		return j.handleForeach(ruleData["foreach"], contextVars), nil
+		return j.handleForeach(ctx, ruleData["foreach"], contextVars)
 	}
 
 	// Se for um operador customizado no topo (como round)
-	for opName, fn := range j.customOps {
+	for opName, spec := range j.customOps {
 		if args, ok := ruleData[opName]; ok {
-			return j.handleManualEval(args, contextVars, fn), nil
+			return j.handleManualEval(ctx, args, contextVars, spec)
 		}
 	}
 
@@ -64,10 +92,10 @@ func (j *JsonLogicExecutor) runStandardLogic(rule interface{}, data interface{})
 	return j.finalizeValue(res), nil
 }
 
-func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]interface{}) interface{} {
+func (j *JsonLogicExecutor) handleForeach(ctx context.Context, args interface{}, data map[string]interface{}) (interface{}, error) {
 	params, ok := args.([]interface{})
 	if !ok || len(params) < 2 {
-		return 0.0
+		return 0.0, nil
 	}
 
 	collection := j.resolveVar(params[0], data)
@@ -77,7 +105,7 @@ func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]inte
 
 	logic, ok := params[1].(map[string]interface{})
 	if !ok {
-		return 0.0
+		return 0.0, nil
 	}
 
 	var total float64
@@ -87,33 +115,50 @@ func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]inte
 			"item":  item,
 			"order": data["order"],
 		}
-		res, _ := j.Execute(context.Background(), logic, itemCtx)
+		res, err := j.Execute(ctx, logic, itemCtx)
+		if err != nil {
+			return nil, err
+		}
 		if f, ok := anyToFloat(res); ok {
 			total += f
 		}
 	}
-	return total
+	return total, nil
 }
 
-func (j *JsonLogicExecutor) handleManualEval(args interface{}, data map[string]interface{}, fn func(args ...interface{}) interface{}) interface{} {
-	var params []interface{}
+func (j *JsonLogicExecutor) handleManualEval(ctx context.Context, args interface{}, data map[string]interface{}, spec domain.OperatorSpec) (interface{}, error) {
+	list := asArgList(args)
+	if err := spec.CheckArity(len(list)); err != nil {
+		return nil, &domain.OperatorError{Operator: spec.Name, Err: err}
+	}
 
-	// Se os argumentos forem uma lista (ex: [ {logic}, 2 ])
-	if list, ok := args.([]interface{}); ok {
-		for _, item := range list {
-			// Se o item for uma regra aninhada (mapa), executamos primeiro
-			if subRule, isRule := item.(map[string]interface{}); isRule {
-				res, _ := j.Execute(context.Background(), subRule, data)
-				params = append(params, res)
-			} else {
-				params = append(params, j.resolveVar(item, data))
+	params := make(domain.OperatorArgs, 0, len(list))
+	for i, item := range list {
+		var val interface{}
+		// Se o item for uma regra aninhada (mapa), executamos primeiro
+		if subRule, isRule := item.(map[string]interface{}); isRule {
+			res, err := j.Execute(ctx, subRule, data)
+			if err != nil {
+				return nil, err
 			}
+			val = res
+		} else {
+			val = item
 		}
-	} else {
-		params = append(params, j.resolveVar(args, data))
+
+		typed, ok := coerceArg(spec.TypeOf(i), val)
+		if !ok {
+			err := fmt.Errorf("%w: argumento %d deve ser %s, recebeu %v", domain.ErrInvalidOperatorCall, i, spec.TypeOf(i), val)
+			return nil, &domain.OperatorError{Operator: spec.Name, Err: err}
+		}
+		params = append(params, typed)
 	}
 
-	return fn(params...)
+	out, err := spec.Fn(ctx, params)
+	if err != nil {
+		return nil, &domain.OperatorError{Operator: spec.Name, Err: err}
+	}
+	return out, nil
 }
 
 func (j *JsonLogicExecutor) resolveVar(arg interface{}, data map[string]interface{}) interface{} {
@@ -152,31 +197,43 @@ func (j *JsonLogicExecutor) finalizeValue(val interface{}) interface{} {
 	return val
 }
 
-func CustomRound(args ...interface{}) interface{} {
-	if len(args) == 0 {
-		return 0.0
-	}
-	val, _ := anyToFloat(args[0])
-	precision := 0
-	if len(args) > 1 {
-		if p, ok := anyToFloat(args[1]); ok {
-			precision = int(p)
-		}
-	}
-	ratio := math.Pow(10, float64(precision))
-	return math.Round(val*ratio) / ratio
+func CustomRound(ctx context.Context, args domain.OperatorArgs) (interface{}, error) {
+	ratio := math.Pow(10, float64(int(args.Number(1))))
+	return math.Round(args.Number(0)*ratio) / ratio, nil
 }
 
-func CustomAllocate(args ...interface{}) interface{} {
-	if len(args) < 2 {
-		return 0.0
-	}
-	val, _ := anyToFloat(args[0])
-	parts, _ := anyToFloat(args[1])
+func CustomAllocate(ctx context.Context, args domain.OperatorArgs) (interface{}, error) {
+	parts := args.Number(1)
 	if parts == 0 {
-		return 0.0
+		return nil, fmt.Errorf("divisão por zero")
 	}
-	return val / parts
+	return args.Number(0) / parts, nil
+}
+
+// asArgList normaliza os argumentos de um operador: um valor isolado equivale a uma lista de um elemento.
+func asArgList(args interface{}) []interface{} {
+	if list, ok := args.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{args}
+}
+
+// coerceArg converte um argumento para o tipo declarado, indicando se a conversão é possível.
+func coerceArg(t domain.ArgType, v interface{}) (interface{}, bool) {
+	switch t {
+	case domain.ArgNumber:
+		return anyToFloat(v)
+	case domain.ArgString:
+		s, ok := v.(string)
+		return s, ok
+	case domain.ArgBool:
+		b, ok := v.(bool)
+		return b, ok
+	case domain.ArgList:
+		l, ok := v.([]interface{})
+		return l, ok
+	}
+	return v, true
 }
 
 func anyToFloat(i interface{}) (float64, bool) {
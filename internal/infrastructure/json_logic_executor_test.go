@@ -0,0 +1,36 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+func newTestExecutor() *JsonLogicExecutor {
+	executor := NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(AllocateOperator)
+	executor.RegisterCustomOperator(RoundOperator)
+	return executor
+}
+
+func TestExecutor_OperatorErrors(t *testing.T) {
+	executor := newTestExecutor()
+	ctx := context.Background()
+	data := map[string]interface{}{"order": map[string]interface{}{"currency": "AOA"}}
+
+	cases := map[string]map[string]interface{}{
+		"divisão por zero":       {"allocate": []interface{}{100.0, 0.0}},
+		"argumento não numérico": {"round": []interface{}{map[string]interface{}{"var": "order.currency"}, 2.0}},
+		"aridade":                {"allocate": []interface{}{100.0}},
+	}
+
+	for name, logic := range cases {
+		_, err := executor.Execute(ctx, logic, data)
+		var opErr *domain.OperatorError
+		if !errors.As(err, &opErr) {
+			t.Errorf("%s: esperado OperatorError, recebido %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Ficheiros de dados mestre dentro do diretório de dados
+const (
+	companyFile  = "company.json"
+	productsFile = "products.json"
+	taxesFile    = "taxs.json"
+)
+
+// FileMasterData lê a empresa, os artigos e os impostos dos ficheiros JSON do diretório de dados.
+type FileMasterData struct {
+	dir string
+}
+
+func NewFileMasterData(dir string) interfaces.MasterData {
+	return &FileMasterData{dir: dir}
+}
+
+func (m *FileMasterData) Company(ctx context.Context) (domain.Company, error) {
+	var company domain.Company
+	err := readJSONFile(filepath.Join(m.dir, companyFile), &company)
+	return company, err
+}
+
+func (m *FileMasterData) Products(ctx context.Context) ([]domain.Product, error) {
+	var products []domain.Product
+	err := readJSONFile(filepath.Join(m.dir, productsFile), &products)
+	return products, err
+}
+
+func (m *FileMasterData) Taxes(ctx context.Context) ([]domain.Tax, error) {
+	var taxes []domain.Tax
+	err := readJSONFile(filepath.Join(m.dir, taxesFile), &taxes)
+	return taxes, err
+}
+
+func readJSONFile(path string, v interface{}) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("JSON inválido em %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// MemoryOrderSessionStore guarda as sessões em memória; sessões sem alterações
+// durante ttl são descartadas para que o mapa não cresça sem limite.
+type MemoryOrderSessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]domain.OrderSession
+	ttl       time.Duration
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewMemoryOrderSessionStore(ttl time.Duration) interfaces.OrderSessionStore {
+	return &MemoryOrderSessionStore{sessions: make(map[string]domain.OrderSession), ttl: ttl, now: time.Now}
+}
+
+func (s *MemoryOrderSessionStore) Get(ctx context.Context, orderID string) (domain.OrderSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[orderID]
+	if !ok {
+		return domain.OrderSession{}, domain.ErrOrderNotFound
+	}
+	if expired(session.UpdatedAt, s.ttl, s.now()) {
+		delete(s.sessions, orderID)
+		return domain.OrderSession{}, domain.ErrOrderNotFound
+	}
+	return session, nil
+}
+
+func (s *MemoryOrderSessionStore) Put(ctx context.Context, session domain.OrderSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(session)
+	return nil
+}
+
+func (s *MemoryOrderSessionStore) CompareAndSwap(ctx context.Context, expected string, session domain.OrderSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current string
+	if stored, ok := s.sessions[session.OrderID]; ok && !expired(stored.UpdatedAt, s.ttl, s.now()) {
+		current = stored.Revision
+	}
+	if current != expected {
+		return false, nil
+	}
+	s.store(session)
+	return true, nil
+}
+
+func (s *MemoryOrderSessionStore) store(session domain.OrderSession) {
+	now := s.now()
+	session.UpdatedAt = now
+	s.sessions[session.OrderID] = session
+
+	if now.Sub(s.lastPrune) > s.ttl/2 {
+		for id, stored := range s.sessions {
+			if expired(stored.UpdatedAt, s.ttl, now) {
+				delete(s.sessions, id)
+			}
+		}
+		s.lastPrune = now
+	}
+}
+
+func expired(updatedAt time.Time, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(updatedAt) > ttl
+}
+
+// FileOrderSessionStore guarda cada sessão num ficheiro JSON do diretório dir, para
+// que os rascunhos sobrevivam a um reinício. O nome do ficheiro é o ID em base64url,
+// pelo que qualquer ID é seguro como nome de ficheiro.
+type FileOrderSessionStore struct {
+	dir       string
+	ttl       time.Duration
+	mu        sync.Mutex
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewFileOrderSessionStore(dir string, ttl time.Duration) (interfaces.OrderSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("falha ao criar diretório de sessões %s: %w", dir, err)
+	}
+	return &FileOrderSessionStore{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *FileOrderSessionStore) Get(ctx context.Context, orderID string) (domain.OrderSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(orderID)
+}
+
+func (s *FileOrderSessionStore) Put(ctx context.Context, session domain.OrderSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(session)
+}
+
+func (s *FileOrderSessionStore) CompareAndSwap(ctx context.Context, expected string, session domain.OrderSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current string
+	stored, err := s.read(session.OrderID)
+	switch {
+	case err == nil:
+		current = stored.Revision
+	case !errors.Is(err, domain.ErrOrderNotFound):
+		return false, err
+	}
+	if current != expected {
+		return false, nil
+	}
+	return true, s.write(session)
+}
+
+func (s *FileOrderSessionStore) path(orderID string) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(orderID))+".json")
+}
+
+func (s *FileOrderSessionStore) read(orderID string) (domain.OrderSession, error) {
+	var session domain.OrderSession
+	raw, err := os.ReadFile(s.path(orderID))
+	if errors.Is(err, os.ErrNotExist) {
+		return session, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return session, fmt.Errorf("falha ao ler sessão %s: %w", orderID, err)
+	}
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return session, fmt.Errorf("sessão %s inválida: %w", orderID, err)
+	}
+	if expired(session.UpdatedAt, s.ttl, s.now()) {
+		os.Remove(s.path(orderID))
+		return domain.OrderSession{}, domain.ErrOrderNotFound
+	}
+	return session, nil
+}
+
+func (s *FileOrderSessionStore) write(session domain.OrderSession) error {
+	now := s.now()
+	session.UpdatedAt = now
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path(session.OrderID), raw, 0o644); err != nil {
+		return err
+	}
+
+	// As sessões abandonadas são apagadas pela data de modificação do ficheiro
+	if s.ttl > 0 && now.Sub(s.lastPrune) > s.ttl/2 {
+		entries, _ := os.ReadDir(s.dir)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err == nil && strings.HasSuffix(entry.Name(), ".json") && expired(info.ModTime(), s.ttl, now) {
+				os.Remove(filepath.Join(s.dir, entry.Name()))
+			}
+		}
+		s.lastPrune = now
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+func TestFileOrderSessionStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	store, err := NewFileOrderSessionStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// IDs com separadores não podem escapar do diretório
+	session := domain.OrderSession{OrderID: "../ORD/1", Revision: "r1", Order: domain.Order{ID: "../ORD/1", Currency: "AOA"}}
+	if ok, err := store.CompareAndSwap(ctx, "", session); !ok || err != nil {
+		t.Fatalf("criação falhou: %v", err)
+	}
+	session.Revision = "r2"
+	if ok, _ := store.CompareAndSwap(ctx, "r0", session); ok {
+		t.Fatal("CompareAndSwap com revisão errada deveria falhar")
+	}
+	if ok, err := store.CompareAndSwap(ctx, "r1", session); !ok || err != nil {
+		t.Fatalf("CompareAndSwap falhou: %v", err)
+	}
+
+	// Outra instância (ex: após reinício) vê a mesma sessão
+	reopened, _ := NewFileOrderSessionStore(dir, time.Hour)
+	got, err := reopened.Get(ctx, "../ORD/1")
+	if err != nil || got.Revision != "r2" || got.Order.Currency != "AOA" {
+		t.Fatalf("sessão não persistida: %+v, %v", got, err)
+	}
+
+	expiring := reopened.(*FileOrderSessionStore)
+	expiring.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := expiring.Get(ctx, "../ORD/1"); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Fatalf("sessão expirada deveria desaparecer, recebido %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+var patchOps = map[string]bool{"add": true, "remove": true, "replace": true, "move": true, "copy": true}
+
+// LoadPatchPolicy lê a política de caminhos alteráveis de um ficheiro JSON. Os campos
+// desconhecidos são recusados, para que um erro de escrita não abra o pedido ao cliente.
+func LoadPatchPolicy(path string) (domain.PatchPolicy, error) {
+	var policy domain.PatchPolicy
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return policy, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("política inválida em %s: %w", path, err)
+	}
+
+	for _, w := range policy.Writable {
+		if !strings.HasPrefix(w.Path, "/") {
+			return policy, fmt.Errorf("política inválida em %s: caminho %q não é um JSON Pointer", path, w.Path)
+		}
+		for _, op := range w.Ops {
+			if !patchOps[op] {
+				return policy, fmt.Errorf("política inválida em %s: operação %q desconhecida em %s", path, op, w.Path)
+			}
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,278 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Página A4 em pontos e margens da fatura
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+	pdfRight      = pdfPageWidth - pdfMargin
+	pdfBottom     = 70.0 // Abaixo fica só o rodapé com o número da página
+)
+
+// PDFReceiptRenderer imprime a fatura em PDF A4. O PDF é escrito diretamente, com as
+// fontes Helvetica de base (sem fontes embutidas) e codificação WinAnsi, que cobre o
+// português; o código QR é impresso como texto.
+type PDFReceiptRenderer struct{}
+
+func NewPDFReceiptRenderer() interfaces.ReceiptRenderer {
+	return PDFReceiptRenderer{}
+}
+
+func (PDFReceiptRenderer) ContentType() string {
+	return "application/pdf"
+}
+
+func (PDFReceiptRenderer) Render(r domain.Receipt) ([]byte, error) {
+	doc := &pdfDocument{}
+	doc.newPage()
+
+	// Emissor à esquerda, documento à direita
+	doc.text(pdfMargin, doc.y, 14, true, r.Company.Name)
+	doc.textRight(pdfRight, doc.y, 14, true, r.Title)
+	doc.y -= 16
+	doc.text(pdfMargin, doc.y, 9, false, "NIF: "+r.Company.TaxID)
+	doc.textRight(pdfRight, doc.y, 10, true, firstNonEmpty(r.DocumentNo, r.SaleID))
+	doc.y -= 12
+	doc.text(pdfMargin, doc.y, 9, false, joinNonEmpty(", ", r.Company.Address, r.Company.PostalCode, r.Company.City))
+	doc.textRight(pdfRight, doc.y, 9, false, "Data: "+r.IssuedAt)
+	doc.y -= 12
+	if r.Currency != "" {
+		doc.textRight(pdfRight, doc.y, 9, false, "Moeda: "+r.Currency)
+	}
+	doc.y -= 20
+
+	if r.OriginalDocumentNo != "" {
+		doc.paragraph(9, false, "Referente a: "+r.OriginalDocumentNo)
+		doc.paragraph(9, false, "Motivo: "+r.ReturnReason)
+		doc.y -= 8
+	}
+	if r.Void != nil {
+		doc.paragraph(12, true, "DOCUMENTO ANULADO")
+		doc.paragraph(9, false, fmt.Sprintf("Anulado por %s em %s. Motivo: %s", r.Void.UserID, r.Void.At.Format("2006-01-02 15:04"), r.Void.Reason))
+		doc.y -= 8
+	}
+
+	// Linhas
+	header := func() {
+		doc.rule(doc.y + 10)
+		doc.text(pdfMargin, doc.y, 9, true, "Artigo")
+		doc.text(130, doc.y, 9, true, "Descrição")
+		doc.textRight(380, doc.y, 9, true, "Qtd")
+		doc.textRight(460, doc.y, 9, true, "Preço unit.")
+		doc.textRight(pdfRight, doc.y, 9, true, "Valor")
+		doc.y -= 6
+		doc.rule(doc.y)
+		doc.y -= 12
+	}
+	header()
+	for _, line := range r.Lines {
+		if doc.y < pdfBottom {
+			doc.newPage()
+			header()
+		}
+		doc.text(pdfMargin, doc.y, 9, false, truncateRunes(line.SKU, 14))
+		doc.text(130, doc.y, 9, false, truncateRunes(line.Description, 36))
+		doc.textRight(380, doc.y, 9, false, fmt.Sprint(line.Qty))
+		doc.textRight(460, doc.y, 9, false, formatAmount(line.UnitPrice))
+		doc.textRight(pdfRight, doc.y, 9, false, formatAmount(line.Amount))
+		doc.y -= 13
+	}
+	doc.rule(doc.y + 7)
+	doc.y -= 10
+
+	// Totais e resumo de impostos
+	total := func(label, value string, bold bool) {
+		doc.ensure(14)
+		doc.text(330, doc.y, 10, bold, label)
+		doc.textRight(pdfRight, doc.y, 10, bold, value)
+		doc.y -= 14
+	}
+	total("Total ilíquido", formatAmount(r.Gross), false)
+	if r.Discount > 0 {
+		label := "Desconto"
+		if r.DiscountPercentage > 0 {
+			label += " (" + formatPercent(r.DiscountPercentage*100) + ")"
+		}
+		total(label, "-"+formatAmount(r.Discount), false)
+	}
+	total("Base tributável", formatAmount(r.BaseValue), false)
+	for _, tax := range r.Taxes {
+		total(tax.Name+" "+formatPercent(tax.Rate), formatAmount(tax.Amount), false)
+	}
+	total(strings.TrimSpace("Total "+r.Currency), formatAmount(r.Total), true)
+	doc.y -= 10
+
+	if len(r.Taxes) > 0 {
+		doc.ensure(40)
+		doc.text(pdfMargin, doc.y, 9, true, "Resumo de impostos")
+		doc.y -= 6
+		doc.rule(doc.y)
+		doc.y -= 12
+		for _, tax := range r.Taxes {
+			doc.ensure(13)
+			doc.text(pdfMargin, doc.y, 9, false, tax.Name)
+			doc.textRight(300, doc.y, 9, false, formatPercent(tax.Rate))
+			doc.textRight(400, doc.y, 9, false, "Base "+formatAmount(r.BaseValue))
+			doc.textRight(pdfRight, doc.y, 9, false, formatAmount(tax.Amount))
+			doc.y -= 13
+		}
+		doc.y -= 10
+	}
+
+	if mention := joinNonEmpty("-", r.SignatureExcerpt, r.Certification); mention != "" {
+		doc.paragraph(9, false, mention)
+	}
+	if r.QRPayload != "" {
+		doc.paragraph(8, false, "QR: "+r.QRPayload)
+	}
+	return doc.bytes(), nil
+}
+
+// pdfDocument acumula o conteúdo de cada página; y é a linha de base atual, a descer.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// ensure muda de página se não houver espaço para mais height pontos.
+func (d *pdfDocument) ensure(height float64) {
+	if d.y-height < pdfBottom {
+		d.newPage()
+	}
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *pdfDocument) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+func (d *pdfDocument) textRight(right, y, size float64, bold bool, s string) {
+	d.text(right-helveticaWidth(s, size, bold), y, size, bold, s)
+}
+
+// paragraph escreve o texto da margem esquerda à direita, quebrando as linhas.
+func (d *pdfDocument) paragraph(size float64, bold bool, s string) {
+	// Largura média de um carácter Helvetica: ~0,5 em
+	for _, line := range wrapText(s, int((pdfRight-pdfMargin)/(size*0.5))) {
+		d.ensure(size + 3)
+		d.text(pdfMargin, d.y, size, bold, line)
+		d.y -= size + 3
+	}
+}
+
+func (d *pdfDocument) rule(y float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, y, pdfRight, y)
+}
+
+// bytes escreve o ficheiro: catálogo, árvore de páginas, as duas fontes e, por página,
+// o objeto da página e o seu conteúdo, seguidos da tabela xref.
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		footer := fmt.Sprintf("Página %d de %d", i+1, len(d.pages))
+		fmt.Fprintf(page, "BT /F1 8.0 Tf %.2f %.2f Td (%s) Tj ET\n", pdfRight-helveticaWidth(footer, 8, false), pdfMargin-20, pdfEscape(footer))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape converte o texto para WinAnsi (igual ao Latin-1 nos acentos portugueses) e
+// escapa os caracteres especiais das strings PDF.
+func pdfEscape(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(byte(r))
+		case r < 0x20:
+			out.WriteByte(' ')
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			out.WriteByte(byte(r))
+		case winAnsiExtras[r] != 0:
+			out.WriteByte(winAnsiExtras[r])
+		default:
+			out.WriteByte('?')
+		}
+	}
+	return out.String()
+}
+
+// Caracteres WinAnsi fora do Latin-1
+var winAnsiExtras = map[rune]byte{'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '–': 0x96, '—': 0x97}
+
+// Larguras Helvetica (milésimos de em) dos caracteres mais comuns nos valores e números;
+// os restantes contam como a largura média.
+var helveticaWidths = map[rune]float64{
+	' ': 278, ',': 278, '.': 278, '-': 333, '%': 889, ':': 278, '/': 278,
+	'0': 556, '1': 556, '2': 556, '3': 556, '4': 556, '5': 556, '6': 556, '7': 556, '8': 556, '9': 556,
+}
+
+// helveticaWidth estima a largura do texto em pontos, para alinhar à direita. Os números
+// usam as larguras exatas; o negrito é ~5% mais largo nas letras.
+func helveticaWidth(s string, size float64, bold bool) float64 {
+	var width float64
+	for _, r := range s {
+		w, ok := helveticaWidths[r]
+		if !ok {
+			w = 556
+			if bold {
+				w = 584
+			}
+		}
+		width += w
+	}
+	return width * size / 1000
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package infrastructure
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+func sampleReceipt() domain.Receipt {
+	lines := []domain.ReceiptLine{{SKU: "PROD-001", Description: "Arroz 1kg", Qty: 2, UnitPrice: 1200, Amount: 2400}}
+	for i := 0; i < 80; i++ {
+		lines = append(lines, domain.ReceiptLine{SKU: "PROD-002", Description: "Óleo alimentar de girassol com uma descrição muito comprida", Qty: 1, UnitPrice: 2500, Amount: 2500})
+	}
+	return domain.Receipt{
+		Company:    domain.Company{TaxID: "5000000000", Name: "Empresa (Exemplo), Lda", Address: "Rua 1", City: "Luanda"},
+		Title:      "Fatura",
+		DocumentNo: "FT MAIN-1/7",
+		IssuedAt:   "2026-01-24 03:39",
+		Currency:   "AOA",
+		Lines:      lines,
+		Gross:      202400, DiscountPercentage: 0.05, Discount: 10120, BaseValue: 192280,
+		Taxes:            []domain.ReceiptTax{{ID: "VAT", Name: "IVA", Rate: 14, Amount: 26919.2}},
+		Total:            219199.2,
+		Void:             &domain.SaleVoid{Reason: "Engano", UserID: "ana", At: time.Date(2026, 1, 24, 4, 0, 0, 0, time.UTC)},
+		SignatureExcerpt: "pD+9",
+		Certification:    "Processado por programa válido n.º 000/AGT/2026",
+		QRPayload:        "A:5000000000*B:999999999*C:AO*D:FT*E:A*F:20260124*G:FT MAIN-1/7*N:26919.20*O:219199.20*Q:pD+9*R:000/AGT/2026",
+	}
+}
+
+func TestTextReceiptRenderer(t *testing.T) {
+	out, err := NewTextReceiptRenderer().Render(sampleReceipt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if n := utf8.RuneCountInString(line); n > receiptWidth {
+			t.Errorf("linha com %d colunas: %q", n, line)
+		}
+	}
+	for _, want := range []string{"FT MAIN-1/7", "Desconto (5%)", "-10.120,00", "IVA 14%", "26.919,20", "TOTAL AOA", "219.199,20", "DOCUMENTO ANULADO", "pD+9-Processado"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("talão sem %q", want)
+		}
+	}
+}
+
+func TestESCPOSReceiptRenderer(t *testing.T) {
+	receipt := sampleReceipt()
+	receipt.Lines[0].Description = "Arroz\x1bp\x00\x19\xfa\x1dV\x00"
+	out, err := NewESCPOSReceiptRenderer().Render(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte{0x1b, '@', 0x1b, 't', 3}) || !bytes.HasSuffix(out, []byte{0x1d, 'V', 66, 3}) {
+		t.Fatal("esperado início com ESC @ e ESC t 3 e fim com o corte")
+	}
+	n := len(receipt.QRPayload) + 3
+	if !bytes.Contains(out, append([]byte{0x1d, '(', 'k', byte(n), byte(n >> 8), '1', 'P', '0'}, receipt.QRPayload...)) {
+		t.Fatal("o payload do código QR deveria seguir em GS ( k com o comprimento correto")
+	}
+	if !bytes.Contains(out, []byte("Arroz?p????V?")) || bytes.Contains(out, []byte{0x1b, 'p'}) {
+		t.Fatal("os caracteres de controlo da descrição não deveriam chegar à impressora")
+	}
+	if !bytes.Contains(out, []byte{0x9f, 'l', 'e', 'o'}) {
+		t.Fatal("Ó deveria ser codificado em PC860")
+	}
+}
+
+func TestPDFReceiptRenderer(t *testing.T) {
+	out, err := NewPDFReceiptRenderer().Render(sampleReceipt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("cabeçalho ou fim de PDF em falta")
+	}
+	// Cada entrada da tabela xref aponta para o início do objeto respetivo
+	xref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	start, _ := strconv.Atoi(string(xref[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out[start:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Fatalf("xref da entrada %d não aponta para %q", i+1, want)
+		}
+	}
+	// 81 linhas não cabem numa página; os parênteses do nome são escapados
+	if !bytes.Contains(out, []byte("/Count 2")) || !bytes.Contains(out, []byte(`Empresa \(Exemplo\), Lda`)) || !bytes.Contains(out, []byte("P\xe1gina 2 de 2")) {
+		t.Fatal("esperadas 2 páginas, texto escapado e em WinAnsi")
+	}
+}
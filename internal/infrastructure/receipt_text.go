@@ -0,0 +1,294 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Largura do talão em caracteres: a das impressoras térmicas de 80 mm com a fonte A
+const receiptWidth = 48
+
+// receiptLine é uma linha do talão; as impressoras ESC/POS imprimem as destacadas a negrito.
+type receiptLine struct {
+	text string
+	bold bool
+}
+
+// TextReceiptRenderer imprime o talão em texto simples com 48 colunas.
+type TextReceiptRenderer struct{}
+
+func NewTextReceiptRenderer() interfaces.ReceiptRenderer {
+	return TextReceiptRenderer{}
+}
+
+func (TextReceiptRenderer) Render(r domain.Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, line := range receiptLines(r) {
+		buf.WriteString(line.text)
+		buf.WriteByte('\n')
+	}
+	// O payload não tem espaços significativos para quebrar: corta-se em blocos
+	for _, line := range chunkRunes("QR: "+r.QRPayload, receiptWidth) {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (TextReceiptRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// ESCPOSReceiptRenderer imprime o talão em comandos ESC/POS: texto na página de código
+// 860 (português), o código QR desenhado pela própria impressora e corte do papel.
+type ESCPOSReceiptRenderer struct{}
+
+func NewESCPOSReceiptRenderer() interfaces.ReceiptRenderer {
+	return ESCPOSReceiptRenderer{}
+}
+
+// Comandos ESC/POS usados
+var (
+	escposInit     = []byte{0x1b, '@'}        // ESC @: reinicia a impressora
+	escposCodePage = []byte{0x1b, 't', 3}     // ESC t 3: página de código PC860
+	escposBoldOn   = []byte{0x1b, 'E', 1}     // ESC E 1
+	escposBoldOff  = []byte{0x1b, 'E', 0}     // ESC E 0
+	escposCenter   = []byte{0x1b, 'a', 1}     // ESC a 1: alinhamento ao centro
+	escposLeft     = []byte{0x1b, 'a', 0}     // ESC a 0
+	escposCut      = []byte{0x1d, 'V', 66, 3} // GS V 66 3: avança 3 linhas e corta
+)
+
+func (ESCPOSReceiptRenderer) Render(r domain.Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(escposInit)
+	buf.Write(escposCodePage)
+	for _, line := range receiptLines(r) {
+		if line.bold {
+			buf.Write(escposBoldOn)
+		}
+		buf.Write(encodeCP860(line.text))
+		if line.bold {
+			buf.Write(escposBoldOff)
+		}
+		buf.WriteByte('\n')
+	}
+	if r.QRPayload != "" {
+		buf.Write(escposCenter)
+		if err := writeESCPOSQR(&buf, r.QRPayload); err != nil {
+			return nil, err
+		}
+		buf.Write(escposLeft)
+	}
+	buf.Write(escposCut)
+	return buf.Bytes(), nil
+}
+
+func (ESCPOSReceiptRenderer) ContentType() string {
+	return "application/octet-stream"
+}
+
+// writeESCPOSQR envia o código QR com os comandos GS ( k: modelo 2, módulo de 6 pontos,
+// correção de erros M, dados e impressão.
+func writeESCPOSQR(buf *bytes.Buffer, payload string) error {
+	data := []byte(payload)
+	if len(data) > 7089 {
+		return fmt.Errorf("payload do código QR com %d bytes excede o máximo de 7089", len(data))
+	}
+	qr := func(fn byte, params ...byte) {
+		n := len(params) + 2
+		buf.Write([]byte{0x1d, '(', 'k', byte(n), byte(n >> 8), '1', fn})
+		buf.Write(params)
+	}
+	buf.Write([]byte{0x1d, '(', 'k', 4, 0, '1', 'A', '2', 0}) // Modelo 2
+	qr('C', 6)                                                // Tamanho do módulo
+	qr('E', '1')                                              // Correção de erros M
+	qr('P', append([]byte{'0'}, data...)...)                  // Guarda os dados
+	qr('Q', '0')                                              // Imprime
+	buf.WriteByte('\n')
+	return nil
+}
+
+// receiptLines compõe o talão em linhas de receiptWidth colunas, comum ao texto e ao ESC/POS.
+func receiptLines(r domain.Receipt) []receiptLine {
+	var lines []receiptLine
+	add := func(bold bool, texts ...string) {
+		for _, text := range texts {
+			lines = append(lines, receiptLine{text: text, bold: bold})
+		}
+	}
+	center := func(bold bool, text string) {
+		for _, part := range wrapText(text, receiptWidth) {
+			add(bold, strings.Repeat(" ", (receiptWidth-utf8.RuneCountInString(part))/2)+part)
+		}
+	}
+
+	center(true, r.Company.Name)
+	if r.Company.TaxID != "" {
+		center(false, "NIF: "+r.Company.TaxID)
+	}
+	center(false, joinNonEmpty(", ", r.Company.Address, r.Company.City))
+	add(false, strings.Repeat("=", receiptWidth))
+	add(true, columns(strings.ToUpper(r.Title), firstNonEmpty(r.DocumentNo, r.SaleID))...)
+	add(false, columns("Data", r.IssuedAt)...)
+	if r.OriginalDocumentNo != "" {
+		add(false, wrapText("Referente a: "+r.OriginalDocumentNo, receiptWidth)...)
+		add(false, wrapText("Motivo: "+r.ReturnReason, receiptWidth)...)
+	}
+	if r.Void != nil {
+		center(true, "*** DOCUMENTO ANULADO ***")
+		add(false, wrapText(fmt.Sprintf("Anulado por %s em %s: %s", r.Void.UserID, r.Void.At.Format("2006-01-02 15:04"), r.Void.Reason), receiptWidth)...)
+	}
+
+	add(false, strings.Repeat("-", receiptWidth))
+	for _, item := range r.Lines {
+		add(false, wrapText(item.Description, receiptWidth)...)
+		add(false, columns(fmt.Sprintf("  %d x %s", item.Qty, formatAmount(item.UnitPrice)), formatAmount(item.Amount))...)
+	}
+	add(false, strings.Repeat("-", receiptWidth))
+	add(false, columns("Total ilíquido", formatAmount(r.Gross))...)
+	if r.Discount > 0 {
+		label := "Desconto"
+		if r.DiscountPercentage > 0 {
+			label += " (" + formatPercent(r.DiscountPercentage*100) + ")"
+		}
+		add(false, columns(label, "-"+formatAmount(r.Discount))...)
+	}
+	add(false, columns("Base tributável", formatAmount(r.BaseValue))...)
+	for _, tax := range r.Taxes {
+		add(false, columns(tax.Name+" "+formatPercent(tax.Rate), formatAmount(tax.Amount))...)
+	}
+	add(false, strings.Repeat("=", receiptWidth))
+	add(true, columns(strings.TrimSpace("TOTAL "+r.Currency), formatAmount(r.Total))...)
+	add(false, strings.Repeat("=", receiptWidth))
+
+	if mention := joinNonEmpty("-", r.SignatureExcerpt, r.Certification); mention != "" {
+		add(false, wrapText(mention, receiptWidth)...)
+	}
+	return lines
+}
+
+// columns alinha left à esquerda e right à direita; se não couberem na mesma linha,
+// right passa para a linha seguinte.
+func columns(left, right string) []string {
+	gap := receiptWidth - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap >= 1 {
+		return []string{left + strings.Repeat(" ", gap) + right}
+	}
+	lines := wrapText(left, receiptWidth)
+	return append(lines, fmt.Sprintf("%*s", receiptWidth, right))
+}
+
+// wrapText quebra o texto em linhas de até width caracteres, pelos espaços quando possível.
+func wrapText(text string, width int) []string {
+	var lines []string
+	var current []rune
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		if len(current) > 0 && len(current)+1+len(w) > width {
+			lines = append(lines, string(current))
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, w...)
+		for len(current) > width {
+			lines = append(lines, string(current[:width]))
+			current = current[width:]
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
+
+// chunkRunes corta o texto em blocos de width caracteres.
+func chunkRunes(text string, width int) []string {
+	var chunks []string
+	for runes := []rune(text); len(runes) > 0; {
+		n := min(width, len(runes))
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
+
+// formatAmount formata um valor com duas casas decimais, vírgula decimal e ponto a separar
+// os milhares, ex: 1.234,56.
+func formatAmount(v float64) string {
+	s := strconv.FormatFloat(math.Abs(math.Round(v*100)/100), 'f', 2, 64)
+	integer, decimals := s[:len(s)-3], s[len(s)-2:]
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+	sign := ""
+	if v < 0 && s != "0.00" {
+		sign = "-"
+	}
+	return sign + grouped.String() + "," + decimals
+}
+
+// formatPercent formata uma percentagem sem casas decimais desnecessárias, ex: 14%, 2,5%.
+func formatPercent(v float64) string {
+	return strings.Replace(strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64), ".", ",", 1) + "%"
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// cp860 mapeia os caracteres portugueses fora do ASCII para a página de código 860.
+var cp860 = map[rune]byte{
+	'Ç': 0x80, 'ü': 0x81, 'é': 0x82, 'â': 0x83, 'ã': 0x84, 'à': 0x85, 'Á': 0x86, 'ç': 0x87,
+	'ê': 0x88, 'Ê': 0x89, 'è': 0x8a, 'Í': 0x8b, 'Ô': 0x8c, 'ì': 0x8d, 'Ã': 0x8e, 'Â': 0x8f,
+	'É': 0x90, 'À': 0x91, 'È': 0x92, 'ô': 0x93, 'õ': 0x94, 'ò': 0x95, 'Ú': 0x96, 'ù': 0x97,
+	'Ì': 0x98, 'Õ': 0x99, 'Ü': 0x9a, 'Ù': 0x9d, 'Ó': 0x9f, 'á': 0xa0, 'í': 0xa1, 'ó': 0xa2,
+	'ú': 0xa3, 'ñ': 0xa4, 'Ñ': 0xa5, 'ª': 0xa6, 'º': 0xa7, 'Ò': 0xa9,
+}
+
+// encodeCP860 converte o texto para a página de código 860; os caracteres sem
+// correspondência são impressos como "?". Os caracteres de controlo também: uma descrição
+// com ESC ou GS enviaria comandos à impressora (abrir a gaveta, cortar o papel).
+func encodeCP860(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch b, ok := cp860[r]; {
+		case r < 0x20 || r == 0x7f:
+			out = append(out, '?')
+		case r < 0x80:
+			out = append(out, byte(r))
+		case ok:
+			out = append(out, b)
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// FileActivationStore guarda o mapa tenant → versão ativa num ficheiro JSON.
+type FileActivationStore struct {
+	path   string
+	mu     sync.RWMutex
+	active map[string]string
+}
+
+func NewFileActivationStore(path string) (interfaces.RuleActivationStore, error) {
+	s := &FileActivationStore{path: path, active: make(map[string]string)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	if err := json.Unmarshal(raw, &s.active); err != nil {
+		return nil, fmt.Errorf("ativações inválidas em %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileActivationStore) Active(ctx context.Context, tenant string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	version, ok := s.active[tenant]
+	return version, ok
+}
+
+func (s *FileActivationStore) All(ctx context.Context) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.active)
+}
+
+func (s *FileActivationStore) SetActive(ctx context.Context, tenant, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := maps.Clone(s.active)
+	next[tenant] = version
+	raw, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, raw, 0644); err != nil {
+		return err
+	}
+	s.active = next
+	return nil
+}
+
+// FileAuditLog acrescenta cada entrada como uma linha JSON (JSON Lines); o ficheiro
+// nunca é reescrito, só estendido.
+type FileAuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileAuditLog(path string) interfaces.AuditLog {
+	return &FileAuditLog{path: path}
+}
+
+func (a *FileAuditLog) Record(ctx context.Context, entry domain.AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir auditoria %s: %w", a.path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("falha ao gravar auditoria: %w", err)
+	}
+	return f.Sync()
+}
+
+func (a *FileAuditLog) List(ctx context.Context) ([]domain.AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao abrir auditoria %s: %w", a.path, err)
+	}
+	defer f.Close()
+
+	var entries []domain.AuditEntry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var entry domain.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("auditoria %s, linha %d: %w", a.path, n, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -1,56 +1,544 @@
 package infrastructure
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Victor-armando18/service-commercial/internal/domain"
 	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/pkg/expr"
+	"github.com/Victor-armando18/service-commercial/pkg/packfmt"
+	"github.com/Victor-armando18/service-commercial/pkg/rulesign"
 )
 
+const (
+	rulesFileStem   = "_rules"
+	rulesFileSuffix = rulesFileStem + ".json"
+)
+
+// packVersion extrai a versão do nome de um ficheiro de pack (JSON ou YAML).
+func packVersion(name string) (string, bool) {
+	for _, ext := range packfmt.Extensions {
+		if strings.HasSuffix(name, rulesFileStem+ext) {
+			return strings.TrimSuffix(name, rulesFileStem+ext), true
+		}
+	}
+	return "", false
+}
+
+// packEntry guarda um pack válido juntamente com a assinatura do ficheiro de origem.
+type packEntry struct {
+	def     *domain.RulePackDefinition
+	modTime time.Time
+	hash    string
+}
+
+// failedFile memoriza um ficheiro inválido para não o reprocessar enquanto não mudar.
+type failedFile struct {
+	modTime time.Time
+	err     error
+}
+
 type FileRuleLoader struct {
-	cache map[string]*domain.RulePackDefinition
-	mu    sync.RWMutex
+	cache     map[string]*packEntry
+	mu        sync.RWMutex
+	reloadMu  sync.Mutex // Serializa recargas concorrentes (watcher e endpoint admin)
+	failed    map[string]failedFile
+	fsys      fs.FS
+	source    string // Origem dos packs, usada nas mensagens de erro
+	validator interfaces.RulePackValidator
+	verifier  interfaces.RulePackVerifier
+	audit     interfaces.AuditLog // Publicações feitas por /admin/rules; essas versões são imutáveis
+	dir       string              // Diretório onde Publish grava; vazio em fs.FS só de leitura
 }
 
-func NewFileRuleLoader() interfaces.RulePackLoader {
-	return &FileRuleLoader{
-		cache: make(map[string]*domain.RulePackDefinition),
+// LoaderOption configura comportamentos opcionais do FileRuleLoader.
+type LoaderOption func(*FileRuleLoader)
+
+// WithVerifier exige que cada pack passe a verificação de assinatura antes de ser ativado.
+func WithVerifier(verifier interfaces.RulePackVerifier) LoaderOption {
+	return func(l *FileRuleLoader) {
+		l.verifier = verifier
 	}
 }
 
+// WithPublished torna imutáveis as versões cuja publicação está registada em audit: a
+// recarga recusa alterar ou remover o pack publicado. Os restantes ficheiros continuam a
+// ser substituídos e removidos a quente.
+func WithPublished(audit interfaces.AuditLog) LoaderOption {
+	return func(l *FileRuleLoader) {
+		l.audit = audit
+	}
+}
+
+// NewFileRuleLoader cria um loader sobre o diretório dir. Se validator não for nil, cada
+// pack é validado estaticamente ao ser lido e rejeitado se tiver diagnósticos de erro.
+func NewFileRuleLoader(dir string, validator interfaces.RulePackValidator, opts ...LoaderOption) interfaces.RulePackRepository {
+	l := newFSRuleLoader(os.DirFS(dir), dir, validator, opts)
+	l.dir = dir
+	return l
+}
+
+// NewFSRuleLoader cria um loader sobre qualquer fs.FS (ex: os packs embutidos com embed.FS).
+// Ficheiros sem mtime, como os embutidos, nunca são recarregados.
+func NewFSRuleLoader(fsys fs.FS, source string, validator interfaces.RulePackValidator, opts ...LoaderOption) interfaces.RulePackRepository {
+	return newFSRuleLoader(fsys, source, validator, opts)
+}
+
+func newFSRuleLoader(fsys fs.FS, source string, validator interfaces.RulePackValidator, opts []LoaderOption) *FileRuleLoader {
+	l := &FileRuleLoader{
+		cache:     make(map[string]*packEntry),
+		failed:    make(map[string]failedFile),
+		fsys:      fsys,
+		source:    source,
+		validator: validator,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
 func (l *FileRuleLoader) Load(ctx context.Context, version string) (*domain.RulePackDefinition, error) {
 	l.mu.RLock()
-	if def, ok := l.cache[version]; ok {
+	if entry, ok := l.cache[version]; ok {
 		l.mu.RUnlock()
-		return def, nil
+		return entry.def, nil
 	}
 	l.mu.RUnlock()
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if def, ok := l.cache[version]; ok {
-		return def, nil
+	if entry, ok := l.cache[version]; ok {
+		return entry.def, nil
+	}
+
+	path, err := l.findPack(version)
+	if err != nil {
+		return nil, err
+	}
+	modTime, err := l.fileStamp(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", l.source, err)
+	}
+	entry, err := l.readPack(version, path, modTime)
+	if err != nil {
+		return nil, err
+	}
+
+	l.cache[version] = entry
+	return entry.def, nil
+}
+
+// Reload compara o diretório de regras com a cache, por mtime e hash do conteúdo.
+// Packs novos ou alterados só substituem a versão em cache se forem válidos; um ficheiro
+// com erros mantém o último pack bom e fica registado no relatório. Ficheiros removidos
+// saem da cache. As versões publicadas por /admin/rules (ver WithPublished) são imutáveis:
+// alterá-las ou removê-las é reportado como erro e o pack publicado continua ativo.
+// A troca é atómica: Load vê sempre o conjunto anterior ou o novo.
+func (l *FileRuleLoader) Reload(ctx context.Context) (domain.ReloadReport, error) {
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	report := domain.ReloadReport{At: time.Now()}
+
+	files, err := fs.ReadDir(l.fsys, ".")
+	if err != nil {
+		return report, fmt.Errorf("falha ao listar %s: %w", l.source, err)
+	}
+
+	published, err := l.publishedHashes(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	l.mu.RLock()
+	next := make(map[string]*packEntry, len(l.cache))
+	for version, entry := range l.cache {
+		next[version] = entry
+	}
+	l.mu.RUnlock()
+
+	byVersion := make(map[string][]string)
+	var versions []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if version, ok := packVersion(f.Name()); ok {
+			if byVersion[version] == nil {
+				versions = append(versions, version)
+			}
+			byVersion[version] = append(byVersion[version], f.Name())
+		}
+	}
+
+	present := make(map[string]bool)
+	for _, version := range versions {
+		present[version] = true
+		if names := byVersion[version]; len(names) > 1 {
+			report.AddError(version, fmt.Errorf("versão %s definida em mais de um ficheiro: %v", version, names))
+			continue
+		}
+		name := byVersion[version][0]
+
+		modTime, err := l.fileStamp(name)
+		if err != nil {
+			report.AddError(version, err)
+			continue
+		}
+
+		current, known := next[version]
+		if known && current.modTime.Equal(modTime) {
+			continue
+		}
+		if prev, ok := l.failed[version]; ok && prev.modTime.Equal(modTime) {
+			report.AddError(version, prev.err)
+			continue
+		}
+
+		entry, err := l.readPack(version, name, modTime)
+		if err == nil && known && current.hash != entry.hash && published[version] == current.hash {
+			err = fmt.Errorf("%w: %s já publicada com o hash %s, o ficheiro tem %s; publique as alterações numa nova versão", domain.ErrRulePackExists, version, current.hash, entry.hash)
+		}
+		if err != nil {
+			l.failed[version] = failedFile{modTime: modTime, err: err}
+			report.AddError(version, err)
+			continue
+		}
+		delete(l.failed, version)
+
+		if known && current.hash == entry.hash {
+			// Só o mtime mudou (ex: touch); mantemos a definição já em uso
+			next[version] = &packEntry{def: current.def, modTime: entry.modTime, hash: current.hash}
+			continue
+		}
+
+		next[version] = entry
+		report.Loaded = append(report.Loaded, version)
+	}
+
+	for version := range next {
+		if present[version] {
+			continue
+		}
+		if published[version] == next[version].hash {
+			report.AddError(version, fmt.Errorf("%w: ficheiro de %s removido, a versão publicada continua ativa", domain.ErrRulePackExists, version))
+			continue
+		}
+		delete(next, version)
+		report.Removed = append(report.Removed, version)
+	}
+	for version := range l.failed {
+		if !present[version] {
+			delete(l.failed, version)
+		}
+	}
+
+	sort.Strings(report.Loaded)
+	sort.Strings(report.Removed)
+
+	l.mu.Lock()
+	l.cache = next
+	l.mu.Unlock()
+
+	return report, nil
+}
+
+// publishedHashes devolve, por versão, o hash da última publicação registada na auditoria.
+// Só o pack com esse hash é imutável: uma entrada sem pack correspondente (publicação que
+// falhou depois de auditada) não bloqueia o ficheiro.
+func (l *FileRuleLoader) publishedHashes(ctx context.Context) (map[string]string, error) {
+	if l.audit == nil {
+		return nil, nil
+	}
+	history, err := l.audit.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler publicações de RulePacks: %w", err)
+	}
+	published := make(map[string]string)
+	for _, entry := range history {
+		if entry.Action == domain.AuditRulePackPublished {
+			published[entry.Version] = entry.ContentHash
+		}
+	}
+	return published, nil
+}
+
+// Versions devolve as versões ativas em cache, ordenadas.
+func (l *FileRuleLoader) Versions(ctx context.Context) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	versions := make([]string, 0, len(l.cache))
+	for version := range l.cache {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Publish grava uma nova versão no diretório de regras depois de passar pelas mesmas
+// verificações de uma recarga (formato, schema, expressões, validação e assinatura).
+// Uma versão publicada nunca é substituída: enviar a mesma versão devolve ErrRulePackExists.
+func (l *FileRuleLoader) Publish(ctx context.Context, upload domain.RulePackUpload) (*domain.RulePackDefinition, error) {
+	if l.dir == "" {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRulePacksReadOnly, l.source)
+	}
+	var ext string
+	switch upload.Format {
+	case "json":
+		ext = ".json"
+	case "yaml":
+		ext = ".yaml"
+	default:
+		return nil, fmt.Errorf("%w: formato %q não suportado", domain.ErrInvalidRulePack, upload.Format)
+	}
+
+	doc, err := packfmt.ToJSON(rulesFileStem+ext, upload.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidRulePack, err)
+	}
+	var head struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(doc, &head); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidRulePack, err)
+	}
+	if !domain.ValidVersion(head.Version) {
+		return nil, fmt.Errorf("%w: versão %q inválida", domain.ErrInvalidRulePack, head.Version)
 	}
+	version := head.Version
 
-	filename := fmt.Sprintf("%s_rules.json", version)
-	path := filepath.Join("data", "rules", filename)
+	// Exclui recargas concorrentes enquanto os ficheiros são gravados
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
 
-	data, err := os.ReadFile(path)
+	l.mu.RLock()
+	current, cached := l.cache[version]
+	l.mu.RUnlock()
+	if cached {
+		return nil, fmt.Errorf("%w: %s (%s)", domain.ErrRulePackExists, version, current.hash)
+	}
+	if _, err := l.findPack(version); err == nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRulePackExists, version)
+	}
+
+	name := version + rulesFileStem + ext
+	entry, err := l.decodePack(version, name, upload.Content, upload.Signature, time.Time{})
 	if err != nil {
-		return nil, fmt.Errorf("falha ao ler ficheiro: %w", err)
+		if !errors.Is(err, domain.ErrInvalidRulePack) {
+			err = fmt.Errorf("%w: %w", domain.ErrInvalidRulePack, err)
+		}
+		return nil, err
+	}
+
+	// A assinatura é gravada primeiro para que o pack nunca fique visível sem ela
+	path := filepath.Join(l.dir, name)
+	if upload.Signature != nil {
+		if err := writeFileAtomic(path+rulesign.SignatureSuffix, upload.Signature, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeFileAtomic(path, upload.Content, 0644); err != nil {
+		return nil, err
+	}
+	if entry.modTime, err = l.fileStamp(name); err != nil {
+		return nil, err
 	}
 
+	l.mu.Lock()
+	l.cache[version] = entry
+	l.mu.Unlock()
+	delete(l.failed, version)
+
+	return entry.def, nil
+}
+
+// Unpublish retira a versão acabada de publicar: apaga o ficheiro e a assinatura e tira-a
+// da cache. Só serve para desfazer Publish quando a publicação não pôde ser auditada.
+func (l *FileRuleLoader) Unpublish(ctx context.Context, version string) error {
+	if l.dir == "" {
+		return fmt.Errorf("%w: %s", domain.ErrRulePacksReadOnly, l.source)
+	}
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	name, err := l.findPack(version)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.dir, name)
+	if err := os.Remove(path + rulesign.SignatureSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("falha ao retirar a assinatura de %s: %w", version, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("falha ao retirar %s: %w", version, err)
+	}
+
+	l.mu.Lock()
+	delete(l.cache, version)
+	l.mu.Unlock()
+	return nil
+}
+
+// Watch recarrega o diretório a cada intervalo até o contexto terminar. notify só é
+// chamado quando há packs novos/removidos ou o conjunto de erros muda, para que um
+// ficheiro partido seja reportado uma vez e não a cada ciclo.
+func (l *FileRuleLoader) Watch(ctx context.Context, interval time.Duration, notify func(domain.ReloadReport, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErrors map[string]string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := l.Reload(ctx)
+			if err != nil || report.Changed() || !maps.Equal(report.Errors, lastErrors) {
+				notify(report, err)
+			}
+			lastErrors = report.Errors
+		}
+	}
+}
+
+// findPack localiza o ficheiro da versão entre as extensões suportadas. Duas definições
+// da mesma versão (ex: JSON e YAML) são ambíguas e recusadas.
+func (l *FileRuleLoader) findPack(version string) (string, error) {
+	var found []string
+	for _, ext := range packfmt.Extensions {
+		name := version + rulesFileStem + ext
+		if _, err := fs.Stat(l.fsys, name); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("falha ao ler ficheiro em %s: %w", l.source, &fs.PathError{Op: "open", Path: version + rulesFileSuffix, Err: fs.ErrNotExist})
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("versão %s definida em mais de um ficheiro: %v", version, found)
+	}
+}
+
+// fileStamp devolve o mtime mais recente entre o pack e a sua assinatura, para que
+// substituir só o ficheiro .sig também provoque nova verificação.
+func (l *FileRuleLoader) fileStamp(path string) (time.Time, error) {
+	info, err := fs.Stat(l.fsys, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	stamp := info.ModTime()
+	if sigInfo, err := fs.Stat(l.fsys, path+rulesign.SignatureSuffix); err == nil && sigInfo.ModTime().After(stamp) {
+		stamp = sigInfo.ModTime()
+	}
+	return stamp, nil
+}
+
+func (l *FileRuleLoader) readPack(version, path string, modTime time.Time) (*packEntry, error) {
+	data, err := fs.ReadFile(l.fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", l.source, err)
+	}
+	var signature []byte
+	if l.verifier != nil {
+		signature, err = fs.ReadFile(l.fsys, path+rulesign.SignatureSuffix)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("falha ao ler assinatura de %s: %w", version, err)
+		}
+	}
+	return l.decodePack(version, path, data, signature, modTime)
+}
+
+// decodePack verifica e descodifica o conteúdo de um pack; path só determina o formato.
+func (l *FileRuleLoader) decodePack(version, path string, data, signature []byte, modTime time.Time) (*packEntry, error) {
+	if l.verifier != nil {
+		if err := l.verifier.Verify(data, signature); err != nil {
+			return nil, fmt.Errorf("rulepack %s recusado: %w", version, err)
+		}
+	}
+
+	// A assinatura cobre os bytes do ficheiro; a definição e o hash usam o JSON normalizado.
+	// Um pack YAML e o JSON equivalente só produzem o mesmo hash se os números estiverem
+	// escritos na forma que o YAML gera (0.2 e 50, não 0.20 nem 50.0): CanonicalPackHash
+	// mantém o texto de cada número, como explicado lá
+	doc, err := packfmt.ToJSON(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("rulepack %s: %w", version, err)
+	}
 	var def domain.RulePackDefinition
-	if err := json.Unmarshal(data, &def); err != nil {
+	if err := packfmt.DecodeStrict(doc, &def); err != nil {
+		return nil, fmt.Errorf("falha no unmarshal: %w", err)
+	}
+	hash, err := CanonicalPackHash(doc)
+	if err != nil {
 		return nil, fmt.Errorf("falha no unmarshal: %w", err)
 	}
+	def.ContentHash = hash
+
+	if err := compileExpressions(&def); err != nil {
+		return nil, err
+	}
+
+	if l.validator != nil {
+		if diags := l.validator.Validate(&def); domain.HasErrors(diags) {
+			return nil, &domain.RulePackValidationError{Version: version, Diagnostics: diags}
+		}
+	}
 
-	l.cache[version] = &def
-	return &def, nil
+	return &packEntry{def: &def, modTime: modTime, hash: hash}, nil
+}
+
+// CanonicalPackHash calcula o SHA-256 da forma canónica do JSON (chaves ordenadas, sem
+// espaços), para que reformatar o ficheiro não altere o hash mas mudar qualquer valor sim.
+// Os números mantêm o texto do ficheiro (UseNumber), pelo que 0.20 e 0.2 dão hashes
+// diferentes; normalizá-los mudaria o hash dos packs já publicados e das vendas gravadas.
+func CanonicalPackHash(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// compileExpressions converte o campo expr de cada regra na lógica JsonLogic equivalente.
+func compileExpressions(def *domain.RulePackDefinition) error {
+	for i := range def.Rules {
+		rule := &def.Rules[i]
+		if rule.Expr == "" {
+			continue
+		}
+		if rule.Logic != nil {
+			return fmt.Errorf("regra %s: logic e expr não podem ser usados em simultâneo", rule.ID)
+		}
+		logic, err := expr.Compile(rule.Expr)
+		if err != nil {
+			return fmt.Errorf("regra %s: expr inválida: %w", rule.ID, err)
+		}
+		rule.Logic = logic
+	}
+	return nil
 }
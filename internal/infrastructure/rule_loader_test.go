@@ -0,0 +1,267 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/pkg/rulesign"
+)
+
+func writePack(t *testing.T, dir, version, content string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, version+rulesFileSuffix)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, modTime, modTime)
+}
+
+func TestFileRuleLoader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	writePack(t, dir, "v1", `{"version": "v1", "description": "original", "rules": []}`, base)
+	loader := NewFileRuleLoader(dir, nil)
+
+	report, err := loader.Reload(ctx)
+	if err != nil || len(report.Loaded) != 1 {
+		t.Fatalf("carga inicial: %v %+v", err, report)
+	}
+
+	// Ficheiro partido: o pack anterior continua ativo e o erro é reportado
+	writePack(t, dir, "v1", `{"version": "v1", "rules": [`, base.Add(time.Minute))
+	report, _ = loader.Reload(ctx)
+	if report.Errors["v1"] == "" || report.Changed() {
+		t.Fatalf("esperado erro sem alterações, recebido %+v", report)
+	}
+	if def, _ := loader.Load(ctx, "v1"); def == nil || def.Description != "original" {
+		t.Fatalf("pack anterior perdido: %+v", def)
+	}
+
+	// Ficheiro corrigido: a nova versão substitui a anterior
+	writePack(t, dir, "v1", `{"version": "v1", "description": "corrigido", "rules": []}`, base.Add(2*time.Minute))
+	report, _ = loader.Reload(ctx)
+	if len(report.Loaded) != 1 || len(report.Errors) != 0 {
+		t.Fatalf("esperada recarga de v1, recebido %+v", report)
+	}
+	if def, _ := loader.Load(ctx, "v1"); def.Description != "corrigido" {
+		t.Fatalf("pack não foi substituído: %+v", def)
+	}
+
+	// Só o mtime muda: o conteúdo é o mesmo, nada é recarregado
+	os.Chtimes(filepath.Join(dir, "v1"+rulesFileSuffix), base.Add(3*time.Minute), base.Add(3*time.Minute))
+	if report, _ = loader.Reload(ctx); report.Changed() {
+		t.Fatalf("touch não deve recarregar: %+v", report)
+	}
+
+	// Ficheiro removido: a versão deixa de estar disponível
+	os.Remove(filepath.Join(dir, "v1"+rulesFileSuffix))
+	report, _ = loader.Reload(ctx)
+	if len(report.Removed) != 1 {
+		t.Fatalf("esperada remoção de v1, recebido %+v", report)
+	}
+	if _, err := loader.Load(ctx, "v1"); err == nil {
+		t.Fatal("v1 não deveria carregar depois de removido")
+	}
+}
+
+func TestFileRuleLoader_PublishedImmutable(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	writePack(t, dir, "v1", `{"version": "v1", "description": "original", "rules": []}`, base)
+	writePack(t, dir, "v2", `{"version": "v2", "description": "original", "rules": []}`, base)
+	audit := NewFileAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	loader := NewFileRuleLoader(dir, nil, WithPublished(audit))
+	if _, err := loader.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// v1 publicada por /admin/rules; a entrada de v2 não corresponde ao pack carregado
+	def, _ := loader.Load(ctx, "v1")
+	audit.Record(ctx, domain.AuditEntry{Action: domain.AuditRulePackPublished, Version: "v1", ContentHash: def.ContentHash})
+	audit.Record(ctx, domain.AuditEntry{Action: domain.AuditRulePackPublished, Version: "v2", ContentHash: "outro"})
+
+	writePack(t, dir, "v1", `{"version": "v1", "description": "corrigido", "rules": []}`, base.Add(time.Minute))
+	writePack(t, dir, "v2", `{"version": "v2", "description": "corrigido", "rules": []}`, base.Add(time.Minute))
+	report, _ := loader.Reload(ctx)
+	if !strings.Contains(report.Errors["v1"], domain.ErrRulePackExists.Error()) || len(report.Loaded) != 1 || report.Loaded[0] != "v2" {
+		t.Fatalf("esperada recusa de v1 e recarga de v2, recebido %+v", report)
+	}
+	if def, _ := loader.Load(ctx, "v1"); def.Description != "original" {
+		t.Fatalf("pack publicado foi substituído: %+v", def)
+	}
+
+	// Removida, a versão publicada continua ativa
+	os.Remove(filepath.Join(dir, "v1"+rulesFileSuffix))
+	report, _ = loader.Reload(ctx)
+	if report.Errors["v1"] == "" || len(report.Removed) != 0 {
+		t.Fatalf("esperado erro de remoção de v1, recebido %+v", report)
+	}
+	if def, err := loader.Load(ctx, "v1"); err != nil || def.Description != "original" {
+		t.Fatalf("v1 deveria continuar disponível: %+v %v", def, err)
+	}
+}
+
+func TestFileRuleLoader_Signatures(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	pub, priv, err := rulesign.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, _ := rulesign.GenerateKey()
+
+	sign := func(version string, key ed25519.PrivateKey, modTime time.Time) {
+		path := filepath.Join(dir, version+rulesFileSuffix)
+		content, _ := os.ReadFile(path)
+		sig, err := rulesign.Sign(key, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(path+rulesign.SignatureSuffix, sig, 0644)
+		os.Chtimes(path+rulesign.SignatureSuffix, modTime, modTime)
+	}
+
+	writePack(t, dir, "signed", `{"version": "signed", "rules": []}`, base)
+	sign("signed", priv, base)
+	writePack(t, dir, "unsigned", `{"version": "unsigned", "rules": []}`, base)
+	writePack(t, dir, "foreign", `{"version": "foreign", "rules": []}`, base)
+	sign("foreign", otherPriv, base)
+
+	// Fora de produção só a assinatura de chave desconhecida é recusada
+	loader := NewFileRuleLoader(dir, nil, WithVerifier(NewSignatureVerifier([]ed25519.PublicKey{pub}, false)))
+	report, _ := loader.Reload(ctx)
+	if len(report.Loaded) != 2 || !strings.Contains(report.Errors["foreign"], rulesign.ErrUnknownKey.Error()) {
+		t.Fatalf("modo desenvolvimento: %+v", report)
+	}
+
+	// Em produção o pack sem assinatura também é recusado
+	loader = NewFileRuleLoader(dir, nil, WithVerifier(NewSignatureVerifier([]ed25519.PublicKey{pub}, true)))
+	report, _ = loader.Reload(ctx)
+	if len(report.Loaded) != 1 || report.Errors["unsigned"] == "" || report.Errors["foreign"] == "" {
+		t.Fatalf("modo produção: %+v", report)
+	}
+
+	// Pack alterado depois de assinado: mantém-se a versão verificada
+	writePack(t, dir, "signed", `{"version": "signed", "description": "alterado", "rules": []}`, base.Add(time.Minute))
+	report, _ = loader.Reload(ctx)
+	if report.Errors["signed"] == "" {
+		t.Fatalf("pack adulterado deveria ser recusado: %+v", report)
+	}
+	if def, _ := loader.Load(ctx, "signed"); def == nil || def.Description != "" {
+		t.Fatalf("pack verificado perdido: %+v", def)
+	}
+
+	// Nova assinatura sobre o conteúdo alterado: a recarga aceita-o
+	sign("signed", priv, base.Add(2*time.Minute))
+	report, _ = loader.Reload(ctx)
+	if len(report.Loaded) != 1 || report.Errors["signed"] != "" {
+		t.Fatalf("pack reassinado deveria recarregar: %+v", report)
+	}
+}
+
+func TestFileRuleLoader_YAMLAndStrictDecoding(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	writePack(t, dir, "json", `{"version": "json", "rules": [{"id": "R1", "phase": "totals", "logic": {"+": [1, 2]}, "output_key": "order.totalValue"}]}`, base)
+	yamlPack := `# Pack equivalente ao JSON, em YAML
+version: json
+rules:
+  - id: R1
+    phase: totals   # fase final
+    logic: {"+": [1, 2]}
+    output_key: order.totalValue
+`
+	os.WriteFile(filepath.Join(dir, "yaml_rules.yaml"), []byte(strings.Replace(yamlPack, "version: json", "version: yaml", 1)), 0644)
+	os.WriteFile(filepath.Join(dir, "same_rules.yml"), []byte(yamlPack), 0644)
+	writePack(t, dir, "typo", `{"version": "typo", "rules": [{"id": "R1", "phase": "totals", "logic": {"+": [1, 2]}, "outputKey": "order.totalValue"}]}`, base)
+	writePack(t, dir, "dup", `{"version": "dup", "rules": []}`, base)
+	os.WriteFile(filepath.Join(dir, "dup_rules.yaml"), []byte("version: dup\nrules: []\n"), 0644)
+
+	loader := NewFileRuleLoader(dir, nil)
+	report, err := loader.Reload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Loaded) != 3 || report.Errors["typo"] == "" || report.Errors["dup"] == "" {
+		t.Fatalf("relatório inesperado: %+v", report)
+	}
+	if !strings.Contains(report.Errors["typo"], "outputKey") {
+		t.Fatalf("campo desconhecido não identificado: %s", report.Errors["typo"])
+	}
+
+	fromJSON, _ := loader.Load(ctx, "json")
+	fromYAML, _ := loader.Load(ctx, "same")
+	if fromYAML.Rules[0].OutputKey != "order.totalValue" || fromJSON.ContentHash != fromYAML.ContentHash {
+		t.Fatalf("YAML não normalizado como o JSON: %+v / %s != %s", fromYAML.Rules, fromYAML.ContentHash, fromJSON.ContentHash)
+	}
+
+	// O hash mantém o texto de cada número: 0.20 e 0.2 não são o mesmo conteúdo
+	short, _ := CanonicalPackHash([]byte(`{"rate": 0.2}`))
+	padded, _ := CanonicalPackHash([]byte(`{"rate": 0.20}`))
+	if short == padded {
+		t.Fatal("0.20 e 0.2 deveriam ter hashes diferentes")
+	}
+}
+
+// O schema publicado tem de descrever exatamente os campos que o descodificador estrito aceita.
+func TestRulePackSchema_MatchesDefinition(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("..", "..", "data", "schema", "rulepack.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		props map[string]json.RawMessage
+		typ   reflect.Type
+	}{
+		"rulepack":  {schema.Properties, reflect.TypeOf(domain.RulePackDefinition{})},
+		"rule":      {schema.Defs["rule"].Properties, reflect.TypeOf(domain.RuleConfig{})},
+		"testCase":  {schema.Defs["testCase"].Properties, reflect.TypeOf(domain.RuleTestCase{})},
+		"order":     {schema.Defs["order"].Properties, reflect.TypeOf(domain.Order{})},
+		"orderItem": {schema.Defs["orderItem"].Properties, reflect.TypeOf(domain.OrderItem{})},
+	}
+	for name, c := range cases {
+		fields := make(map[string]bool)
+		for i := 0; i < c.typ.NumField(); i++ {
+			tag := strings.Split(c.typ.Field(i).Tag.Get("json"), ",")[0]
+			if tag != "" && tag != "-" {
+				fields[tag] = true
+			}
+		}
+		for tag := range fields {
+			if _, ok := c.props[tag]; !ok {
+				t.Errorf("%s: campo %q ausente do schema", name, tag)
+			}
+		}
+		for prop := range c.props {
+			if !fields[prop] {
+				t.Errorf("%s: propriedade %q do schema não existe em %s", name, prop, c.typ.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package infrastructure
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/pkg/rulesign"
+)
+
+// SignatureVerifier valida as assinaturas Ed25519 dos packs contra as chaves confiáveis.
+// Com required (modo produção) um pack sem assinatura é recusado; fora dele só é
+// recusado quando a assinatura existe e não confere.
+type SignatureVerifier struct {
+	keyring  rulesign.Keyring
+	required bool
+}
+
+func NewSignatureVerifier(keys []ed25519.PublicKey, required bool) interfaces.RulePackVerifier {
+	return &SignatureVerifier{keyring: rulesign.NewKeyring(keys...), required: required}
+}
+
+// LoadTrustedKeys lê as chaves públicas PEM indicadas.
+func LoadTrustedKeys(paths []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler chave %s: %w", path, err)
+		}
+		pub, err := rulesign.ParsePublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("chave %s inválida: %w", path, err)
+		}
+		keys = append(keys, pub)
+	}
+	return keys, nil
+}
+
+func (v *SignatureVerifier) Verify(content, signature []byte) error {
+	if signature == nil && !v.required {
+		return nil
+	}
+	return v.keyring.Verify(content, signature)
+}
@@ -0,0 +1,218 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	bolt "go.etcd.io/bbolt"
+)
+
+const salesDBFile = "sales.db"
+
+var (
+	salesBucket      = []byte("sales")       // ID → venda (JSON)
+	salesOrderBucket = []byte("sales_order") // Sequência → ID, para listar por ordem de gravação
+	seriesBucket     = []byte("series")      // SeriesKey → último número emitido
+	seriesHashBucket = []byte("series_hash") // SeriesKey → hash do último documento assinado
+)
+
+// BoltSalesRepository guarda as vendas numa base de dados chave-valor embutida (bbolt),
+// para volumes que não cabem confortavelmente em memória. Cada Save é uma transação
+// sincronizada em disco; o próprio bbolt impede a abertura por um segundo processo.
+type BoltSalesRepository struct {
+	db *bolt.DB
+}
+
+func NewBoltSalesRepository(dir string) (interfaces.SalesRepository, error) {
+	path := filepath.Join(dir, salesDBFile)
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrSalesLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao abrir %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		sales, err := tx.CreateBucketIfNotExists(salesBucket)
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{salesOrderBucket, seriesBucket, seriesHashBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if sales.Stats().KeyN > 0 {
+			return nil
+		}
+		legacy, err := readLegacySales(filepath.Join(dir, legacySalesFile))
+		if err != nil {
+			return err
+		}
+		for _, sale := range legacy {
+			if err := putSale(tx, sale); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("falha ao preparar %s: %w", path, err)
+	}
+	return &BoltSalesRepository{db: db}, nil
+}
+
+func (r *BoltSalesRepository) Save(ctx context.Context, sale domain.Sale) error {
+	_, err := r.save(sale, false, nil)
+	return err
+}
+
+func (r *BoltSalesRepository) Issue(ctx context.Context, sale domain.Sale, signer interfaces.DocumentSigner) (domain.Sale, error) {
+	return r.save(sale, true, signer)
+}
+
+// save numera e grava a venda na mesma transação: se a transação falhar, o contador da
+// série também não avança.
+func (r *BoltSalesRepository) save(sale domain.Sale, issue bool, signer interfaces.DocumentSigner) (domain.Sale, error) {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(salesBucket).Get([]byte(sale.ID)) != nil {
+			return fmt.Errorf("%w: %s", domain.ErrSaleExists, sale.ID)
+		}
+		var last domain.FiscalDocument
+		if sale.Document != nil {
+			key := []byte(sale.Document.SeriesKey())
+			if raw := tx.Bucket(seriesBucket).Get(key); raw != nil {
+				last.Number = int64(binary.BigEndian.Uint64(raw))
+			}
+			last.Hash = string(tx.Bucket(seriesHashBucket).Get(key))
+		}
+		numbered, err := nextDocument(sale, last, issue, signer)
+		if err != nil {
+			return err
+		}
+		sale = numbered
+		return putSale(tx, sale)
+	})
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	return sale, nil
+}
+
+// Void regrava a venda com a anulação, mantendo a posição na ordem de gravação.
+func (r *BoltSalesRepository) Void(ctx context.Context, id string, void domain.SaleVoid) (domain.Sale, error) {
+	var sale domain.Sale
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(salesBucket)
+		raw := bucket.Get([]byte(id))
+		if raw == nil {
+			return fmt.Errorf("%w: %s", domain.ErrSaleNotFound, id)
+		}
+		if err := json.Unmarshal(raw, &sale); err != nil {
+			return fmt.Errorf("venda %s inválida: %w", id, err)
+		}
+		if sale.Void != nil {
+			return fmt.Errorf("%w: %s", domain.ErrSaleVoided, id)
+		}
+		sale.Void = &void
+		updated, err := json.Marshal(sale)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id), updated)
+	})
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	return sale, nil
+}
+
+func (r *BoltSalesRepository) Get(ctx context.Context, id string) (domain.Sale, error) {
+	var sale domain.Sale
+	err := r.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(salesBucket).Get([]byte(id))
+		if raw == nil {
+			return fmt.Errorf("%w: %s", domain.ErrSaleNotFound, id)
+		}
+		return json.Unmarshal(raw, &sale)
+	})
+	return sale, err
+}
+
+func (r *BoltSalesRepository) All(ctx context.Context) ([]domain.Sale, error) {
+	var sales []domain.Sale
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(salesBucket)
+		return tx.Bucket(salesOrderBucket).ForEach(func(_, id []byte) error {
+			var sale domain.Sale
+			if err := json.Unmarshal(bucket.Get(id), &sale); err != nil {
+				return fmt.Errorf("venda %s inválida: %w", id, err)
+			}
+			sales = append(sales, sale)
+			return nil
+		})
+	})
+	return sales, err
+}
+
+// Query percorre todas as vendas (não há índices secundários) e ordena só as que passam nos filtros.
+func (r *BoltSalesRepository) Query(ctx context.Context, q domain.SalesQuery) (domain.SalesPage, error) {
+	var matching []domain.Sale
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(salesBucket).ForEach(func(id, raw []byte) error {
+			var sale domain.Sale
+			if err := json.Unmarshal(raw, &sale); err != nil {
+				return fmt.Errorf("venda %s inválida: %w", id, err)
+			}
+			if q.Matches(sale) {
+				matching = append(matching, sale)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return domain.SalesPage{}, err
+	}
+	return pageSales(matching, q)
+}
+
+func (r *BoltSalesRepository) Close() error {
+	return r.db.Close()
+}
+
+func putSale(tx *bolt.Tx, sale domain.Sale) error {
+	raw, err := json.Marshal(sale)
+	if err != nil {
+		return err
+	}
+	order := tx.Bucket(salesOrderBucket)
+	seq, err := order.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	if err := order.Put(key, []byte(sale.ID)); err != nil {
+		return err
+	}
+	if doc := sale.Document; doc != nil {
+		counter := make([]byte, 8)
+		binary.BigEndian.PutUint64(counter, uint64(doc.Number))
+		if err := tx.Bucket(seriesBucket).Put([]byte(doc.SeriesKey()), counter); err != nil {
+			return err
+		}
+		if err := tx.Bucket(seriesHashBucket).Put([]byte(doc.SeriesKey()), []byte(doc.Hash)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(salesBucket).Put([]byte(sale.ID), raw)
+}
@@ -0,0 +1,283 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Ficheiros do repositório de vendas dentro do diretório de dados
+const (
+	salesLogFile    = "sales.jsonl"
+	salesLockFile   = "sales.lock"
+	legacySalesFile = "sales.json" // Array JSON das versões anteriores, importado na primeira abertura
+)
+
+// FileSalesRepository guarda as vendas em JSON Lines, só por acréscimo: cada venda é uma
+// linha escrita e sincronizada (fsync) antes de Save retornar. Uma linha incompleta no
+// fim do ficheiro, deixada por uma queda a meio da escrita, é descartada na abertura.
+// As vendas ficam também em memória para leitura.
+type FileSalesRepository struct {
+	path  string
+	lock  *os.File
+	mu    sync.RWMutex
+	log   *os.File
+	size  int64
+	sales []domain.Sale
+	index map[string]int
+	// series guarda o último documento de cada série (SeriesKey), reconstruído do ficheiro
+	series map[string]domain.FiscalDocument
+}
+
+func NewFileSalesRepository(dir string) (interfaces.SalesRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("falha ao criar diretório %s: %w", dir, err)
+	}
+	lock, err := lockFile(filepath.Join(dir, salesLockFile))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FileSalesRepository{path: filepath.Join(dir, salesLogFile), lock: lock, index: make(map[string]int), series: make(map[string]domain.FiscalDocument)}
+	compact, err := r.load()
+	if errors.Is(err, os.ErrNotExist) {
+		var legacy []domain.Sale
+		if legacy, err = readLegacySales(filepath.Join(dir, legacySalesFile)); err == nil {
+			for _, sale := range legacy {
+				r.add(sale)
+			}
+			compact = true
+		}
+	}
+	if err == nil && compact {
+		err = r.compact()
+	}
+	if err == nil && r.log == nil {
+		err = r.open()
+	}
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileSalesRepository) Save(ctx context.Context, sale domain.Sale) error {
+	_, err := r.save(sale, false, nil)
+	return err
+}
+
+func (r *FileSalesRepository) Issue(ctx context.Context, sale domain.Sale, signer interfaces.DocumentSigner) (domain.Sale, error) {
+	return r.save(sale, true, signer)
+}
+
+// save numera e grava a venda sob o mesmo lock: o número só passa a contar como emitido
+// depois de a linha estar em disco.
+func (r *FileSalesRepository) save(sale domain.Sale, issue bool, signer interfaces.DocumentSigner) (domain.Sale, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.index[sale.ID]; ok {
+		return domain.Sale{}, fmt.Errorf("%w: %s", domain.ErrSaleExists, sale.ID)
+	}
+	var last domain.FiscalDocument
+	if sale.Document != nil {
+		last = r.series[sale.Document.SeriesKey()]
+	}
+	sale, err := nextDocument(sale, last, issue, signer)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+
+	if err := r.append(sale); err != nil {
+		return domain.Sale{}, err
+	}
+	return sale, nil
+}
+
+// Void acrescenta a venda anulada como uma nova linha, que substitui a anterior.
+func (r *FileSalesRepository) Void(ctx context.Context, id string, void domain.SaleVoid) (domain.Sale, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[id]
+	if !ok {
+		return domain.Sale{}, fmt.Errorf("%w: %s", domain.ErrSaleNotFound, id)
+	}
+	sale := r.sales[i]
+	if sale.Void != nil {
+		return domain.Sale{}, fmt.Errorf("%w: %s", domain.ErrSaleVoided, id)
+	}
+	sale.Void = &void
+	if err := r.append(sale); err != nil {
+		return domain.Sale{}, err
+	}
+	return sale, nil
+}
+
+// append escreve a linha da venda, sincroniza-a em disco e só então a regista em memória.
+func (r *FileSalesRepository) append(sale domain.Sale) error {
+	line, err := json.Marshal(sale)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = r.log.Write(line)
+	if err == nil {
+		err = r.log.Sync()
+	}
+	if err != nil {
+		// Remove o que tenha ficado escrito, para não deixar uma linha parcial no meio do ficheiro
+		r.log.Truncate(r.size)
+		return fmt.Errorf("falha ao gravar %s: %w", r.path, err)
+	}
+	r.size += int64(len(line))
+	r.add(sale)
+	return nil
+}
+
+func (r *FileSalesRepository) Get(ctx context.Context, id string) (domain.Sale, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.index[id]
+	if !ok {
+		return domain.Sale{}, fmt.Errorf("%w: %s", domain.ErrSaleNotFound, id)
+	}
+	return r.sales[i], nil
+}
+
+func (r *FileSalesRepository) All(ctx context.Context) ([]domain.Sale, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]domain.Sale(nil), r.sales...), nil
+}
+
+func (r *FileSalesRepository) Query(ctx context.Context, q domain.SalesQuery) (domain.SalesPage, error) {
+	r.mu.RLock()
+	var matching []domain.Sale
+	for _, sale := range r.sales {
+		if q.Matches(sale) {
+			matching = append(matching, sale)
+		}
+	}
+	r.mu.RUnlock()
+	return pageSales(matching, q)
+}
+
+func (r *FileSalesRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.log.Close()
+	if cerr := r.lock.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// add regista a venda em memória; uma linha posterior com o mesmo ID substitui a anterior.
+func (r *FileSalesRepository) add(sale domain.Sale) {
+	if doc := sale.Document; doc != nil && doc.Number > r.series[doc.SeriesKey()].Number {
+		r.series[doc.SeriesKey()] = *doc
+	}
+	if i, ok := r.index[sale.ID]; ok {
+		r.sales[i] = sale
+		return
+	}
+	r.index[sale.ID] = len(r.sales)
+	r.sales = append(r.sales, sale)
+}
+
+// load lê o ficheiro e indica se deve ser compactado (linha final incompleta ou IDs repetidos).
+func (r *FileSalesRepository) load() (bool, error) {
+	raw, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, err
+	}
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		lines++
+		var sale domain.Sale
+		if err := json.Unmarshal(scanner.Bytes(), &sale); err != nil {
+			if !bytes.HasSuffix(raw, []byte("\n")) && int64(len(raw)) == r.size+int64(len(scanner.Bytes())) {
+				return true, nil
+			}
+			return false, fmt.Errorf("venda inválida em %s, linha %d: %w", r.path, lines, err)
+		}
+		r.size += int64(len(scanner.Bytes())) + 1
+		r.add(sale)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("falha ao ler %s: %w", r.path, err)
+	}
+	return lines != len(r.sales), nil
+}
+
+// compact reescreve o ficheiro só com a última versão de cada venda, de forma atómica.
+func (r *FileSalesRepository) compact() error {
+	var buf bytes.Buffer
+	for _, sale := range r.sales {
+		line, err := json.Marshal(sale)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := writeFileAtomic(r.path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	r.size = int64(buf.Len())
+	return r.open()
+}
+
+func (r *FileSalesRepository) open() error {
+	if r.log != nil {
+		r.log.Close()
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("falha ao abrir %s: %w", r.path, err)
+	}
+	r.log = file
+	return nil
+}
+
+// readLegacySales lê o sales.json das versões anteriores (array JSON); sem ficheiro não há vendas.
+func readLegacySales(path string) ([]domain.Sale, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	var sales []domain.Sale
+	if err := json.Unmarshal(raw, &sales); err != nil {
+		return nil, fmt.Errorf("vendas inválidas em %s: %w", path, err)
+	}
+	// Os IDs antigos tinham resolução de segundos; uma colisão tem de ser resolvida à mão
+	seen := make(map[string]bool, len(sales))
+	for i, sale := range sales {
+		if seen[sale.ID] {
+			return nil, fmt.Errorf("vendas inválidas em %s: ID %s repetido", path, sale.ID)
+		}
+		seen[sale.ID] = true
+		// As vendas antigas não tinham createdAt, mas o ID era SALE-<data e hora local>
+		if stamp := strings.TrimPrefix(sale.ID, "SALE-"); sale.CreatedAt.IsZero() && len(stamp) >= 14 {
+			if at, err := time.ParseInLocation("20060102150405", stamp[:14], time.Local); err == nil {
+				sales[i].CreatedAt = at
+			}
+		}
+	}
+	return sales, nil
+}
@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// Tamanho das páginas das consultas de vendas
+const (
+	defaultSalesPageSize = 50
+	maxSalesPageSize     = 500
+)
+
+// salesCursor guarda a chave de ordenação da última venda devolvida; a página seguinte
+// começa na primeira venda depois dela, pelo que vendas novas não desalinham as páginas.
+type salesCursor struct {
+	Sort       string    `json:"s"`
+	CreatedAt  time.Time `json:"c"`
+	TotalValue float64   `json:"t"`
+	ID         string    `json:"i"`
+}
+
+// pageSales ordena as vendas que já satisfazem os filtros e devolve a página pedida.
+// O ID desempata a ordenação, para que a ordem seja total e o cursor inequívoco.
+func pageSales(matching []domain.Sale, q domain.SalesQuery) (domain.SalesPage, error) {
+	sortKey := q.Sort
+	if sortKey == "" {
+		sortKey = "-" + domain.SortSalesByCreatedAt
+	}
+	compare, err := salesComparator(sortKey)
+	if err != nil {
+		return domain.SalesPage{}, err
+	}
+	if q.Status != "" && q.Status != domain.SaleStatusActive && q.Status != domain.SaleStatusVoided {
+		return domain.SalesPage{}, fmt.Errorf("%w: status %q desconhecido (use %s ou %s)", domain.ErrInvalidSalesQuery, q.Status, domain.SaleStatusActive, domain.SaleStatusVoided)
+	}
+	limit := q.Limit
+	switch {
+	case limit < 0:
+		return domain.SalesPage{}, fmt.Errorf("%w: limit não pode ser negativo", domain.ErrInvalidSalesQuery)
+	case limit == 0:
+		limit = defaultSalesPageSize
+	case limit > maxSalesPageSize:
+		limit = maxSalesPageSize
+	}
+
+	slices.SortFunc(matching, compare)
+	start := 0
+	if q.Cursor != "" {
+		after, err := decodeSalesCursor(q.Cursor, sortKey)
+		if err != nil {
+			return domain.SalesPage{}, err
+		}
+		start = sort.Search(len(matching), func(i int) bool { return compare(matching[i], after) > 0 })
+	}
+	end := min(start+limit, len(matching))
+
+	page := domain.SalesPage{Sales: append([]domain.Sale{}, matching[start:end]...)}
+	if end < len(matching) {
+		last := matching[end-1]
+		page.NextCursor = encodeSalesCursor(salesCursor{Sort: sortKey, CreatedAt: last.CreatedAt, TotalValue: last.TotalValue, ID: last.ID})
+	}
+	return page, nil
+}
+
+func salesComparator(sortKey string) (func(a, b domain.Sale) int, error) {
+	var compare func(a, b domain.Sale) int
+	switch strings.TrimPrefix(sortKey, "-") {
+	case domain.SortSalesByCreatedAt:
+		compare = func(a, b domain.Sale) int {
+			return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+		}
+	case domain.SortSalesByTotalValue:
+		compare = func(a, b domain.Sale) int {
+			return cmp.Or(cmp.Compare(a.TotalValue, b.TotalValue), strings.Compare(a.ID, b.ID))
+		}
+	case domain.SortSalesByID:
+		compare = func(a, b domain.Sale) int { return strings.Compare(a.ID, b.ID) }
+	default:
+		return nil, fmt.Errorf("%w: ordenação %q desconhecida (use createdAt, totalValue ou id)", domain.ErrInvalidSalesQuery, sortKey)
+	}
+	if strings.HasPrefix(sortKey, "-") {
+		return func(a, b domain.Sale) int { return compare(b, a) }, nil
+	}
+	return compare, nil
+}
+
+func encodeSalesCursor(c salesCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSalesCursor(cursor, sortKey string) (domain.Sale, error) {
+	var c salesCursor
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(raw, &c)
+	}
+	if err != nil {
+		return domain.Sale{}, fmt.Errorf("%w: cursor inválido", domain.ErrInvalidSalesQuery)
+	}
+	if c.Sort != sortKey {
+		return domain.Sale{}, fmt.Errorf("%w: o cursor foi gerado com a ordenação %q", domain.ErrInvalidSalesQuery, c.Sort)
+	}
+	return domain.Sale{Order: domain.Order{ID: c.ID, TotalValue: c.TotalValue}, CreatedAt: c.CreatedAt}, nil
+}
@@ -0,0 +1,279 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+func TestSalesRepositories(t *testing.T) {
+	for name, open := range map[string]func(string) (interfaces.SalesRepository, error){
+		"file": NewFileSalesRepository,
+		"kv":   NewBoltSalesRepository,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := context.Background()
+			legacy := `[{"id": "SALE-OLD", "currency": "AOA", "totalValue": 10}]`
+			os.WriteFile(filepath.Join(dir, legacySalesFile), []byte(legacy), 0o644)
+
+			repo, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if err := repo.Save(ctx, domain.Sale{Order: domain.Order{ID: fmt.Sprintf("SALE-%02d", i), Currency: "AOA"}}); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if err := repo.Save(ctx, domain.Sale{Order: domain.Order{ID: "SALE-01"}}); !errors.Is(err, domain.ErrSaleExists) {
+				t.Fatalf("esperado ErrSaleExists, recebido %v", err)
+			}
+			repo.Close()
+
+			reopened, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			all, err := reopened.All(ctx)
+			if err != nil || len(all) != 21 || all[0].ID != "SALE-OLD" {
+				t.Fatalf("esperadas 21 vendas com a importada primeiro, recebidas %d (%v)", len(all), err)
+			}
+			if sale, err := reopened.Get(ctx, "SALE-07"); err != nil || sale.Currency != "AOA" {
+				t.Fatalf("Get falhou: %v", err)
+			}
+			if _, err := reopened.Get(ctx, "SALE-XX"); !errors.Is(err, domain.ErrSaleNotFound) {
+				t.Fatalf("esperado ErrSaleNotFound, recebido %v", err)
+			}
+		})
+	}
+}
+
+func TestSalesRepositories_Query(t *testing.T) {
+	for name, open := range map[string]func(string) (interfaces.SalesRepository, error){
+		"file": NewFileSalesRepository,
+		"kv":   NewBoltSalesRepository,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo, err := open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.Close()
+			base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < 10; i++ {
+				sale := domain.Sale{Order: domain.Order{ID: fmt.Sprintf("SALE-%02d", i), Currency: "AOA", TotalValue: float64(i * 100)}, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+				if i%2 == 0 {
+					sale.Items = []domain.OrderItem{{SKU: "PAO"}}
+				}
+				if i < 3 {
+					sale.RulesVersion = "v1.2"
+				}
+				repo.Save(ctx, sale)
+			}
+
+			// Todas as vendas com PAO e total >= 200, três por página, por total crescente
+			minTotal := 200.0
+			q := domain.SalesQuery{SKU: "PAO", MinTotal: &minTotal, Sort: "totalValue", Limit: 3}
+			var ids []string
+			for {
+				page, err := repo.Query(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, sale := range page.Sales {
+					ids = append(ids, sale.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			if got := strings.Join(ids, ","); got != "SALE-02,SALE-04,SALE-06,SALE-08" {
+				t.Fatalf("páginas inesperadas: %s", got)
+			}
+
+			// Intervalo de datas [01:00, 03:00), ordem de omissão (mais recentes primeiro)
+			from, to := base.Add(time.Hour), base.Add(3*time.Hour)
+			page, err := repo.Query(ctx, domain.SalesQuery{From: &from, To: &to})
+			if err != nil || len(page.Sales) != 2 || page.Sales[0].ID != "SALE-02" || page.NextCursor != "" {
+				t.Fatalf("filtro por data falhou: %+v (%v)", page, err)
+			}
+
+			// A versão das regras aceita-se com ou sem o prefixo "v", como no motor
+			if page, err := repo.Query(ctx, domain.SalesQuery{RulesVersion: "1.2"}); err != nil || len(page.Sales) != 3 {
+				t.Fatalf("filtro por rulesVersion=1.2: esperadas 3 vendas, recebido %+v (%v)", page, err)
+			}
+
+			if _, err := repo.Query(ctx, domain.SalesQuery{Sort: "id", Cursor: q.Cursor}); !errors.Is(err, domain.ErrInvalidSalesQuery) {
+				t.Fatalf("cursor de outra ordenação deveria ser recusado, recebido %v", err)
+			}
+		})
+	}
+}
+
+func TestSalesRepositories_Issue(t *testing.T) {
+	for name, open := range map[string]func(string) (interfaces.SalesRepository, error){
+		"file": NewFileSalesRepository,
+		"kv":   NewBoltSalesRepository,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := context.Background()
+			repo, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ft := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+			fr := domain.FiscalDocument{Type: domain.DocumentInvoiceReceipt, Series: "A"}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					doc := ft
+					if i >= 7 {
+						doc = fr
+					}
+					if _, err := repo.Issue(ctx, domain.Sale{Order: domain.Order{ID: fmt.Sprintf("SALE-%02d", i)}, Document: &doc}, nil); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			// Uma venda recusada não consome número
+			if _, err := repo.Issue(ctx, domain.Sale{Order: domain.Order{ID: "SALE-00"}, Document: &ft}, nil); !errors.Is(err, domain.ErrSaleExists) {
+				t.Fatalf("esperado ErrSaleExists, recebido %v", err)
+			}
+			repo.Close()
+
+			reopened, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			sale, err := reopened.Issue(ctx, domain.Sale{Order: domain.Order{ID: "SALE-10"}, Document: &ft}, nil)
+			if err != nil || sale.Document.No != "FT A/8" {
+				t.Fatalf("esperado FT A/8 depois de reabrir, recebido %+v (%v)", sale.Document, err)
+			}
+			gapped := ft.Numbered(10)
+			if err := reopened.Save(ctx, domain.Sale{Order: domain.Order{ID: "SALE-11"}, Document: &gapped}); !errors.Is(err, domain.ErrSeriesGap) {
+				t.Fatalf("esperado ErrSeriesGap, recebido %v", err)
+			}
+			all, _ := reopened.All(ctx)
+			numbers := map[string][]int64{}
+			for _, sale := range all {
+				numbers[sale.Document.SeriesKey()] = append(numbers[sale.Document.SeriesKey()], sale.Document.Number)
+			}
+			slices.Sort(numbers["FT A"])
+			if fmt.Sprint(numbers["FT A"]) != "[1 2 3 4 5 6 7 8]" || len(numbers["FR A"]) != 3 {
+				t.Fatalf("numeração inesperada: %v", numbers)
+			}
+		})
+	}
+}
+
+func TestSalesRepositories_Void(t *testing.T) {
+	for name, open := range map[string]func(string) (interfaces.SalesRepository, error){
+		"file": NewFileSalesRepository,
+		"kv":   NewBoltSalesRepository,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := context.Background()
+			repo, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ft := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+			for _, id := range []string{"SALE-1", "SALE-2", "SALE-3"} {
+				if _, err := repo.Issue(ctx, domain.Sale{Order: domain.Order{ID: id}, Document: &ft}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			void := domain.SaleVoid{Reason: "Engano", UserID: "u1", At: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}
+			sale, err := repo.Void(ctx, "SALE-2", void)
+			if err != nil || sale.Void == nil || sale.Document.No != "FT A/2" {
+				t.Fatalf("anulação falhou: %+v (%v)", sale, err)
+			}
+			if _, err := repo.Void(ctx, "SALE-2", void); !errors.Is(err, domain.ErrSaleVoided) {
+				t.Fatalf("esperado ErrSaleVoided, recebido %v", err)
+			}
+			if _, err := repo.Void(ctx, "SALE-X", void); !errors.Is(err, domain.ErrSaleNotFound) {
+				t.Fatalf("esperado ErrSaleNotFound, recebido %v", err)
+			}
+			repo.Close()
+
+			// A anulação sobrevive à reabertura sem mudar a ordem nem a numeração
+			reopened, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			all, _ := reopened.All(ctx)
+			if len(all) != 3 || all[1].ID != "SALE-2" || all[1].Void == nil || all[1].Void.Reason != "Engano" {
+				t.Fatalf("vendas inesperadas depois de reabrir: %+v", all)
+			}
+			if sale, err := reopened.Issue(ctx, domain.Sale{Order: domain.Order{ID: "SALE-4"}, Document: &ft}, nil); err != nil || sale.Document.No != "FT A/4" {
+				t.Fatalf("esperado FT A/4, recebido %+v (%v)", sale.Document, err)
+			}
+			page, err := reopened.Query(ctx, domain.SalesQuery{Status: domain.SaleStatusVoided})
+			if err != nil || len(page.Sales) != 1 || page.Sales[0].ID != "SALE-2" {
+				t.Fatalf("filtro por estado falhou: %+v (%v)", page, err)
+			}
+			if page, _ = reopened.Query(ctx, domain.SalesQuery{Status: domain.SaleStatusActive}); len(page.Sales) != 3 {
+				t.Fatalf("esperadas 3 vendas ativas, recebidas %d", len(page.Sales))
+			}
+		})
+	}
+}
+
+func TestFileSalesRepository_CrashRecoveryAndLock(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	repo, err := NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSalesRepository(dir); !errors.Is(err, domain.ErrSalesLocked) {
+		t.Fatalf("um segundo processo não deveria abrir o repositório, recebido %v", err)
+	}
+	repo.Save(ctx, domain.Sale{Order: domain.Order{ID: "SALE-1"}})
+	repo.Close()
+
+	// Queda a meio da escrita: a última linha ficou incompleta
+	file, _ := os.OpenFile(filepath.Join(dir, salesLogFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	file.WriteString(`{"id": "SALE-2", "curr`)
+	file.Close()
+
+	repo, err = NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatalf("a linha incompleta deveria ser descartada: %v", err)
+	}
+	defer repo.Close()
+	if err := repo.Save(ctx, domain.Sale{Order: domain.Order{ID: "SALE-2"}}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := repo.All(ctx)
+	if len(all) != 2 {
+		t.Fatalf("esperadas 2 vendas, recebidas %d", len(all))
+	}
+}
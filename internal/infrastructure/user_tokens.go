@@ -0,0 +1,76 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// userTokensFile é o formato do ficheiro de utilizadores: cada um com o SHA-256 (hex) do
+// seu token, para que o ficheiro não guarde as credenciais, e as permissões e atributos
+// que a política de autorização avalia.
+type userTokensFile struct {
+	Users []struct {
+		ID          string                 `json:"id"`
+		TokenSHA256 string                 `json:"tokenSha256"`
+		Permissions []domain.Permission    `json:"permissions"`
+		Attributes  map[string]interface{} `json:"attributes"`
+	} `json:"users"`
+}
+
+// TokenAuthenticator autentica os pedidos pelo token (Authorization: Bearer) de cada utilizador.
+type TokenAuthenticator struct {
+	users map[string]domain.Caller // Por SHA-256 do token
+}
+
+// LoadUserTokens lê os utilizadores de um ficheiro JSON. IDs ou tokens repetidos são
+// recusados: o mesmo token não pode identificar dois utilizadores.
+func LoadUserTokens(path string) (interfaces.Authenticator, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler ficheiro em %s: %w", path, err)
+	}
+	var file userTokensFile
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("utilizadores inválidos em %s: %w", path, err)
+	}
+
+	auth := &TokenAuthenticator{users: make(map[string]domain.Caller, len(file.Users))}
+	ids := make(map[string]bool)
+	for _, u := range file.Users {
+		hash := strings.ToLower(u.TokenSHA256)
+		if digest, err := hex.DecodeString(hash); err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("utilizadores inválidos em %s: tokenSha256 de %q não é um SHA-256 em hex", path, u.ID)
+		}
+		switch {
+		case strings.TrimSpace(u.ID) == "":
+			return nil, fmt.Errorf("utilizadores inválidos em %s: utilizador sem id", path)
+		case ids[u.ID]:
+			return nil, fmt.Errorf("utilizadores inválidos em %s: %q repetido", path, u.ID)
+		case auth.users[hash].UserID != "":
+			return nil, fmt.Errorf("utilizadores inválidos em %s: %q e %q têm o mesmo token", path, auth.users[hash].UserID, u.ID)
+		}
+		ids[u.ID] = true
+		auth.users[hash] = domain.Caller{UserID: u.ID, Permissions: u.Permissions, Attributes: u.Attributes}
+	}
+	return auth, nil
+}
+
+func (a *TokenAuthenticator) Authenticate(_ context.Context, token string) (domain.Caller, error) {
+	digest := sha256.Sum256([]byte(token))
+	caller, ok := a.users[hex.EncodeToString(digest[:])]
+	if token == "" || !ok {
+		return domain.Caller{}, fmt.Errorf("%w: token desconhecido", domain.ErrUnauthenticated)
+	}
+	return caller, nil
+}
@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+func TestLoadUserTokens(t *testing.T) {
+	digest := sha256.Sum256([]byte("segredo"))
+	path := filepath.Join(t.TempDir(), "users.json")
+	users := `{"users": [{"id": "ana", "tokenSha256": "` + hex.EncodeToString(digest[:]) + `", "permissions": ["sales.void"], "attributes": {"max_allowed_discount": 15}}]}`
+	if err := os.WriteFile(path, []byte(users), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := LoadUserTokens(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caller, err := auth.Authenticate(context.Background(), "segredo")
+	if err != nil || caller.UserID != "ana" || len(caller.Permissions) != 1 || caller.Attributes["max_allowed_discount"] != 15.0 {
+		t.Fatalf("utilizador mal autenticado: %+v, %v", caller, err)
+	}
+	for _, token := range []string{"", "outro"} {
+		if _, err := auth.Authenticate(context.Background(), token); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("token %q deveria ser recusado, recebido %v", token, err)
+		}
+	}
+
+	duplicated := `{"users": [{"id": "a", "tokenSha256": "` + hex.EncodeToString(digest[:]) + `"}, {"id": "b", "tokenSha256": "` + hex.EncodeToString(digest[:]) + `"}]}`
+	for name, content := range map[string]string{"token repetido": duplicated, "hash inválido": `{"users": [{"id": "a", "tokenSha256": "segredo"}]}`} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadUserTokens(path); err == nil {
+			t.Errorf("%s deveria ser recusado", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// XMLLintValidator valida documentos contra um XSD com o xmllint (libxml2); a biblioteca
+// padrão do Go não valida esquemas XML.
+type XMLLintValidator struct {
+	xsd string
+	// Esquema embutido: o xmllint só lê ficheiros, por isso é copiado para o diretório
+	// temporário na primeira validação
+	fsys    fs.FS
+	name    string
+	extract sync.Once
+	err     error
+}
+
+func NewXMLLintValidator(xsdPath string) interfaces.XMLValidator {
+	return &XMLLintValidator{xsd: xsdPath}
+}
+
+// NewXMLLintValidatorFS valida contra o XSD name de fsys (ex: o esquema embutido com
+// embed.FS). O esquema tem de ser autónomo, sem xs:include nem xs:import relativos.
+func NewXMLLintValidatorFS(fsys fs.FS, name string) interfaces.XMLValidator {
+	return &XMLLintValidator{fsys: fsys, name: name}
+}
+
+// extractSchema grava o esquema embutido num ficheiro com o hash no nome, para que
+// processos com o mesmo esquema o partilhem em vez de acumularem cópias.
+func (v *XMLLintValidator) extractSchema() {
+	raw, err := fs.ReadFile(v.fsys, v.name)
+	if err != nil {
+		v.err = fmt.Errorf("%w: esquema %s: %v", domain.ErrSchemaUnavailable, v.name, err)
+		return
+	}
+	sum := sha256.Sum256(raw)
+	path := filepath.Join(os.TempDir(), "saft-"+hex.EncodeToString(sum[:8])+".xsd")
+	if err := writeFileAtomic(path, raw, 0644); err != nil {
+		v.err = fmt.Errorf("%w: esquema %s: %v", domain.ErrSchemaUnavailable, v.name, err)
+		return
+	}
+	v.xsd = path
+}
+
+func (v *XMLLintValidator) Validate(ctx context.Context, doc []byte) error {
+	if v.fsys != nil {
+		if v.extract.Do(v.extractSchema); v.err != nil {
+			return v.err
+		}
+	}
+	if _, err := os.Stat(v.xsd); err != nil {
+		return fmt.Errorf("%w: esquema %s: %v", domain.ErrSchemaUnavailable, v.xsd, err)
+	}
+	bin, err := exec.LookPath("xmllint")
+	if err != nil {
+		return fmt.Errorf("%w: xmllint não encontrado no PATH", domain.ErrSchemaUnavailable)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "--noout", "--schema", v.xsd, "-")
+	cmd.Stdin = bytes.NewReader(doc)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	// O xmllint termina com 3 ou 4 quando o documento não é válido; os restantes códigos
+	// indicam um problema no próprio esquema ou na execução
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == 3 || exitErr.ExitCode() == 4) {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidSAFT, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao executar xmllint: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// Authenticator identifica o utilizador a partir da credencial do pedido.
+type Authenticator interface {
+	// Authenticate devolve domain.ErrUnauthenticated se o token não for de nenhum utilizador.
+	Authenticate(ctx context.Context, token string) (domain.Caller, error)
+}
+
+// Authorizer avalia a política de autorização para uma ação de um utilizador.
+type Authorizer interface {
+	Authorize(ctx context.Context, caller domain.Caller, action domain.Permission) (domain.Decision, error)
+}
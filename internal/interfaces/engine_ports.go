@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/Victor-armando18/service-commercial/internal/domain"
 )
@@ -14,10 +15,63 @@ type RulePackLoader interface {
 	Load(ctx context.Context, version string) (*domain.RulePackDefinition, error)
 }
 
+// RulePackVerifier verifica a assinatura destacada de um pack (nil se o ficheiro .sig não existir).
+type RulePackVerifier interface {
+	Verify(content, signature []byte) error
+}
+
+// ReloadableRuleLoader é um loader cujos packs podem ser recarregados sem reiniciar o servidor.
+type ReloadableRuleLoader interface {
+	RulePackLoader
+	Reload(ctx context.Context) (domain.ReloadReport, error)
+	Watch(ctx context.Context, interval time.Duration, notify func(domain.ReloadReport, error))
+}
+
+// RulePackRepository acrescenta ao loader a listagem e a publicação de novas versões.
+// Publish recusa versões já existentes (domain.ErrRulePackExists) e packs inválidos;
+// Unpublish desfaz uma publicação que não chegou a ficar registada na auditoria.
+type RulePackRepository interface {
+	ReloadableRuleLoader
+	Versions(ctx context.Context) []string
+	Publish(ctx context.Context, upload domain.RulePackUpload) (*domain.RulePackDefinition, error)
+	Unpublish(ctx context.Context, version string) error
+}
+
+// RuleActivationStore guarda a versão de regras ativa de cada tenant.
+type RuleActivationStore interface {
+	Active(ctx context.Context, tenant string) (string, bool)
+	All(ctx context.Context) map[string]string
+	SetActive(ctx context.Context, tenant, version string) error
+}
+
+// AuditLog é o histórico append-only das alterações administrativas.
+type AuditLog interface {
+	Record(ctx context.Context, entry domain.AuditEntry) error
+	List(ctx context.Context) ([]domain.AuditEntry, error)
+}
+
+// RulePackAdmin é o caso de uso da API de gestão de RulePacks.
+type RulePackAdmin interface {
+	List(ctx context.Context) ([]domain.RulePackInfo, error)
+	Get(ctx context.Context, version string) (*domain.RulePackDefinition, error)
+	// Publish e Activate exigem a permissão de gestão de regras ao utilizador do pedido
+	Publish(ctx context.Context, upload domain.RulePackUpload) (*domain.RulePackDefinition, error)
+	Diff(ctx context.Context, from, to string) (*domain.RulePackDiff, error)
+	Activate(ctx context.Context, tenant, version string) error
+	ActiveVersion(ctx context.Context, tenant string) (string, bool)
+	History(ctx context.Context) ([]domain.AuditEntry, error)
+}
+
 // RuleExecutor define o contrato para executar uma regra JsonLogic com operadores customizados.
 type RuleExecutor interface {
 	Execute(ctx context.Context, ruleData map[string]interface{}, contextVars map[string]interface{}) (interface{}, error)
-	RegisterCustomOperator(name string, logic func(args ...interface{}) interface{})
+	RegisterCustomOperator(spec domain.OperatorSpec)
+	Operator(name string) (domain.OperatorSpec, bool)
+}
+
+// RulePackValidator define o contrato para a validação estática de um RulePack.
+type RulePackValidator interface {
+	Validate(def *domain.RulePackDefinition) []domain.Diagnostic
 }
 
 // EngineFacade é a função clara exposta ao mundo externo (a porta de entrada da aplicação).
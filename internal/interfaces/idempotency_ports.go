@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// IdempotencyStore guarda as respostas por (tenant, chave) e serializa pedidos repetidos.
+type IdempotencyStore interface {
+	// Begin devolve a resposta guardada para a chave ou, se não houver, reserva a chave
+	// (nil, nil) até Complete ou Abort. Com a mesma chave em execução, espera que termine.
+	// Devolve domain.ErrIdempotencyKeyReused se fingerprint não for o do pedido original.
+	Begin(ctx context.Context, tenant, key, fingerprint string) (*domain.IdempotencyRecord, error)
+	Complete(ctx context.Context, record domain.IdempotencyRecord) error
+	// Abort liberta a chave sem guardar resposta (ex: o pedido falhou e pode ser repetido).
+	Abort(ctx context.Context, tenant, key string)
+}
@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// OrderSessionStore guarda os pedidos em rascunho do servidor. Sessões sem alterações
+// durante o TTL do store expiram e deixam de ser devolvidas.
+type OrderSessionStore interface {
+	// Get devolve domain.ErrOrderNotFound se a sessão não existir ou tiver expirado.
+	Get(ctx context.Context, orderID string) (domain.OrderSession, error)
+	Put(ctx context.Context, session domain.OrderSession) error
+	// CompareAndSwap grava session só se a revisão guardada for expected ("" = ainda não existe).
+	CompareAndSwap(ctx context.Context, expected string, session domain.OrderSession) (bool, error)
+}
+
+// OrderSessions gere pedidos em rascunho com controlo otimista de concorrência.
+type OrderSessions interface {
+	// Create calcula o pedido e guarda-o como sessão, gerando o ID se vier vazio. Um
+	// desconto acima do limite do utilizador devolve domain.ErrForbidden (ou
+	// domain.ErrUnauthenticated num pedido anónimo); nos patches é uma violação da política.
+	Create(ctx context.Context, order domain.Order) (*domain.EngineResult, error)
+	Get(ctx context.Context, orderID string) (domain.OrderSession, error)
+	// Patch aplica um patch RFC 6902 a base e recalcula. ifMatch vazio dispensa a verificação
+	// de revisão; "*" aceita qualquer revisão. Conflitos devolvem *domain.PreconditionError;
+	// sem If-Match o patch é repetido e, se perder sempre a corrida, devolve
+	// domain.ErrConcurrentUpdate.
+	Patch(ctx context.Context, base domain.Order, patch []byte, ifMatch string) (*domain.EngineResult, error)
+	// PatchSession aplica um patch (domain.PatchFormatJSON ou PatchFormatMerge) à sessão guardada.
+	PatchSession(ctx context.Context, orderID, format string, patch []byte, ifMatch string) (*domain.EngineResult, error)
+}
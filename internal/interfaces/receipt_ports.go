@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// ReceiptRenderer imprime um talão num formato.
+type ReceiptRenderer interface {
+	Render(receipt domain.Receipt) ([]byte, error)
+	ContentType() string
+}
+
+// Receipts gera o talão de uma venda gravada.
+type Receipts interface {
+	// Render devolve domain.ErrSaleNotFound ou domain.ErrUnknownReceiptFormat.
+	Render(ctx context.Context, saleID string, format domain.ReceiptFormat) (domain.RenderedReceipt, error)
+}
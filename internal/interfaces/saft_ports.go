@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// MasterData fornece os dados mestre do SAF-T; são lidos em cada exportação, para que
+// alterações aos ficheiros não exijam reinício.
+type MasterData interface {
+	Company(ctx context.Context) (domain.Company, error)
+	Products(ctx context.Context) ([]domain.Product, error)
+	Taxes(ctx context.Context) ([]domain.Tax, error)
+}
+
+// XMLValidator valida um documento XML contra um esquema. Devolve domain.ErrSchemaUnavailable
+// se o esquema ou o validador não existirem e domain.ErrInvalidSAFT se o documento falhar.
+type XMLValidator interface {
+	Validate(ctx context.Context, doc []byte) error
+}
+
+// SAFTExporter gera o SAF-T (AO) dos documentos emitidos em [from, to).
+type SAFTExporter interface {
+	Export(ctx context.Context, from, to time.Time) (domain.SAFTExport, error)
+}
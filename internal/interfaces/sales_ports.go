@@ -0,0 +1,57 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+)
+
+// SalesRepository guarda as vendas de forma durável: Save só retorna depois de a venda
+// estar em disco. Cada repositório é aberto por um único processo.
+type SalesRepository interface {
+	// Save grava uma venda nova; devolve domain.ErrSaleExists se o ID já existir. Um
+	// documento fiscal já numerado tem de ser o seguinte da série (domain.ErrSeriesGap).
+	Save(ctx context.Context, sale domain.Sale) error
+	// Issue atribui ao documento da venda o número seguinte da série e grava a venda na
+	// mesma operação, pelo que um número só é consumido por uma venda gravada. Com signer,
+	// o documento é assinado e encadeado com o hash do anterior da série.
+	Issue(ctx context.Context, sale domain.Sale, signer DocumentSigner) (domain.Sale, error)
+	// Void grava a anulação da venda, que mantém o documento e a assinatura; devolve
+	// domain.ErrSaleNotFound ou domain.ErrSaleVoided.
+	Void(ctx context.Context, id string, void domain.SaleVoid) (domain.Sale, error)
+	// Get devolve domain.ErrSaleNotFound se a venda não existir.
+	Get(ctx context.Context, id string) (domain.Sale, error)
+	// All devolve todas as vendas por ordem de gravação.
+	All(ctx context.Context) ([]domain.Sale, error)
+	// Query devolve uma página das vendas que satisfazem os filtros; domain.ErrInvalidSalesQuery
+	// indica uma ordenação ou cursor inválidos.
+	Query(ctx context.Context, q domain.SalesQuery) (domain.SalesPage, error)
+	Close() error
+}
+
+// DocumentSigner assina documentos fiscais com a chave privada do emissor.
+type DocumentSigner interface {
+	// Sign devolve a assinatura de domain.SignatureMessage em base64.
+	Sign(message string) (string, error)
+	// KeyVersion identifica a chave, gravada em FiscalDocument.HashControl.
+	KeyVersion() string
+}
+
+// DocumentVerifier confirma assinaturas feitas por um DocumentSigner.
+type DocumentVerifier interface {
+	Verify(message, hash string) error
+}
+
+// SaleReturns emite notas de crédito sobre vendas gravadas.
+type SaleReturns interface {
+	// Return devolve domain.ErrSaleNotFound, domain.ErrInvalidReturn ou domain.ErrOverReturn.
+	Return(ctx context.Context, saleID string, req domain.ReturnRequest) (domain.Sale, error)
+}
+
+// SaleVoids anula vendas gravadas.
+type SaleVoids interface {
+	// Void anula a venda em nome do utilizador em usecase.CallerFrom(ctx). Devolve
+	// domain.ErrUnauthenticated, domain.ErrForbidden, domain.ErrSaleNotFound,
+	// domain.ErrSaleVoided ou domain.ErrInvalidVoid.
+	Void(ctx context.Context, saleID, reason string) (domain.Sale, error)
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+type callerKey struct{}
+
+// WithCaller associa ao pedido o utilizador autenticado que o faz.
+func WithCaller(ctx context.Context, caller domain.Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom devolve o utilizador definido com WithCaller (anónimo se nenhum).
+func CallerFrom(ctx context.Context) domain.Caller {
+	caller, _ := ctx.Value(callerKey{}).(domain.Caller)
+	return caller
+}
+
+// authorize pede à política de autorização a decisão sobre a ação para o utilizador do
+// pedido. Uma recusa é domain.ErrUnauthenticated num pedido anónimo e domain.ErrForbidden
+// num utilizador sem a permissão.
+func authorize(ctx context.Context, authz interfaces.Authorizer, action domain.Permission) (domain.Decision, error) {
+	caller := CallerFrom(ctx)
+	decision, err := authz.Authorize(ctx, caller, action)
+	switch {
+	case err != nil:
+		return decision, err
+	case decision.Allowed:
+		return decision, nil
+	case caller.Anonymous():
+		return decision, fmt.Errorf("%w: %s exige um utilizador autenticado", domain.ErrUnauthenticated, action)
+	default:
+		return decision, fmt.Errorf("%w: %s não tem a permissão %s", domain.ErrForbidden, caller.UserID, action)
+	}
+}
+
+// Authorize recusa o pedido se o utilizador não tiver a permissão action, para as rotas
+// que não passam por um serviço que a verifique (ex: consulta e recarga das regras).
+func Authorize(ctx context.Context, authz interfaces.Authorizer, action domain.Permission) error {
+	_, err := authorize(ctx, authz, action)
+	return err
+}
+
+// AuthorizeDiscount verifica o desconto do pedido, tal como será calculado, contra o limite
+// (max_discount_pct) que a política dá ao utilizador; sem desconto não é preciso permissão.
+// Tem de ser chamado em todos os caminhos em que um pedido do cliente chega ao motor.
+func AuthorizeDiscount(ctx context.Context, authz interfaces.Authorizer, order domain.Order) error {
+	discount := order.DiscountPercentage
+	if discount == 0 {
+		return nil
+	}
+	if discount < 0 || discount > 1 {
+		return fmt.Errorf("%w: desconto %g fora de [0, 1]", domain.ErrForbidden, discount)
+	}
+	decision, err := authorize(ctx, authz, domain.PermissionApplyDiscount)
+	if err != nil {
+		return err
+	}
+	limit, ok := decision.Constraints[domain.ConstraintMaxDiscountPct]
+	if !ok {
+		return fmt.Errorf("%w: a política não define %s para %s", domain.ErrForbidden, domain.ConstraintMaxDiscountPct, domain.PermissionApplyDiscount)
+	}
+	// A política exprime o limite em percentagem e o pedido o desconto em fração
+	if discount*100 > limit+1e-9 {
+		return fmt.Errorf("%w: desconto de %g%% acima do limite de %g%% de %s", domain.ErrForbidden, round2(discount*100), limit, CallerFrom(ctx).UserID)
+	}
+	return nil
+}
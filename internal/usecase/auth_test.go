@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/policies"
+)
+
+// testAuthorizer avalia a política de autorização embutida.
+func testAuthorizer(t *testing.T) interfaces.Authorizer {
+	t.Helper()
+	authz, err := infrastructure.NewRegoAuthorizer("authz.rego", policies.Authz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authz
+}
+
+func TestAuthorizeDiscount(t *testing.T) {
+	authz := testAuthorizer(t)
+	order := func(discount float64) domain.Order { return domain.Order{DiscountPercentage: discount} }
+	apply := []domain.Permission{domain.PermissionApplyDiscount}
+	ctx := context.Background()
+	cashier := WithCaller(ctx, domain.Caller{UserID: "caixa", Permissions: apply})
+	manager := WithCaller(ctx, domain.Caller{UserID: "gerente", Permissions: apply, Attributes: map[string]interface{}{"max_allowed_discount": 15}})
+	admin := WithCaller(ctx, domain.Caller{UserID: "admin", Permissions: append(apply, "sales.admin")})
+
+	for _, tc := range []struct {
+		name     string
+		ctx      context.Context
+		discount float64
+		want     error
+	}{
+		{"sem desconto não exige permissão", ctx, 0, nil},
+		{"anónimo", ctx, 0.01, domain.ErrUnauthenticated},
+		{"sem a permissão", WithCaller(ctx, domain.Caller{UserID: "u"}), 0.01, domain.ErrForbidden},
+		{"limite por omissão", cashier, 0.05, nil},
+		{"acima do limite por omissão", cashier, 0.06, domain.ErrForbidden},
+		{"limite do atributo", manager, 0.15, nil},
+		{"acima do limite do atributo", manager, 0.2, domain.ErrForbidden},
+		{"sem limite", admin, 1, nil},
+		{"negativo", admin, -0.1, domain.ErrForbidden},
+	} {
+		if err := AuthorizeDiscount(tc.ctx, authz, order(tc.discount)); !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+			t.Errorf("%s: esperado %v, recebido %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+// Só as ações listadas na política são autorizadas, mesmo que o utilizador tenha a permissão.
+func TestAuthorize_UnknownAction(t *testing.T) {
+	ctx := WithCaller(context.Background(), domain.Caller{UserID: "ana", Permissions: []domain.Permission{"sales.export", domain.PermissionVoidSale}})
+	authz := testAuthorizer(t)
+	if _, err := authorize(ctx, authz, "sales.export"); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("ação desconhecida: esperado ErrForbidden, recebido %v", err)
+	}
+	if _, err := authorize(ctx, authz, domain.PermissionVoidSale); err != nil {
+		t.Fatalf("sales.void: %v", err)
+	}
+}
@@ -2,15 +2,34 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/Victor-armando18/service-commercial/internal/domain"
 	"github.com/Victor-armando18/service-commercial/internal/interfaces"
-	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/Victor-armando18/service-commercial/pkg/jsondiff"
 )
 
+// pipelinePhases define a ordem obrigatória de execução das fases.
+var pipelinePhases = []string{"baseline", "orderAdjust", "allocation", "taxes", "totals", "guards"}
+
+type deltaToleranceKey struct{}
+
+// WithDeltaTolerance define, para o pedido, a diferença numérica abaixo da qual uma
+// alteração do servidor é marcada como cosmética (ex: 0.01 para arredondamentos ao cêntimo).
+func WithDeltaTolerance(ctx context.Context, tolerance float64) context.Context {
+	return context.WithValue(ctx, deltaToleranceKey{}, tolerance)
+}
+
+// DeltaTolerance devolve a tolerância definida com WithDeltaTolerance (0 se nenhuma).
+func DeltaTolerance(ctx context.Context) float64 {
+	tolerance, _ := ctx.Value(deltaToleranceKey{}).(float64)
+	return tolerance
+}
+
 type EngineService struct {
 	loader   interfaces.RulePackLoader
 	executor interfaces.RuleExecutor
@@ -25,32 +44,41 @@ func (e *EngineService) RunEngine(ctx context.Context, initialOrder domain.Order
 		version = "v" + version
 	}
 
+	// As chamadas a operadores já foram validadas pelo loader ao carregar o pack
 	rulePack, err := e.loader.Load(ctx, version)
 	if err != nil {
 		return nil, err
 	}
 
-	// Preservação total da cópia original
+	// Preservação total da cópia original, que serve de base às diferenças (changes). Os
+	// valores calculados pelo motor partem sempre do zero: um total, base ou imposto enviado
+	// pelo cliente nunca entra no cálculo
 	workingOrder := initialOrder
 	workingOrder.RulesVersion = version
+	workingOrder.BaseValue, workingOrder.TotalValue, workingOrder.TotalItems = 0, 0, 0
+	workingOrder.AppliedTaxes = nil
 	e.hydrateData(&workingOrder)
 
 	initialJSON, _ := json.Marshal(initialOrder)
 	executionLog := []domain.ExecutionStep{}
 	guardsHit := []domain.GuardViolation{}
+	guards := []domain.GuardResult{}
 
-	phases := []string{"baseline", "orderAdjust", "allocation", "taxes", "totals", "guards"}
-
-	for _, phase := range phases {
+	for _, phase := range pipelinePhases {
 		rules := e.getRules(rulePack.Rules, phase)
 		for _, rule := range rules {
 			out, err := e.executor.Execute(ctx, rule.Logic, map[string]interface{}{"order": workingOrder})
-			if err != nil || out == nil {
+			if err != nil {
+				return nil, fmt.Errorf("%w: regra %s (fase %s): %w", domain.ErrRuleExecutionFailed, rule.ID, phase, err)
+			}
+			if out == nil {
 				continue
 			}
 
 			if phase == "guards" {
-				if v, ok := out.(bool); ok && v {
+				v, ok := out.(bool)
+				guards = append(guards, domain.GuardResult{RuleID: rule.ID, Passed: !(ok && v)})
+				if ok && v {
 					guardsHit = append(guardsHit, domain.GuardViolation{
 						RuleID:  rule.ID,
 						Reason:  "Violation Detected",
@@ -79,17 +107,27 @@ func (e *EngineService) RunEngine(ctx context.Context, initialOrder domain.Order
 
 	// Geração do Fragmento (Mantém Currency e CorrelationID se estiverem na workingOrder)
 	finalJSON, _ := json.Marshal(workingOrder)
-	patch, _ := jsonpatch.CreateMergePatch(initialJSON, finalJSON)
+	changes, _ := jsondiff.Diff(initialJSON, finalJSON, DeltaTolerance(ctx))
 
 	var stateFragment map[string]interface{}
 	json.Unmarshal(finalJSON, &stateFragment)
 
+	var warnings []string
+	if initialOrder.RulesHash != "" && initialOrder.RulesHash != rulePack.ContentHash {
+		warnings = append(warnings, fmt.Sprintf("o conteúdo do rulepack %s mudou: pedido calculado com %s, pack atual %s", version, initialOrder.RulesHash, rulePack.ContentHash))
+	}
+
 	return &domain.EngineResult{
 		StateFragment: stateFragment,
-		ServerDelta:   len(patch) > 2,
+		ServerDelta:   jsondiff.HasChanges(changes),
+		Changes:       changes,
 		RulesVersion:  version,
+		RulesHash:     rulePack.ContentHash,
+		Revision:      stateRevision(finalJSON, rulePack.ContentHash),
 		ExecutionLog:  executionLog,
 		GuardsHit:     guardsHit,
+		Guards:        guards,
+		Warnings:      warnings,
 	}, nil
 }
 
@@ -101,10 +139,8 @@ func (e *EngineService) hydrateData(order *domain.Order) {
 		v += i.Value * float64(i.Qty)
 	}
 	order.TotalItems = q
-	// Só calculamos o BaseValue se o motor ainda não o definiu via regras
-	if order.BaseValue == 0 {
-		order.BaseValue = v
-	}
+	// Valor inicial da base; as regras de desconto substituem-no
+	order.BaseValue = v
 }
 
 func (e *EngineService) getRules(rules []domain.RuleConfig, phase string) []domain.RuleConfig {
@@ -137,6 +173,26 @@ func (e *EngineService) applyUpdate(key string, val interface{}, order *domain.O
 	}
 }
 
+func isPipelinePhase(phase string) bool {
+	for _, p := range pipelinePhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// isWritableKey indica se applyUpdate sabe gravar o resultado de uma regra nesta chave.
+func isWritableKey(key string) bool {
+	switch {
+	case key == "order.baseValue", key == "order.totalValue":
+		return true
+	case strings.HasPrefix(key, "order.appliedTaxes."):
+		return len(key) > len("order.appliedTaxes.")
+	}
+	return false
+}
+
 func (e *EngineService) toFloat(i interface{}) (float64, bool) {
 	switch v := i.(type) {
 	case float64:
@@ -149,3 +205,10 @@ func (e *EngineService) toFloat(i interface{}) (float64, bool) {
 	}
 	return 0, false
 }
+
+// stateRevision identifica o estado calculado: muda se o pedido resultante ou o conteúdo
+// das regras mudar, e é igual para o mesmo cálculo em qualquer servidor.
+func stateRevision(state []byte, rulesHash string) string {
+	sum := sha256.Sum256(append(append(state, '\n'), rulesHash...))
+	return hex.EncodeToString(sum[:16])
+}
@@ -2,27 +2,28 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/Victor-armando18/service-commercial/internal/domain"
 	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/pkg/engine"
 )
 
-func TestEngine_DeterministicExecution(t *testing.T) {
-	// Ajuste de diretório para encontrar pkg/rules durante o teste
-	wd, _ := os.Getwd()
-	for !strings.HasSuffix(wd, "service-commercial") {
-		wd = filepath.Dir(wd)
-	}
-	os.Chdir(wd)
+// Diretório dos RulePacks publicados, relativo a este pacote
+var rulesDir = filepath.Join("..", "..", "data", "rules")
 
-	loader := infrastructure.NewFileRuleLoader()
+func TestEngine_DeterministicExecution(t *testing.T) {
 	executor := infrastructure.NewJsonLogicExecutor()
-	executor.RegisterCustomOperator("allocate", infrastructure.CustomAllocate)
-	executor.RegisterCustomOperator("round", infrastructure.CustomRound)
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	loader := infrastructure.NewFileRuleLoader(rulesDir, NewRulePackValidator(executor))
 
 	engine := NewEngineService(loader, executor)
 
@@ -44,3 +45,113 @@ func TestEngine_DeterministicExecution(t *testing.T) {
 		t.Errorf("Não determinístico")
 	}
 }
+
+func TestEngine_RulesHash(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	loader := infrastructure.NewFileRuleLoader(rulesDir, NewRulePackValidator(executor))
+	engine := NewEngineService(loader, executor)
+	ctx := context.Background()
+
+	order := domain.Order{ID: "TEST-HASH-001", Currency: "AOA", Items: []domain.OrderItem{{SKU: "PROD1", Value: 1000, Qty: 1}}}
+	res, err := engine.RunEngine(ctx, order, "v1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RulesHash == "" || len(res.Warnings) != 0 {
+		t.Fatalf("esperado hash sem avisos: %q %v", res.RulesHash, res.Warnings)
+	}
+
+	// Pedido calculado com outro conteúdo da mesma versão
+	order.RulesHash = "sha256:antigo"
+	if res, _ = engine.RunEngine(ctx, order, "v1.2"); len(res.Warnings) != 1 {
+		t.Fatalf("esperado aviso de conteúdo alterado: %v", res.Warnings)
+	}
+
+	sales := []domain.Order{
+		{ID: "S1", RulesVersion: "v1.2", RulesHash: res.RulesHash},
+		{ID: "S2", RulesVersion: "v1.2", RulesHash: "sha256:antigo"},
+		{ID: "S3", RulesVersion: "v1.2"},
+	}
+	drifts := DetectRulesDrift(ctx, loader, sales)
+	if len(drifts) != 1 || drifts[0].SaleIDs[0] != "S2" || drifts[0].CurrentHash != res.RulesHash {
+		t.Fatalf("drift inesperado: %+v", drifts)
+	}
+}
+
+// Um erro do executor numa regra interrompe o cálculo e identifica a regra, em vez de a
+// regra ser ignorada em silêncio.
+func TestEngine_ExecutionErrorNamesRule(t *testing.T) {
+	dir := t.TempDir()
+	pack := `{"version": "v1", "rules": [{"id": "R_BROKEN", "phase": "taxes", "logic": {"nope": [1]}, "output_key": "order.appliedTaxes.VAT"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "v1_rules.json"), []byte(pack), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(dir, nil), infrastructure.NewJsonLogicExecutor())
+
+	_, err := engine.RunEngine(context.Background(), domain.Order{ID: "O1", Items: []domain.OrderItem{{SKU: "A", Value: 1, Qty: 1}}}, "v1")
+	if !errors.Is(err, domain.ErrRuleExecutionFailed) || !strings.Contains(err.Error(), "R_BROKEN") {
+		t.Fatalf("esperado ErrRuleExecutionFailed com a regra R_BROKEN, recebido %v", err)
+	}
+}
+
+// enginePackLoader serve ao motor de pkg/engine os packs lidos pelo loader do servidor.
+type enginePackLoader struct {
+	repo interfaces.RulePackRepository
+}
+
+func (l enginePackLoader) Load(ctx context.Context, version string) (*engine.RulePack, error) {
+	def, err := l.repo.Load(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	return enginePack(def), nil
+}
+
+func TestEngine_MatchesStandaloneEngine(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	loader := infrastructure.NewFileRuleLoader(rulesDir, NewRulePackValidator(executor))
+	server := NewEngineService(loader, executor)
+	standalone := engine.NewEngineService(enginePackLoader{repo: loader}, engine.NewJsonLogicExecutor())
+
+	// Os valores calculados enviados pelo cliente são ignorados pelos dois motores
+	order := domain.Order{
+		ID:                 "TEST-PARITY-001",
+		Currency:           "AOA",
+		Items:              []domain.OrderItem{{SKU: "PROD1", Value: 1000, Qty: 2}, {SKU: "PROD2", Value: 250, Qty: 3}},
+		DiscountPercentage: 0.10,
+		BaseValue:          1,
+		TotalValue:         2,
+		TotalItems:         99,
+		AppliedTaxes:       map[string]float64{"VAT": 3},
+	}
+	raw, _ := json.Marshal(order)
+	var standaloneOrder engine.Order
+	if err := json.Unmarshal(raw, &standaloneOrder); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := loader.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range loader.Versions(ctx) {
+		want, err := server.RunEngine(ctx, order, version)
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		got, err := standalone.RunEngine(ctx, standaloneOrder, version)
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		if !reflect.DeepEqual(want.StateFragment, got.StateFragment) {
+			t.Errorf("%s: motores divergem\nservidor: %v\npkg/engine: %v", version, want.StateFragment, got.StateFragment)
+		}
+		if want.StateFragment["baseValue"] == float64(1) {
+			t.Errorf("%s: baseValue do cliente entrou no cálculo", version)
+		}
+	}
+}
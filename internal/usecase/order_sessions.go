@@ -0,0 +1,349 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/pkg/jsondiff"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// OrderSessionService guarda pedidos em rascunho e aplica-lhes patches com controlo
+// otimista de concorrência: a revisão atual de um pedido é a da sua sessão ou, sem
+// sessão, a do pedido base recalculado pelo motor. Só são aceites alterações aos
+// caminhos que a política deixa o cliente alterar, e o desconto de cada pedido calculado
+// tem de estar dentro do limite de quem o envia.
+type OrderSessionService struct {
+	engine   interfaces.EngineFacade
+	sessions interfaces.OrderSessionStore
+	policy   domain.PatchPolicy
+	authz    interfaces.Authorizer
+}
+
+func NewOrderSessionService(engine interfaces.EngineFacade, sessions interfaces.OrderSessionStore, policy domain.PatchPolicy, authz interfaces.Authorizer) interfaces.OrderSessions {
+	return &OrderSessionService{engine: engine, sessions: sessions, policy: policy, authz: authz}
+}
+
+func (s *OrderSessionService) Create(ctx context.Context, order domain.Order) (*domain.EngineResult, error) {
+	if order.ID == "" {
+		order.ID = newOrderID()
+	}
+	if err := AuthorizeDiscount(ctx, s.authz, order); err != nil {
+		return nil, err
+	}
+	result, err := s.engine.RunEngine(ctx, order, order.RulesVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.Put(ctx, newSession(order, result)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *OrderSessionService) Get(ctx context.Context, orderID string) (domain.OrderSession, error) {
+	return s.sessions.Get(ctx, orderID)
+}
+
+// patchAttempts limita as tentativas de um patch sem If-Match que perde a corrida com
+// outras alterações à mesma sessão.
+const patchAttempts = 3
+
+// Patch mantém o contrato de /orders/patch, em que o cliente envia o pedido completo.
+// Sem If-Match o patch aplica-se ao pedido enviado e a sessão é substituída; com If-Match
+// aplica-se à versão do servidor, não à cópia do cliente. Do pedido enviado só contam os
+// campos do cliente: os calculados são refeitos pelo motor e, havendo sessão, a versão
+// das regras é a dela. A sessão só é gravada se ainda estiver na revisão lida: sem
+// If-Match, uma alteração concorrente faz repetir o patch sobre a sessão nova e, ao fim
+// de patchAttempts tentativas, devolve domain.ErrConcurrentUpdate.
+func (s *OrderSessionService) Patch(ctx context.Context, base domain.Order, patchData []byte, ifMatch string) (*domain.EngineResult, error) {
+	patch, err := s.decodeJSONPatch(patchData)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		result, next, stored, err := s.patchOrder(ctx, base, patch, ifMatch)
+		if err != nil || next.OrderID == "" {
+			return result, err
+		}
+		ok, err := s.sessions.CompareAndSwap(ctx, stored, next)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return result, nil
+		}
+		// Com If-Match o cliente editou uma revisão que deixou de ser a atual
+		if ifMatch != "" {
+			latest, _ := s.sessions.Get(ctx, next.OrderID)
+			return nil, &domain.PreconditionError{Reason: "o pedido foi alterado em simultâneo", Current: latest}
+		}
+		if attempt == patchAttempts {
+			return nil, fmt.Errorf("%w: o pedido %s foi alterado em simultâneo %d vezes", domain.ErrConcurrentUpdate, next.OrderID, patchAttempts)
+		}
+	}
+}
+
+// patchOrder aplica o patch à revisão atual do pedido e devolve o resultado, a sessão a
+// gravar (sem OrderID se o pedido não tiver ID) e a revisão sobre a qual foi calculada.
+func (s *OrderSessionService) patchOrder(ctx context.Context, base domain.Order, patch jsonpatch.Patch, ifMatch string) (*domain.EngineResult, domain.OrderSession, string, error) {
+	current, stored, err := s.current(ctx, base)
+	if err != nil {
+		return nil, domain.OrderSession{}, "", err
+	}
+	if err := checkIfMatch(ifMatch, current); err != nil {
+		return nil, domain.OrderSession{}, "", err
+	}
+
+	switch {
+	case ifMatch != "" && stored != "":
+		base = current.Order
+	case stored != "":
+		base.RulesVersion = current.Order.RulesVersion
+	}
+	updated, err := s.applyJSONPatch(ctx, patch, base, current)
+	if err != nil {
+		return nil, domain.OrderSession{}, "", err
+	}
+
+	result, err := s.engine.RunEngine(ctx, updated, updated.RulesVersion)
+	if err != nil {
+		return nil, domain.OrderSession{}, "", err
+	}
+	if updated.ID == "" {
+		return result, domain.OrderSession{}, stored, nil
+	}
+	return result, newSession(updated, result), stored, nil
+}
+
+func (s *OrderSessionService) PatchSession(ctx context.Context, orderID, format string, patchData []byte, ifMatch string) (*domain.EngineResult, error) {
+	current, err := s.sessions.Get(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIfMatch(ifMatch, current); err != nil {
+		return nil, err
+	}
+
+	var updated domain.Order
+	if format == domain.PatchFormatMerge {
+		updated, err = s.applyMergePatch(ctx, patchData, current)
+	} else {
+		var patch jsonpatch.Patch
+		if patch, err = s.decodeJSONPatch(patchData); err == nil {
+			updated, err = s.applyJSONPatch(ctx, patch, current.Order, current)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	updated.ID = current.OrderID
+
+	result, err := s.engine.RunEngine(ctx, updated, updated.RulesVersion)
+	if err != nil {
+		return nil, err
+	}
+	// Mesmo sem If-Match, uma alteração concorrente não é sobreposta em silêncio
+	return result, s.swap(ctx, current.Revision, newSession(updated, result))
+}
+
+func (s *OrderSessionService) swap(ctx context.Context, expected string, next domain.OrderSession) error {
+	ok, err := s.sessions.CompareAndSwap(ctx, expected, next)
+	if err != nil {
+		return err
+	}
+	// Outro terminal pode ter gravado entre a leitura e agora
+	if !ok {
+		latest, _ := s.sessions.Get(ctx, next.OrderID)
+		return &domain.PreconditionError{Reason: "o pedido foi alterado em simultâneo", Current: latest}
+	}
+	return nil
+}
+
+// current devolve a sessão atual do pedido e a sua revisão ("" se não houver sessão).
+func (s *OrderSessionService) current(ctx context.Context, base domain.Order) (domain.OrderSession, string, error) {
+	if base.ID != "" {
+		session, err := s.sessions.Get(ctx, base.ID)
+		if err == nil {
+			return session, session.Revision, nil
+		}
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			return domain.OrderSession{}, "", err
+		}
+	}
+	result, err := s.engine.RunEngine(ctx, base, base.RulesVersion)
+	if err != nil {
+		return domain.OrderSession{}, "", err
+	}
+	return newSession(base, result), "", nil
+}
+
+func checkIfMatch(ifMatch string, current domain.OrderSession) error {
+	if ifMatch != "" && ifMatch != "*" && ifMatch != current.Revision {
+		return &domain.PreconditionError{
+			Reason:  fmt.Sprintf("a revisão %s já não é a atual (%s)", ifMatch, current.Revision),
+			Current: current,
+		}
+	}
+	return nil
+}
+
+func newSession(order domain.Order, result *domain.EngineResult) domain.OrderSession {
+	return domain.OrderSession{OrderID: order.ID, Revision: result.Revision, Order: order, Result: result}
+}
+
+func newOrderID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "ORD-" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+func (s *OrderSessionService) decodeJSONPatch(data []byte) (jsonpatch.Patch, error) {
+	patch, err := jsonpatch.DecodePatch(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+	if violations := s.checkPaths(patch); len(violations) > 0 {
+		return nil, &domain.ForbiddenPatchError{Violations: violations}
+	}
+	return patch, nil
+}
+
+// applyJSONPatch aplica um patch RFC 6902 já verificado; as operações test que falham
+// devolvem o estado atual para o cliente refazer a alteração.
+func (s *OrderSessionService) applyJSONPatch(ctx context.Context, patch jsonpatch.Patch, base domain.Order, current domain.OrderSession) (domain.Order, error) {
+	updated, err := applyOrderPatch(base, patch)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return base, &domain.PreconditionError{Reason: err.Error(), Current: current}
+	}
+	if err != nil {
+		return base, err
+	}
+	if err := s.checkDiscount(ctx, patch, updated); err != nil {
+		return base, err
+	}
+	return updated, nil
+}
+
+// applyMergePatch aplica um merge patch RFC 7386: cada membro é uma substituição e
+// null é uma remoção, verificadas contra a política como as operações equivalentes.
+func (s *OrderSessionService) applyMergePatch(ctx context.Context, data []byte, current domain.OrderSession) (domain.Order, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return current.Order, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+	var violations []domain.PatchViolation
+	s.checkMergePaths("", doc, &violations)
+	if len(violations) > 0 {
+		return current.Order, &domain.ForbiddenPatchError{Violations: violations}
+	}
+
+	original, _ := json.Marshal(current.Order)
+	modified, err := jsonpatch.MergePatch(original, data)
+	if err != nil {
+		return current.Order, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+	var updated domain.Order
+	if err := json.Unmarshal(modified, &updated); err != nil {
+		return current.Order, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+	if err := s.checkDiscount(ctx, nil, updated); err != nil {
+		return current.Order, err
+	}
+	return updated, nil
+}
+
+func (s *OrderSessionService) checkMergePaths(path string, value interface{}, violations *[]domain.PatchViolation) {
+	members, ok := value.(map[string]interface{})
+	if !ok || path != "" && len(members) == 0 {
+		op := "replace"
+		if value == nil {
+			op = "remove"
+		}
+		if !s.policy.Allows(op, path) {
+			*violations = append(*violations, domain.PatchViolation{Index: -1, Op: op, Path: path, Reason: "campo não alterável pelo cliente"})
+		}
+		return
+	}
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.checkMergePaths(path+"/"+jsondiff.EscapePointer(k), members[k], violations)
+	}
+}
+
+// checkPaths recusa as operações que escrevem em campos do servidor. As operações
+// test só leem e são sempre aceites (as desconhecidas são recusadas ao aplicar o patch);
+// move também remove a origem, por isso esta tem de ser alterável.
+func (s *OrderSessionService) checkPaths(patch jsonpatch.Patch) []domain.PatchViolation {
+	var violations []domain.PatchViolation
+	for i, op := range patch {
+		kind := op.Kind()
+		switch kind {
+		case "add", "remove", "replace", "move", "copy":
+		default:
+			continue
+		}
+		path, _ := op.Path()
+		if !s.policy.Allows(kind, path) {
+			violations = append(violations, domain.PatchViolation{Index: i, Op: kind, Path: path, Reason: "campo não alterável pelo cliente"})
+			continue
+		}
+		if kind == "move" {
+			if from, _ := op.From(); !s.policy.Allows("remove", from) {
+				violations = append(violations, domain.PatchViolation{Index: i, Op: kind, Path: from, Reason: "origem não alterável pelo cliente"})
+			}
+		}
+	}
+	return violations
+}
+
+// checkDiscount verifica o desconto do pedido resultante, mesmo que o patch não o altere,
+// contra o limite de quem o envia: o pedido base também vem do cliente. Uma recusa é
+// devolvida como violação das operações que escrevem o desconto.
+func (s *OrderSessionService) checkDiscount(ctx context.Context, patch jsonpatch.Patch, updated domain.Order) error {
+	err := AuthorizeDiscount(ctx, s.authz, updated)
+	if !errors.Is(err, domain.ErrForbidden) && !errors.Is(err, domain.ErrUnauthenticated) {
+		return err
+	}
+
+	var violations []domain.PatchViolation
+	for i, op := range patch {
+		if path, _ := op.Path(); path == "/discountPercentage" && op.Kind() != "test" {
+			violations = append(violations, domain.PatchViolation{Index: i, Op: op.Kind(), Path: path, Reason: err.Error()})
+		}
+	}
+	if len(violations) == 0 {
+		violations = append(violations, domain.PatchViolation{Index: -1, Op: "replace", Path: "/discountPercentage", Reason: err.Error()})
+	}
+	return &domain.ForbiddenPatchError{Violations: violations}
+}
+
+// applyOrderPatch aplica o patch RFC 6902 (incluindo operações test) ao pedido.
+func applyOrderPatch(order domain.Order, patch jsonpatch.Patch) (domain.Order, error) {
+	original, _ := json.Marshal(order)
+	modified, err := patch.Apply(original)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return order, err
+	}
+	if err != nil {
+		return order, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+
+	var updated domain.Order
+	if err := json.Unmarshal(modified, &updated); err != nil {
+		return order, fmt.Errorf("%w: %w", domain.ErrInvalidPatch, err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+func TestOrderPatch_OptimisticConcurrency(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+	patcher := NewOrderSessionService(engine, infrastructure.NewMemoryOrderSessionStore(time.Hour), domain.DefaultPatchPolicy(), testAuthorizer(t))
+	ctx := context.Background()
+
+	order := domain.Order{ID: "ORD-1", Currency: "AOA", RulesVersion: "v1.2", Items: []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}}}
+	initial, err := patcher.Create(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qty := func(n int) []byte {
+		return []byte(`[{"op": "replace", "path": "/items/0/qty", "value": ` + string(rune('0'+n)) + `}]`)
+	}
+
+	// Terminal 1 edita a revisão atual
+	first, err := patcher.Patch(ctx, order, qty(2), initial.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Revision == initial.Revision {
+		t.Fatal("a revisão deveria mudar com o pedido")
+	}
+
+	// Terminal 2 ainda tem a revisão inicial: conflito com o estado atual
+	_, err = patcher.Patch(ctx, order, qty(5), initial.Revision)
+	var precondition *domain.PreconditionError
+	if !errors.As(err, &precondition) || precondition.Current.Revision != first.Revision || precondition.Current.Order.Items[0].Qty != 2 {
+		t.Fatalf("esperado conflito com o estado atual, recebido %v", err)
+	}
+
+	// Operação test sobre o estado do servidor (qty já é 2)
+	stale := []byte(`[{"op": "test", "path": "/items/0/qty", "value": 1}, {"op": "replace", "path": "/items/0/qty", "value": 5}]`)
+	if _, err := patcher.Patch(ctx, order, stale, "*"); !errors.Is(err, domain.ErrPreconditionFailed) {
+		t.Fatalf("esperado falha da operação test, recebido %v", err)
+	}
+	fresh := []byte(`[{"op": "test", "path": "/items/0/qty", "value": 2}, {"op": "replace", "path": "/items/0/qty", "value": 5}]`)
+	if res, err := patcher.Patch(ctx, order, fresh, first.Revision); err != nil || res.StateFragment["totalItems"] != 5.0 {
+		t.Fatalf("patch com test válido falhou: %v", err)
+	}
+
+	if _, err := patcher.Patch(ctx, order, []byte(`[{"op": "bogus", "path": "/x"}]`), ""); !errors.Is(err, domain.ErrInvalidPatch) {
+		t.Fatalf("esperado ErrInvalidPatch, recebido %v", err)
+	}
+}
+
+func TestOrderPatch_Policy(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+	patcher := NewOrderSessionService(engine, infrastructure.NewMemoryOrderSessionStore(time.Hour), domain.DefaultPatchPolicy(), testAuthorizer(t))
+	order := domain.Order{Currency: "AOA", RulesVersion: "v1.2", Items: []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}}}
+
+	patch := []byte(`[
+		{"op": "replace", "path": "/items/0/qty", "value": 3},
+		{"op": "replace", "path": "/totalValue", "value": 1},
+		{"op": "test", "path": "/rulesVersion", "value": "v1.2"},
+		{"op": "add", "path": "/appliedTaxes/VAT", "value": 0},
+		{"op": "move", "from": "/rulesVersion", "path": "/currency"}
+	]`)
+	_, err := patcher.Patch(context.Background(), order, patch, "")
+	var forbidden *domain.ForbiddenPatchError
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("esperado ForbiddenPatchError, recebido %v", err)
+	}
+	var indexes []int
+	for _, v := range forbidden.Violations {
+		indexes = append(indexes, v.Index)
+	}
+	if len(indexes) != 3 || indexes[0] != 1 || indexes[1] != 3 || indexes[2] != 4 {
+		t.Fatalf("operações recusadas erradas: %+v", forbidden.Violations)
+	}
+
+	discount := []byte(`[{"op": "replace", "path": "/discountPercentage", "value": 0.1}]`)
+	if _, err := patcher.Patch(context.Background(), order, discount, ""); !errors.Is(err, domain.ErrForbiddenPatch) {
+		t.Fatalf("desconto de um pedido anónimo deveria ser recusado, recebido %v", err)
+	}
+	cashier := WithCaller(context.Background(), domain.Caller{UserID: "caixa", Permissions: []domain.Permission{domain.PermissionApplyDiscount}})
+	if _, err := patcher.Patch(cashier, order, discount, ""); !errors.Is(err, domain.ErrForbiddenPatch) {
+		t.Fatalf("desconto acima do limite por omissão (5%%) deveria ser recusado, recebido %v", err)
+	}
+	manager := WithCaller(context.Background(), domain.Caller{
+		UserID: "gerente", Permissions: []domain.Permission{domain.PermissionApplyDiscount},
+		Attributes: map[string]interface{}{"max_allowed_discount": 15},
+	})
+	if _, err := patcher.Patch(manager, order, discount, ""); err != nil {
+		t.Fatalf("desconto dentro do limite do gerente recusado: %v", err)
+	}
+
+	// O desconto e os campos do servidor no pedido base não escapam às verificações
+	discounted := order
+	discounted.DiscountPercentage = 0.9
+	if _, err := patcher.Patch(cashier, discounted, []byte(`[]`), ""); !errors.Is(err, domain.ErrForbiddenPatch) {
+		t.Fatalf("desconto no pedido base deveria ser verificado, recebido %v", err)
+	}
+	if _, err := patcher.Create(cashier, discounted); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("desconto acima do limite na criação deveria ser recusado, recebido %v", err)
+	}
+	forged := order
+	forged.BaseValue, forged.TotalValue, forged.AppliedTaxes = 1, 1, map[string]float64{"VAT": -100}
+	res, err := patcher.Patch(context.Background(), forged, []byte(`[]`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StateFragment["baseValue"] != 100.0 || res.StateFragment["totalValue"] == 1.0 {
+		t.Fatalf("os valores calculados não deveriam vir do cliente: %v", res.StateFragment)
+	}
+}
+
+func TestOrderSessions_IncrementalPatches(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+	sessions := NewOrderSessionService(engine, infrastructure.NewMemoryOrderSessionStore(time.Hour), domain.DefaultPatchPolicy(), testAuthorizer(t))
+	ctx := context.Background()
+
+	created, err := sessions.Create(ctx, domain.Order{Currency: "AOA", RulesVersion: "v1.2", Items: []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := created.StateFragment["id"].(string)
+	if id == "" {
+		t.Fatal("o pedido criado deveria ter um ID gerado")
+	}
+
+	merged, err := sessions.PatchSession(ctx, id, domain.PatchFormatMerge, []byte(`{"items": [{"sku": "A", "value": 100, "qty": 3}]}`), created.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := sessions.PatchSession(ctx, id, domain.PatchFormatJSON, []byte(`[{"op": "add", "path": "/items/-", "value": {"sku": "B", "value": 50, "qty": 1}}]`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.StateFragment["totalItems"] != 4.0 || patched.StateFragment["id"] != id {
+		t.Fatalf("o patch deveria partir do estado guardado: %v", patched.StateFragment)
+	}
+
+	session, err := sessions.Get(ctx, id)
+	if err != nil || session.Revision != patched.Revision || session.Result.Revision != patched.Revision {
+		t.Fatalf("GET deveria devolver o último resultado: %v", err)
+	}
+	if _, err := sessions.PatchSession(ctx, id, domain.PatchFormatMerge, []byte(`{"qty": 1}`), merged.Revision); !errors.Is(err, domain.ErrPreconditionFailed) {
+		t.Fatalf("esperado conflito de revisão, recebido %v", err)
+	}
+	_, err = sessions.PatchSession(ctx, id, domain.PatchFormatMerge, []byte(`{"totalValue": 1, "rulesVersion": null}`), "")
+	var forbidden *domain.ForbiddenPatchError
+	if !errors.As(err, &forbidden) || len(forbidden.Violations) != 2 {
+		t.Fatalf("merge patch sobre campos do servidor deveria ser recusado, recebido %v", err)
+	}
+	if _, err := sessions.Get(ctx, "ORD-INEXISTENTE"); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Fatalf("esperado ErrOrderNotFound, recebido %v", err)
+	}
+}
+
+// racingStore simula outro terminal que grava a sessão entre a leitura e a escrita das
+// primeiras races chamadas a CompareAndSwap.
+type racingStore struct {
+	interfaces.OrderSessionStore
+	races int
+}
+
+func (s *racingStore) CompareAndSwap(ctx context.Context, expected string, session domain.OrderSession) (bool, error) {
+	if s.races > 0 {
+		s.races--
+		latest, err := s.Get(ctx, session.OrderID)
+		if err != nil {
+			return false, err
+		}
+		latest.Revision += "-outro"
+		return false, s.Put(ctx, latest)
+	}
+	return s.OrderSessionStore.CompareAndSwap(ctx, expected, session)
+}
+
+// Sem If-Match, /orders/patch não sobrepõe uma alteração concorrente: repete o patch
+// sobre a sessão nova e, se perder sempre a corrida, devolve ErrConcurrentUpdate.
+func TestOrderPatch_ConcurrentWithoutIfMatch(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+	store := &racingStore{OrderSessionStore: infrastructure.NewMemoryOrderSessionStore(time.Hour)}
+	patcher := NewOrderSessionService(engine, store, domain.DefaultPatchPolicy(), testAuthorizer(t))
+	ctx := context.Background()
+
+	order := domain.Order{ID: "ORD-1", Currency: "AOA", RulesVersion: "v1.2", Items: []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}}}
+	if _, err := patcher.Create(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	patch := []byte(`[{"op": "replace", "path": "/items/0/qty", "value": 2}]`)
+
+	store.races = 1
+	result, err := patcher.Patch(ctx, order, patch, "")
+	if err != nil {
+		t.Fatalf("uma corrida perdida deveria ser repetida: %v", err)
+	}
+	if session, _ := store.Get(ctx, order.ID); session.Revision != result.Revision {
+		t.Fatalf("a sessão deveria ficar na revisão do patch: %s != %s", session.Revision, result.Revision)
+	}
+
+	store.races = patchAttempts
+	if _, err := patcher.Patch(ctx, order, patch, ""); !errors.Is(err, domain.ErrConcurrentUpdate) {
+		t.Fatalf("esperado ErrConcurrentUpdate, recebido %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Extensão do ficheiro de cada formato de talão
+var receiptExtensions = map[domain.ReceiptFormat]string{
+	domain.ReceiptText:   "txt",
+	domain.ReceiptESCPOS: "bin",
+	domain.ReceiptPDF:    "pdf",
+}
+
+// ReceiptService monta o talão de uma venda gravada com os dados mestre e entrega-o ao
+// renderizador do formato pedido.
+type ReceiptService struct {
+	sales     interfaces.SalesRepository
+	master    interfaces.MasterData
+	renderers map[domain.ReceiptFormat]interfaces.ReceiptRenderer
+}
+
+func NewReceiptService(sales interfaces.SalesRepository, master interfaces.MasterData, renderers map[domain.ReceiptFormat]interfaces.ReceiptRenderer) interfaces.Receipts {
+	return &ReceiptService{sales: sales, master: master, renderers: renderers}
+}
+
+func (s *ReceiptService) Render(ctx context.Context, saleID string, format domain.ReceiptFormat) (domain.RenderedReceipt, error) {
+	renderer, ok := s.renderers[format]
+	if !ok {
+		return domain.RenderedReceipt{}, fmt.Errorf("%w: %q (use %s)", domain.ErrUnknownReceiptFormat, format, strings.Join(receiptFormats(s.renderers), ", "))
+	}
+	sale, err := s.sales.Get(ctx, saleID)
+	if err != nil {
+		return domain.RenderedReceipt{}, err
+	}
+	company, err := s.master.Company(ctx)
+	if err != nil {
+		return domain.RenderedReceipt{}, err
+	}
+	// Artigos e impostos sem registo são impressos pelo SKU e pelo ID; só a falta dos
+	// ficheiros é tolerada, um ficheiro ilegível ou inválido é um erro
+	products, err := s.master.Products(ctx)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return domain.RenderedReceipt{}, err
+	}
+	taxes, err := s.master.Taxes(ctx)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return domain.RenderedReceipt{}, err
+	}
+
+	body, err := renderer.Render(buildReceipt(sale, company, products, taxes))
+	if err != nil {
+		return domain.RenderedReceipt{}, err
+	}
+	name := sale.ID
+	if sale.Document != nil {
+		name = strings.NewReplacer(" ", "_", "/", "_").Replace(sale.Document.No)
+	}
+	return domain.RenderedReceipt{
+		ContentType: renderer.ContentType(),
+		Filename:    name + "." + cmp.Or(receiptExtensions[format], string(format)),
+		Body:        body,
+	}, nil
+}
+
+func receiptFormats(renderers map[domain.ReceiptFormat]interfaces.ReceiptRenderer) []string {
+	var formats []string
+	for format := range renderers {
+		formats = append(formats, string(format))
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+// buildReceipt reúne o conteúdo do talão: os valores são os gravados na venda, nunca
+// recalculados, para que o talão diga o mesmo que o documento assinado e o SAF-T.
+func buildReceipt(sale domain.Sale, company domain.Company, products []domain.Product, taxes []domain.Tax) domain.Receipt {
+	names := make(map[string]string, len(products))
+	for _, p := range products {
+		names[p.SKU] = p.Name
+	}
+	taxNames := make(map[string]string, len(taxes))
+	for _, t := range taxes {
+		taxNames[t.ID] = t.Name
+	}
+
+	r := domain.Receipt{
+		Company:            company,
+		Title:              "Venda",
+		SaleID:             sale.ID,
+		IssuedAt:           sale.CreatedAt.Format("2006-01-02 15:04"),
+		Currency:           sale.Currency,
+		DiscountPercentage: sale.DiscountPercentage,
+		BaseValue:          sale.BaseValue,
+		Total:              sale.TotalValue,
+		OriginalDocumentNo: sale.OriginalDocumentNo,
+		ReturnReason:       sale.ReturnReason,
+		Void:               sale.Void,
+	}
+	for _, item := range sale.Items {
+		amount := round2(item.Value * float64(item.Qty))
+		r.Gross += amount
+		r.Lines = append(r.Lines, domain.ReceiptLine{
+			SKU: item.SKU, Description: cmp.Or(names[item.SKU], item.SKU),
+			Qty: item.Qty, UnitPrice: item.Value, Amount: amount,
+		})
+	}
+	r.Gross = round2(r.Gross)
+	if discount := round2(r.Gross - sale.BaseValue); discount > 0 {
+		r.Discount = discount
+	}
+
+	var taxTotal float64
+	for _, id := range slices.Sorted(maps.Keys(sale.AppliedTaxes)) {
+		amount := sale.AppliedTaxes[id]
+		taxTotal += amount
+		var rate float64
+		if sale.BaseValue != 0 {
+			rate = round2(amount / sale.BaseValue * 100)
+		}
+		r.Taxes = append(r.Taxes, domain.ReceiptTax{ID: id, Name: cmp.Or(taxNames[id], id), Rate: rate, Amount: amount})
+	}
+
+	docType := "VD"
+	state := "N"
+	if sale.Void != nil {
+		state = "A"
+	}
+	if doc := sale.Document; doc != nil {
+		docType = string(doc.Type)
+		r.DocumentNo = doc.No
+		r.Title = receiptTitles[doc.Type]
+		r.SignatureExcerpt = signatureExcerpt(doc.Hash)
+	}
+	if company.SoftwareValidationNumber != "" {
+		r.Certification = "Processado por programa válido n.º " + company.SoftwareValidationNumber
+	}
+	r.QRPayload = strings.Join([]string{
+		"A:" + company.TaxID,
+		"B:" + saftFinalConsumerTaxID,
+		"C:" + saftCountry,
+		"D:" + docType,
+		"E:" + state,
+		"F:" + sale.CreatedAt.Format("20060102"),
+		"G:" + cmp.Or(r.DocumentNo, sale.ID),
+		fmt.Sprintf("N:%.2f", round2(taxTotal)),
+		fmt.Sprintf("O:%.2f", sale.TotalValue),
+		"Q:" + cmp.Or(r.SignatureExcerpt, "0"),
+		"R:" + cmp.Or(company.SoftwareValidationNumber, "0"),
+	}, "*")
+	return r
+}
+
+var receiptTitles = map[domain.DocumentType]string{
+	domain.DocumentInvoice:        "Fatura",
+	domain.DocumentInvoiceReceipt: "Fatura-Recibo",
+	domain.DocumentCreditNote:     "Nota de Crédito",
+}
+
+// signatureExcerpt devolve o 1.º, 11.º, 21.º e 31.º caracteres do hash, a parte da
+// assinatura impressa nos documentos.
+func signatureExcerpt(hash string) string {
+	if len(hash) < 31 {
+		return ""
+	}
+	return string([]byte{hash[0], hash[10], hash[20], hash[30]})
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+func TestReceiptService_Render(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "company.json"), []byte(`{"taxId": "5000000000", "name": "Empresa", "softwareValidationNumber": "1/AGT/2026"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "products.json"), []byte(`[{"sku": "A", "name": "Arroz"}]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "taxs.json"), []byte(`[{"id": "VAT", "name": "IVA", "rate": 0.14}]`), 0o644)
+	repo, err := infrastructure.NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+
+	doc := domain.FiscalDocument{Type: domain.DocumentInvoiceReceipt, Series: "A"}
+	sale := domain.Sale{
+		Order: domain.Order{
+			ID: "SALE-1", Currency: "AOA", BaseValue: 225, DiscountPercentage: 0.1, TotalValue: 256.5,
+			Items:        []domain.OrderItem{{SKU: "A", Value: 100, Qty: 2}, {SKU: "B", Value: 50, Qty: 1}},
+			AppliedTaxes: map[string]float64{"VAT": 31.5},
+		},
+		CreatedAt: time.Date(2026, 1, 24, 3, 39, 0, 0, time.UTC), Document: &doc,
+	}
+	if _, err := repo.Issue(ctx, sale, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	products, _ := infrastructure.NewFileMasterData(dir).Products(ctx)
+	stored, _ := repo.Get(ctx, "SALE-1")
+	signed := *stored.Document
+	signed.Hash = strings.Repeat("0123456789", 4) // Extrato: 1.º, 11.º, 21.º e 31.º caracteres
+	stored.Document = &signed
+	receipt := buildReceipt(stored, domain.Company{TaxID: "5000000000", SoftwareValidationNumber: "1/AGT/2026"}, products, nil)
+	if receipt.Discount != 25 || receipt.Gross != 250 || receipt.Taxes[0].Rate != 14 || receipt.Lines[1].Description != "B" {
+		t.Fatalf("valores do talão inesperados: %+v", receipt)
+	}
+	if want := "A:5000000000*B:999999999*C:AO*D:FR*E:N*F:20260124*G:FR A/1*N:31.50*O:256.50*Q:0000*R:1/AGT/2026"; receipt.QRPayload != want {
+		t.Fatalf("payload QR:\n%s\nesperado:\n%s", receipt.QRPayload, want)
+	}
+
+	receipts := NewReceiptService(repo, infrastructure.NewFileMasterData(dir), map[domain.ReceiptFormat]interfaces.ReceiptRenderer{
+		domain.ReceiptText: infrastructure.NewTextReceiptRenderer(),
+	})
+	out, err := receipts.Render(ctx, "SALE-1", domain.ReceiptText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Filename != "FR_A_1.txt" || !strings.HasPrefix(out.ContentType, "text/plain") {
+		t.Fatalf("ficheiro inesperado: %s (%s)", out.Filename, out.ContentType)
+	}
+	for _, want := range []string{"FATURA-RECIBO", "FR A/1", "Arroz", "Desconto (10%)", "-25,00", "IVA 14%", "256,50", "Processado por programa válido n.º"} {
+		if !strings.Contains(string(out.Body), want) {
+			t.Errorf("talão sem %q:\n%s", want, out.Body)
+		}
+	}
+
+	if _, err := receipts.Render(ctx, "SALE-1", domain.ReceiptPDF); !errors.Is(err, domain.ErrUnknownReceiptFormat) {
+		t.Fatalf("esperado ErrUnknownReceiptFormat, recebido %v", err)
+	}
+	if _, err := receipts.Render(ctx, "SALE-X", domain.ReceiptText); !errors.Is(err, domain.ErrSaleNotFound) {
+		t.Fatalf("esperado ErrSaleNotFound, recebido %v", err)
+	}
+
+	// Sem products.json o talão usa os SKU; um ficheiro inválido é um erro
+	os.Remove(filepath.Join(dir, "products.json"))
+	if _, err := receipts.Render(ctx, "SALE-1", domain.ReceiptText); err != nil {
+		t.Fatalf("products.json em falta deveria ser tolerado: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "taxs.json"), []byte(`{`), 0o644)
+	if _, err := receipts.Render(ctx, "SALE-1", domain.ReceiptText); err == nil || !strings.Contains(err.Error(), "taxs.json") {
+		t.Fatalf("esperado erro de taxs.json inválido, recebido %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// ReturnService emite as notas de crédito. Os itens devolvidos são recalculados com a versão
+// de regras fixada na venda, pelo que o desconto e cada imposto são revertidos pelas mesmas
+// regras com que foram cobrados, e a soma das notas nunca excede a venda.
+type ReturnService struct {
+	engine      interfaces.EngineFacade
+	sales       interfaces.SalesRepository
+	series      domain.SeriesCatalog
+	signer      interfaces.DocumentSigner
+	corrections sync.Locker
+	now         func() time.Time
+}
+
+// NewReturnService cria o serviço de devoluções. corrections é o lock partilhado com o
+// VoidService do mesmo repositório: a verificação das notas já emitidas e a gravação da
+// nova nota ou anulação não podem intercalar. O repositório de vendas só é aberto por um
+// processo, pelo que um lock local basta.
+func NewReturnService(engine interfaces.EngineFacade, sales interfaces.SalesRepository, series domain.SeriesCatalog, signer interfaces.DocumentSigner, corrections sync.Locker) interfaces.SaleReturns {
+	return &ReturnService{engine: engine, sales: sales, series: series, signer: signer, corrections: corrections, now: time.Now}
+}
+
+func (s *ReturnService) Return(ctx context.Context, saleID string, req domain.ReturnRequest) (domain.Sale, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return domain.Sale{}, fmt.Errorf("%w: o motivo da devolução é obrigatório", domain.ErrInvalidReturn)
+	}
+	s.corrections.Lock()
+	defer s.corrections.Unlock()
+
+	original, err := s.sales.Get(ctx, saleID)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	switch {
+	case original.Void != nil:
+		return domain.Sale{}, fmt.Errorf("%w: %s", domain.ErrSaleVoided, saleID)
+	case original.Document == nil:
+		return domain.Sale{}, fmt.Errorf("%w: a venda %s não tem documento fiscal", domain.ErrInvalidReturn, saleID)
+	case original.Document.Type == domain.DocumentCreditNote:
+		return domain.Sale{}, fmt.Errorf("%w: %s já é uma nota de crédito", domain.ErrInvalidReturn, original.Document.No)
+	}
+	previous, err := creditNotes(ctx, s.sales, saleID)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+
+	items, complete, err := returnedItems(original, previous, req.Items)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	credited, warnings, err := s.credit(ctx, original, previous, items, complete)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+
+	storeID, terminalID := req.StoreID, req.TerminalID
+	if storeID == "" {
+		storeID = original.Document.StoreID
+	}
+	if terminalID == "" {
+		terminalID = original.Document.TerminalID
+	}
+	doc, err := s.series.Document(domain.DocumentCreditNote, storeID, terminalID)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+
+	at := s.now()
+	note := domain.Sale{
+		Order:              credited,
+		TenantID:           original.TenantID,
+		CreatedAt:          at,
+		Document:           &doc,
+		Warnings:           warnings,
+		OriginalSaleID:     original.ID,
+		OriginalDocumentNo: original.Document.No,
+		ReturnReason:       strings.TrimSpace(req.Reason),
+	}
+	note.ID = newSaleID(at)
+	return s.sales.Issue(ctx, note, s.signer)
+}
+
+// creditNotes devolve as notas de crédito não anuladas emitidas sobre a venda.
+func creditNotes(ctx context.Context, sales interfaces.SalesRepository, saleID string) ([]domain.Sale, error) {
+	var notes []domain.Sale
+	q := domain.SalesQuery{OriginalSaleID: saleID, Status: domain.SaleStatusActive, Sort: domain.SortSalesByID}
+	for {
+		page, err := sales.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, page.Sales...)
+		if page.NextCursor == "" {
+			return notes, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// credit calcula os valores da nota executando os itens devolvidos, com o desconto da
+// venda, no motor e na versão de regras com que a venda foi feita; as guardas não se
+// aplicam, porque a venda já foi aceite. Cada valor fica limitado ao que falta creditar e a
+// devolução que completa a venda credita exatamente o resto. Só se o pack já não existir,
+// ou for recusado pela validação (como a v1.0), a nota recorre à proporção dos valores
+// faturados (creditedOrder), com aviso em warnings.
+func (s *ReturnService) credit(ctx context.Context, original domain.Sale, previous []domain.Sale, items []domain.OrderItem, complete bool) (domain.Order, []string, error) {
+	returned := original.Order
+	returned.Items = items
+	result, err := s.engine.RunEngine(ctx, returned, original.RulesVersion)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, domain.ErrInvalidRulePack) {
+		warning := fmt.Sprintf("o pack %s já não existe ou não pode ser executado: valores creditados na proporção dos faturados", original.RulesVersion)
+		return creditedOrder(original, previous, items, complete), []string{warning}, nil
+	}
+	if err != nil {
+		return domain.Order{}, nil, err
+	}
+
+	var warnings []string
+	if original.RulesHash != "" && result.RulesHash != original.RulesHash {
+		warnings = append(warnings, fmt.Sprintf("o pack %s mudou desde a venda (hash %s, atual %s)", original.RulesVersion, original.RulesHash, result.RulesHash))
+	}
+	raw, _ := json.Marshal(result.StateFragment)
+	var recomputed domain.Order
+	if err := json.Unmarshal(raw, &recomputed); err != nil {
+		return domain.Order{}, nil, err
+	}
+
+	// Valor por creditar: o faturado menos o que as notas anteriores já creditaram
+	remaining := func(total float64, credited func(domain.Sale) float64) float64 {
+		for _, note := range previous {
+			total -= credited(note)
+		}
+		return round2(total)
+	}
+	capped := func(value, left float64) float64 {
+		if complete {
+			return left
+		}
+		return round2(math.Min(value, left))
+	}
+
+	order := domain.Order{
+		Currency:           original.Currency,
+		Items:              items,
+		AppliedTaxes:       make(map[string]float64, len(original.AppliedTaxes)),
+		TotalItems:         recomputed.TotalItems,
+		DiscountPercentage: original.DiscountPercentage,
+		RulesVersion:       original.RulesVersion,
+		RulesHash:          original.RulesHash,
+		CorrelationID:      original.CorrelationID,
+	}
+	order.BaseValue = capped(recomputed.BaseValue, remaining(original.BaseValue, func(note domain.Sale) float64 { return note.BaseValue }))
+	taxes := 0.0
+	for id, value := range original.AppliedTaxes {
+		order.AppliedTaxes[id] = capped(recomputed.AppliedTaxes[id], remaining(value, func(note domain.Sale) float64 { return note.AppliedTaxes[id] }))
+		taxes += order.AppliedTaxes[id]
+	}
+	// Só se credita o que foi faturado: um imposto que a venda não cobrou fica de fora
+	for id := range recomputed.AppliedTaxes {
+		if _, billed := original.AppliedTaxes[id]; !billed {
+			warnings = append(warnings, fmt.Sprintf("o recálculo com %s aplica o imposto %s, que a venda não cobrou", original.RulesVersion, id))
+		}
+	}
+	order.TotalValue = round2(order.BaseValue + taxes)
+	if complete {
+		order.TotalValue = remaining(original.TotalValue, func(note domain.Sale) float64 { return note.TotalValue })
+	}
+	return order, warnings, nil
+}
+
+// returnedItems reparte as unidades pedidas pelas linhas da venda, descontando as já
+// devolvidas, e indica se com esta devolução a venda fica totalmente devolvida.
+func returnedItems(original domain.Sale, previous []domain.Sale, requested []domain.ReturnItem) ([]domain.OrderItem, bool, error) {
+	// Unidades ainda por devolver em cada linha, consumindo as devoluções anteriores por ordem
+	returned := make(map[string]int)
+	for _, note := range previous {
+		for _, item := range note.Items {
+			returned[item.SKU] += item.Qty
+		}
+	}
+	available := make([]int, len(original.Items))
+	for i, item := range original.Items {
+		taken := min(item.Qty, returned[item.SKU])
+		returned[item.SKU] -= taken
+		available[i] = item.Qty - taken
+	}
+
+	wanted := make(map[string]int)
+	if len(requested) == 0 {
+		for i, item := range original.Items {
+			wanted[item.SKU] += available[i]
+		}
+	}
+	for _, r := range requested {
+		if r.Qty <= 0 {
+			return nil, false, fmt.Errorf("%w: quantidade de %s tem de ser positiva", domain.ErrInvalidReturn, r.SKU)
+		}
+		wanted[r.SKU] += r.Qty
+	}
+
+	var items []domain.OrderItem
+	for i, item := range original.Items {
+		take := min(available[i], wanted[item.SKU])
+		if take == 0 {
+			continue
+		}
+		wanted[item.SKU] -= take
+		available[i] -= take
+		items = append(items, domain.OrderItem{SKU: item.SKU, Value: item.Value, Qty: take})
+	}
+	for sku, qty := range wanted {
+		if qty == 0 {
+			continue
+		}
+		if !containsSKU(original.Items, sku) {
+			return nil, false, fmt.Errorf("%w: %s não consta da venda %s", domain.ErrInvalidReturn, sku, original.ID)
+		}
+		asked := 0
+		for _, r := range requested {
+			if r.SKU == sku {
+				asked += r.Qty
+			}
+		}
+		return nil, false, fmt.Errorf("%w: pedidas %d unidade(s) de %s, só %d por devolver", domain.ErrOverReturn, asked, sku, asked-qty)
+	}
+	if len(items) == 0 {
+		return nil, false, fmt.Errorf("%w: a venda %s já foi totalmente devolvida", domain.ErrOverReturn, original.ID)
+	}
+
+	complete := true
+	for _, n := range available {
+		complete = complete && n == 0
+	}
+	return items, complete, nil
+}
+
+func containsSKU(items []domain.OrderItem, sku string) bool {
+	for _, item := range items {
+		if item.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// creditedOrder calcula os valores da nota quando o pack da venda já não existe: a fração
+// do valor bruto devolvido aplicada à base, a cada imposto e ao total da venda. A última devolução credita o que falta,
+// para que os arredondamentos não deixem cêntimos por creditar.
+func creditedOrder(original domain.Sale, previous []domain.Sale, items []domain.OrderItem, complete bool) domain.Order {
+	var gross, returned float64
+	for _, item := range original.Items {
+		gross += item.Value * float64(item.Qty)
+	}
+	qty := 0
+	for _, item := range items {
+		returned += item.Value * float64(item.Qty)
+		qty += item.Qty
+	}
+	share := 0.0
+	if gross > 0 {
+		share = returned / gross
+	}
+
+	credit := func(total float64, credited func(domain.Sale) float64) float64 {
+		if !complete {
+			return round2(total * share)
+		}
+		for _, note := range previous {
+			total -= credited(note)
+		}
+		return round2(total)
+	}
+	taxes := make(map[string]float64, len(original.AppliedTaxes))
+	for id, value := range original.AppliedTaxes {
+		taxes[id] = credit(value, func(note domain.Sale) float64 { return note.AppliedTaxes[id] })
+	}
+	return domain.Order{
+		Currency:           original.Currency,
+		BaseValue:          credit(original.BaseValue, func(note domain.Sale) float64 { return note.BaseValue }),
+		Items:              items,
+		AppliedTaxes:       taxes,
+		TotalItems:         qty,
+		DiscountPercentage: original.DiscountPercentage,
+		TotalValue:         credit(original.TotalValue, func(note domain.Sale) float64 { return note.TotalValue }),
+		RulesVersion:       original.RulesVersion,
+		RulesHash:          original.RulesHash,
+		CorrelationID:      original.CorrelationID,
+	}
+}
@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+)
+
+func TestReturnService_CreditNotes(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+	repo, err := infrastructure.NewFileSalesRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+
+	order := domain.Order{
+		Currency: "BRL",
+		Items:    []domain.OrderItem{{SKU: "PROD-001", Value: 100, Qty: 3}, {SKU: "PROD-002", Value: 50, Qty: 1}},
+	}
+	result, err := engine.RunEngine(ctx, order, "v1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale, err := NewSale(result, "T1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale.Document = &domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+	if sale, err = repo.Issue(ctx, sale, nil); err != nil {
+		t.Fatal(err)
+	}
+	returns := NewReturnService(engine, repo, domain.SeriesCatalog{}, nil, &sync.Mutex{})
+
+	if _, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Items: []domain.ReturnItem{{SKU: "PROD-001", Qty: 1}}}); !errors.Is(err, domain.ErrInvalidReturn) {
+		t.Fatalf("sem motivo deveria dar ErrInvalidReturn, recebido %v", err)
+	}
+	if _, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Items: []domain.ReturnItem{{SKU: "PROD-009", Qty: 1}}, Reason: "x"}); !errors.Is(err, domain.ErrInvalidReturn) {
+		t.Fatalf("SKU fora da venda deveria dar ErrInvalidReturn, recebido %v", err)
+	}
+
+	// Devolução parcial: 1 de 3 unidades de PROD-001 é 100/350 da venda
+	partial, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Items: []domain.ReturnItem{{SKU: "PROD-001", Qty: 1}}, Reason: "Avariado"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial.Document.No != "NC MAIN-1/1" || partial.OriginalDocumentNo != "FT A/1" || partial.OriginalSaleID != sale.ID || partial.ReturnReason != "Avariado" {
+		t.Fatalf("nota de crédito mal identificada: %+v, %+v", partial.Document, partial)
+	}
+	if want := math.Round(sale.TotalValue*100/350*100) / 100; partial.TotalValue != want || partial.TotalItems != 1 {
+		t.Fatalf("total da nota: esperado %.2f, recebido %.2f", want, partial.TotalValue)
+	}
+
+	if _, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Items: []domain.ReturnItem{{SKU: "PROD-001", Qty: 3}}, Reason: "x"}); !errors.Is(err, domain.ErrOverReturn) {
+		t.Fatalf("3 unidades quando restam 2 deveria dar ErrOverReturn, recebido %v", err)
+	}
+	if _, err := returns.Return(ctx, partial.ID, domain.ReturnRequest{Reason: "x"}); !errors.Is(err, domain.ErrInvalidReturn) {
+		t.Fatalf("devolver uma nota de crédito deveria dar ErrInvalidReturn, recebido %v", err)
+	}
+
+	// Sem itens devolve o resto, e as notas somam exatamente a venda
+	rest, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Reason: "Desistência"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.TotalItems != 3 || math.Abs(partial.TotalValue+rest.TotalValue-sale.TotalValue) > 1e-9 {
+		t.Fatalf("as notas deveriam somar %.2f: %.2f + %.2f", sale.TotalValue, partial.TotalValue, rest.TotalValue)
+	}
+	for id, value := range sale.AppliedTaxes {
+		if math.Abs(partial.AppliedTaxes[id]+rest.AppliedTaxes[id]-value) > 1e-9 {
+			t.Fatalf("imposto %s creditado %.2f + %.2f, faturado %.2f", id, partial.AppliedTaxes[id], rest.AppliedTaxes[id], value)
+		}
+	}
+	if _, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Reason: "x"}); !errors.Is(err, domain.ErrOverReturn) {
+		t.Fatalf("venda já devolvida deveria dar ErrOverReturn, recebido %v", err)
+	}
+
+	// Um pack que já não existe não impede a devolução: a nota parte dos valores faturados
+	retired := sale
+	retired.ID, retired.RulesVersion = "SALE-RETIRED", "v9.9"
+	retired.Document = &domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+	if retired, err = repo.Issue(ctx, retired, nil); err != nil {
+		t.Fatal(err)
+	}
+	note, err := returns.Return(ctx, retired.ID, domain.ReturnRequest{Reason: "Avariado"})
+	if err != nil {
+		t.Fatalf("a devolução não deveria depender do recálculo: %v", err)
+	}
+	if note.TotalValue != retired.TotalValue || len(note.Warnings) == 0 || !strings.Contains(note.Warnings[0], "v9.9") {
+		t.Fatalf("esperada nota de %.2f com aviso sobre v9.9: %.2f %v", retired.TotalValue, note.TotalValue, note.Warnings)
+	}
+}
+
+// A nota credita os itens devolvidos recalculados com o pack da venda, e não a proporção
+// do faturado: um desconto por volume que a devolução parcial deixa de atingir não entra.
+func TestReturnService_CreditsPinnedRules(t *testing.T) {
+	dir := t.TempDir()
+	pack := `{"version": "v1", "rules": [
+		{"id": "R_VOLUME_DISCOUNT", "phase": "orderAdjust", "logic": {"if": [{">": [{"var": "order.baseValue"}, 300]}, {"*": [{"var": "order.baseValue"}, 0.9]}, {"var": "order.baseValue"}]}, "output_key": "order.baseValue"},
+		{"id": "R_VAT", "phase": "taxes", "logic": {"*": [{"var": "order.baseValue"}, 0.2]}, "output_key": "order.appliedTaxes.VAT"}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "v1_rules.json"), []byte(pack), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(dir, nil), infrastructure.NewJsonLogicExecutor())
+	repo, err := infrastructure.NewFileSalesRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+
+	order := domain.Order{
+		Currency: "AOA",
+		Items:    []domain.OrderItem{{SKU: "PROD-001", Value: 100, Qty: 3}, {SKU: "PROD-002", Value: 50, Qty: 1}},
+	}
+	result, err := engine.RunEngine(ctx, order, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale, err := NewSale(result, "T1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale.Document = &domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+	if sale, err = repo.Issue(ctx, sale, nil); err != nil {
+		t.Fatal(err)
+	}
+	if sale.TotalValue != 378 {
+		t.Fatalf("venda com desconto por volume: esperado 378, recebido %.2f", sale.TotalValue)
+	}
+	returns := NewReturnService(engine, repo, domain.SeriesCatalog{}, nil, &sync.Mutex{})
+
+	// 1 unidade de PROD-001 não atinge o desconto: 100 + 20 de IVA, e não 378*100/350
+	partial, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Items: []domain.ReturnItem{{SKU: "PROD-001", Qty: 1}}, Reason: "Avariado"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial.BaseValue != 100 || partial.AppliedTaxes["VAT"] != 20 || partial.TotalValue != 120 {
+		t.Fatalf("esperada nota de 100 + 20 = 120, recebido %.2f + %v = %.2f", partial.BaseValue, partial.AppliedTaxes, partial.TotalValue)
+	}
+
+	// O resto recalculado (300) excede o que falta creditar: a nota fica limitada a 258
+	rest, err := returns.Return(ctx, sale.ID, domain.ReturnRequest{Reason: "Desistência"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.TotalValue != 258 || partial.AppliedTaxes["VAT"]+rest.AppliedTaxes["VAT"] != sale.AppliedTaxes["VAT"] {
+		t.Fatalf("as notas deveriam somar a venda: %.2f + %.2f, IVA %v + %v", partial.TotalValue, rest.TotalValue, partial.AppliedTaxes, rest.AppliedTaxes)
+	}
+}
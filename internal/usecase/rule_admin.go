@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/Victor-armando18/service-commercial/pkg/engine"
+)
+
+// RulePackAdminService implementa a gestão de RulePacks: publicação de versões
+// imutáveis, ativação por tenant e histórico de quem alterou o quê. As alterações exigem
+// a permissão domain.PermissionManageRules e o autor registado é o utilizador autenticado.
+type RulePackAdminService struct {
+	repo        interfaces.RulePackRepository
+	activations interfaces.RuleActivationStore
+	audit       interfaces.AuditLog
+	authz       interfaces.Authorizer
+	now         func() time.Time
+}
+
+func NewRulePackAdminService(repo interfaces.RulePackRepository, activations interfaces.RuleActivationStore, audit interfaces.AuditLog, authz interfaces.Authorizer) interfaces.RulePackAdmin {
+	return &RulePackAdminService{repo: repo, activations: activations, audit: audit, authz: authz, now: time.Now}
+}
+
+func (s *RulePackAdminService) List(ctx context.Context) ([]domain.RulePackInfo, error) {
+	history, err := s.audit.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]domain.AuditEntry)
+	for _, entry := range history {
+		if entry.Action == domain.AuditRulePackPublished {
+			published[entry.Version] = entry
+		}
+	}
+	activeFor := make(map[string][]string)
+	for tenant, version := range s.activations.All(ctx) {
+		activeFor[version] = append(activeFor[version], tenant)
+	}
+
+	var infos []domain.RulePackInfo
+	for _, version := range s.repo.Versions(ctx) {
+		def, err := s.repo.Load(ctx, version)
+		if err != nil {
+			continue // Removido entre a listagem e a leitura
+		}
+		info := domain.RulePackInfo{
+			Version:     version,
+			Description: def.Description,
+			ContentHash: def.ContentHash,
+			Rules:       len(def.Rules),
+			ActiveFor:   activeFor[version],
+		}
+		sort.Strings(info.ActiveFor)
+		if entry, ok := published[version]; ok {
+			at := entry.At
+			info.PublishedBy, info.PublishedAt = entry.Actor, &at
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *RulePackAdminService) Get(ctx context.Context, version string) (*domain.RulePackDefinition, error) {
+	if !domain.ValidVersion(version) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRulePackNotFound, version)
+	}
+	def, err := s.repo.Load(ctx, version)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRulePackNotFound, version)
+	}
+	return def, err
+}
+
+func (s *RulePackAdminService) Publish(ctx context.Context, upload domain.RulePackUpload) (*domain.RulePackDefinition, error) {
+	if _, err := authorize(ctx, s.authz, domain.PermissionManageRules); err != nil {
+		return nil, err
+	}
+	def, err := s.repo.Publish(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+	entry := domain.AuditEntry{
+		At:          s.now(),
+		Actor:       CallerFrom(ctx).UserID,
+		Action:      domain.AuditRulePackPublished,
+		Version:     def.Version,
+		ContentHash: def.ContentHash,
+	}
+	// Uma versão publicada tem sempre autor registado: sem auditoria a publicação é desfeita
+	if err := s.audit.Record(ctx, entry); err != nil {
+		if undo := s.repo.Unpublish(ctx, def.Version); undo != nil {
+			return nil, fmt.Errorf("auditoria da publicação de %s falhou (%w) e a versão não pôde ser retirada: %v", def.Version, err, undo)
+		}
+		return nil, fmt.Errorf("auditoria da publicação de %s falhou, versão retirada: %w", def.Version, err)
+	}
+	return def, nil
+}
+
+func (s *RulePackAdminService) Diff(ctx context.Context, from, to string) (*domain.RulePackDiff, error) {
+	a, err := s.Get(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.Get(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.RulePackDiff{
+		PackDiff: engine.DiffPacks(enginePack(a), enginePack(b)),
+		FromHash: a.ContentHash,
+		ToHash:   b.ContentHash,
+	}, nil
+}
+
+// Activate fixa version como a versão de regras do tenant: os pedidos com X-Tenant-ID passam
+// a ser calculados com ela, e os que indicam outra rulesVersion são recusados com 409.
+func (s *RulePackAdminService) Activate(ctx context.Context, tenant, version string) error {
+	if _, err := authorize(ctx, s.authz, domain.PermissionManageRules); err != nil {
+		return err
+	}
+	def, err := s.Get(ctx, version)
+	if err != nil {
+		return err
+	}
+	previous, _ := s.activations.Active(ctx, tenant)
+	if err := s.activations.SetActive(ctx, tenant, version); err != nil {
+		return err
+	}
+	return s.audit.Record(ctx, domain.AuditEntry{
+		At:          s.now(),
+		Actor:       CallerFrom(ctx).UserID,
+		Action:      domain.AuditRulePackActivated,
+		Version:     version,
+		ContentHash: def.ContentHash,
+		Tenant:      tenant,
+		Previous:    previous,
+	})
+}
+
+func (s *RulePackAdminService) ActiveVersion(ctx context.Context, tenant string) (string, bool) {
+	return s.activations.Active(ctx, tenant)
+}
+
+func (s *RulePackAdminService) History(ctx context.Context) ([]domain.AuditEntry, error) {
+	return s.audit.List(ctx)
+}
+
+// enginePack converte a definição para o tipo de pkg/engine, que tem a comparação de packs
+// usada também pelo comando diff. Logic já inclui o resultado da compilação de expr.
+func enginePack(def *domain.RulePackDefinition) *engine.RulePack {
+	pack := &engine.RulePack{Version: def.Version, Description: def.Description, ContentHash: def.ContentHash}
+	for _, rule := range def.Rules {
+		pack.Rules = append(pack.Rules, engine.RuleConfig{
+			ID:           rule.ID,
+			Phase:        rule.Phase,
+			Logic:        rule.Logic,
+			OutputKey:    rule.OutputKey,
+			ErrorMessage: rule.ErrorMessage,
+		})
+	}
+	return pack
+}
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+func TestRulePackAdmin_PublishActivateDiff(t *testing.T) {
+	dir, dataDir := t.TempDir(), t.TempDir()
+	ctx := context.Background()
+
+	executor := infrastructure.NewJsonLogicExecutor()
+	repo := infrastructure.NewFileRuleLoader(dir, NewRulePackValidator(executor))
+	activations, err := infrastructure.NewFileActivationStore(filepath.Join(dataDir, "activations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := NewRulePackAdminService(repo, activations, infrastructure.NewFileAuditLog(filepath.Join(dataDir, "audit.jsonl")), testAuthorizer(t))
+	manage := []domain.Permission{domain.PermissionManageRules}
+	ana := WithCaller(ctx, domain.Caller{UserID: "ana", Permissions: manage})
+	rui := WithCaller(ctx, domain.Caller{UserID: "rui", Permissions: manage})
+
+	v1 := []byte(`{"version": "v1", "rules": [
+		{"id": "R_VAT", "phase": "taxes", "logic": {"*": [{"var": "order.baseValue"}, 0.14]}, "output_key": "order.appliedTaxes.VAT"},
+		{"id": "R_OLD", "phase": "totals", "logic": {"var": "order.baseValue"}, "output_key": "order.totalValue"}]}`)
+	v2 := []byte("version: v2\nrules:\n  - id: R_VAT\n    phase: taxes\n    expr: order.baseValue * 0.2\n    output_key: order.appliedTaxes.VAT\n")
+
+	// Sem a permissão de gestão de regras nada é publicado nem ativado
+	if _, err := admin.Publish(ctx, domain.RulePackUpload{Format: "json", Content: v1}); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Fatalf("esperado ErrUnauthenticated, recebido %v", err)
+	}
+	cashier := WithCaller(ctx, domain.Caller{UserID: "caixa", Permissions: []domain.Permission{domain.PermissionApplyDiscount}})
+	if err := admin.Activate(cashier, "acme", "v1"); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("esperado ErrForbidden, recebido %v", err)
+	}
+
+	if _, err := admin.Publish(ana, domain.RulePackUpload{Format: "json", Content: v1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Publish(ana, domain.RulePackUpload{Format: "yaml", Content: v2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Versões publicadas são imutáveis
+	if _, err := admin.Publish(rui, domain.RulePackUpload{Format: "json", Content: v1}); !errors.Is(err, domain.ErrRulePackExists) {
+		t.Fatalf("esperado ErrRulePackExists, recebido %v", err)
+	}
+	// Packs inválidos não chegam ao repositório
+	invalid := []byte(`{"version": "v3", "rules": [{"id": "R", "phase": "taxes", "logic": {"var": "order.nope"}, "output_key": "order.appliedTaxes.VAT"}]}`)
+	if _, err := admin.Publish(ana, domain.RulePackUpload{Format: "json", Content: invalid}); !errors.Is(err, domain.ErrInvalidRulePack) {
+		t.Fatalf("esperado ErrInvalidRulePack, recebido %v", err)
+	}
+
+	if err := admin.Activate(rui, "acme", "v9"); !errors.Is(err, domain.ErrRulePackNotFound) {
+		t.Fatalf("esperado ErrRulePackNotFound, recebido %v", err)
+	}
+	if err := admin.Activate(rui, "acme", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := admin.ActiveVersion(ctx, "acme"); version != "v2" {
+		t.Fatalf("versão ativa inesperada: %s", version)
+	}
+
+	infos, err := admin.List(ctx)
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("listagem inesperada: %v %+v", err, infos)
+	}
+	if infos[1].PublishedBy != "ana" || len(infos[1].ActiveFor) != 1 || infos[1].ActiveFor[0] != "acme" {
+		t.Fatalf("metadados de v2 inesperados: %+v", infos[1])
+	}
+
+	diff, err := admin.Diff(ctx, "v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != "R_OLD" || len(diff.LogicChanges) != 1 || diff.LogicChanges[0].RuleID != "R_VAT" || diff.FromHash == diff.ToHash {
+		t.Fatalf("diff inesperado: %+v", diff)
+	}
+
+	history, _ := admin.History(ctx)
+	if len(history) != 3 || history[2].Action != domain.AuditRulePackActivated || history[2].Actor != "rui" {
+		t.Fatalf("auditoria inesperada: %+v", history)
+	}
+}
+
+// brokenAuditLog recusa todas as entradas, como um disco cheio.
+type brokenAuditLog struct {
+	interfaces.AuditLog
+}
+
+func (brokenAuditLog) Record(ctx context.Context, entry domain.AuditEntry) error {
+	return errors.New("disco cheio")
+}
+
+func TestRulePackAdmin_PublishWithoutAudit(t *testing.T) {
+	dir, dataDir := t.TempDir(), t.TempDir()
+	repo := infrastructure.NewFileRuleLoader(dir, nil)
+	activations, err := infrastructure.NewFileActivationStore(filepath.Join(dataDir, "activations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := NewRulePackAdminService(repo, activations, brokenAuditLog{}, testAuthorizer(t))
+	ana := WithCaller(context.Background(), domain.Caller{UserID: "ana", Permissions: []domain.Permission{domain.PermissionManageRules}})
+
+	// Sem registo de quem publicou, a versão não pode ficar visível
+	v1 := []byte(`{"version": "v1", "rules": []}`)
+	if _, err := admin.Publish(ana, domain.RulePackUpload{Format: "json", Content: v1}); err == nil {
+		t.Fatal("esperado erro de auditoria")
+	}
+	if versions := repo.Versions(ana); len(versions) != 0 {
+		t.Fatalf("versão publicada sem auditoria: %v", versions)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("ficheiro da versão não foi retirado: %v", entries)
+	}
+}
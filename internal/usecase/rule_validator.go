@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+	"github.com/diegoholiveira/jsonlogic/v3"
+)
+
+// Operadores JsonLogic cujo resultado é sempre booleano
+var booleanOperators = map[string]bool{
+	"==": true, "===": true, "!=": true, "!==": true,
+	">": true, ">=": true, "<": true, "<=": true,
+	"!": true, "!!": true, "in": true, "all": true, "none": true, "some": true,
+}
+
+// Operadores JsonLogic cujo resultado é sempre numérico
+var numericOperators = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "min": true, "max": true,
+}
+
+// Operadores que iteram sobre uma coleção: a partir do 2º argumento o "var" refere-se ao elemento
+var iteratingOperators = map[string]bool{
+	"map": true, "filter": true, "reduce": true, "all": true, "none": true, "some": true,
+}
+
+var identifierKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type RulePackValidator struct {
+	executor interfaces.RuleExecutor
+}
+
+func NewRulePackValidator(executor interfaces.RuleExecutor) interfaces.RulePackValidator {
+	return &RulePackValidator{executor: executor}
+}
+
+// varScope associa cada raiz de "var" ao tipo que lhe corresponde no contexto de execução.
+// Um scope nil desativa a verificação (ex: dentro de map/filter/reduce).
+type varScope map[string]reflect.Type
+
+var (
+	orderType     = reflect.TypeOf(domain.Order{})
+	orderItemType = reflect.TypeOf(domain.OrderItem{})
+)
+
+// walkState acumula os diagnósticos de uma regra durante a travessia da lógica.
+type walkState struct {
+	rule  domain.RuleConfig
+	diags []domain.Diagnostic
+}
+
+func (w *walkState) report(path, format string, args ...interface{}) {
+	w.diags = append(w.diags, domain.Diagnostic{
+		RuleID:   w.rule.ID,
+		Path:     path,
+		Severity: domain.SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate verifica todas as regras do pack sem as executar: IDs duplicados, fases
+// desconhecidas, operadores inexistentes, caminhos "var" que não existem em Order/OrderItem,
+// output_key que o motor não sabe escrever e guardas que não devolvem booleano.
+func (v *RulePackValidator) Validate(def *domain.RulePackDefinition) []domain.Diagnostic {
+	var diags []domain.Diagnostic
+	seen := make(map[string]int)
+
+	for i, rule := range def.Rules {
+		rulePath := fmt.Sprintf("$.rules[%d]", i)
+		w := &walkState{rule: rule}
+
+		switch first, dup := seen[rule.ID]; {
+		case rule.ID == "":
+			w.report(rulePath+".id", "regra sem id")
+		case dup:
+			w.report(rulePath+".id", "id duplicado (já definido em $.rules[%d])", first)
+		default:
+			seen[rule.ID] = i
+		}
+
+		if !isPipelinePhase(rule.Phase) {
+			w.report(rulePath+".phase", "fase desconhecida %q (esperado: %s)", rule.Phase, strings.Join(pipelinePhases, ", "))
+		}
+
+		if rule.Logic == nil {
+			w.report(rulePath+".logic", "regra sem logic")
+		} else {
+			scope := varScope{"order": orderType}
+			resultType := v.walk(w, rule.Logic, rulePath+".logic", scope, true)
+			if rule.Phase == "guards" && resultType != domain.ArgAny && resultType != domain.ArgBool {
+				w.report(rulePath+".logic", "guarda deve devolver booleano, devolve %s", resultType)
+			}
+		}
+
+		if rule.Phase != "guards" && !isWritableKey(rule.OutputKey) {
+			w.report(rulePath+".output_key", "output_key %q não é escrito pelo motor", rule.OutputKey)
+		}
+
+		diags = append(diags, w.diags...)
+	}
+	return diags
+}
+
+// walk valida recursivamente um nó da lógica e devolve o tipo inferido do seu resultado.
+// customAllowed indica se o nó é avaliado pelo executor (raiz, foreach, argumentos de
+// operadores customizados) ou já dentro do JsonLogic padrão, que não conhece os customizados.
+func (v *RulePackValidator) walk(w *walkState, node interface{}, path string, scope varScope, customAllowed bool) domain.ArgType {
+	switch n := node.(type) {
+	case bool:
+		return domain.ArgBool
+	case float64, int:
+		return domain.ArgNumber
+	case string:
+		return domain.ArgString
+	case []interface{}:
+		for i, item := range n {
+			v.walk(w, item, fmt.Sprintf("%s[%d]", path, i), scope, customAllowed)
+		}
+		return domain.ArgList
+	case map[string]interface{}:
+		if len(n) != 1 {
+			// O JsonLogic trata objetos com várias chaves como dados literais
+			return domain.ArgAny
+		}
+		for op, args := range n {
+			return v.walkOperator(w, op, args, path+pathKey(op), scope, customAllowed)
+		}
+	}
+	return domain.ArgAny
+}
+
+func (v *RulePackValidator) walkOperator(w *walkState, op string, args interface{}, path string, scope varScope, customAllowed bool) domain.ArgType {
+	list, isList := args.([]interface{})
+	if !isList {
+		list = []interface{}{args}
+	}
+	argPath := func(i int) string {
+		if !isList {
+			return path
+		}
+		return fmt.Sprintf("%s[%d]", path, i)
+	}
+
+	if op == "var" {
+		v.checkVar(w, list, path, scope)
+		return domain.ArgAny
+	}
+
+	if op == "foreach" {
+		if !customAllowed {
+			w.report(path, "foreach só é suportado na raiz da regra ou como argumento de operador customizado")
+		}
+		if len(list) != 2 {
+			w.report(path, "foreach espera 2 argumentos, recebeu %d", len(list))
+			return domain.ArgNumber
+		}
+		v.walk(w, list[0], argPath(0), scope, false)
+		itemScope := varScope{"order": orderType, "item": orderItemType}
+		v.walk(w, list[1], argPath(1), itemScope, true)
+		return domain.ArgNumber
+	}
+
+	if spec, ok := v.executor.Operator(op); ok {
+		if !customAllowed {
+			w.report(path, "operador customizado %s não pode ser usado dentro de um operador JsonLogic padrão", op)
+		}
+		if err := spec.CheckArity(len(list)); err != nil {
+			w.report(path, "%s: %v", op, err)
+		}
+		for i, arg := range list {
+			argType := v.walk(w, arg, argPath(i), scope, true)
+			expected := spec.TypeOf(i)
+			if expected != domain.ArgAny && argType != domain.ArgAny && argType != expected {
+				w.report(argPath(i), "%s espera %s no argumento %d, recebeu %s", op, expected, i, argType)
+			}
+		}
+		if spec.Returns == "" {
+			return domain.ArgAny
+		}
+		return spec.Returns
+	}
+
+	if !jsonlogic.ValidateJsonLogic(map[string]interface{}{op: []interface{}{}}) {
+		w.report(path, "operador desconhecido %q", op)
+		return domain.ArgAny
+	}
+
+	argTypes := make([]domain.ArgType, len(list))
+	for i, arg := range list {
+		argScope := scope
+		if iteratingOperators[op] && i > 0 {
+			argScope = nil
+		}
+		argTypes[i] = v.walk(w, arg, argPath(i), argScope, false)
+	}
+
+	switch {
+	case booleanOperators[op]:
+		return domain.ArgBool
+	case numericOperators[op]:
+		return domain.ArgNumber
+	case op == "if" || op == "?:":
+		return branchType(argTypes)
+	case op == "and" || op == "or":
+		return commonType(argTypes)
+	}
+	return domain.ArgAny
+}
+
+// checkVar confirma que o caminho de um "var" existe no tipo associado à sua raiz.
+func (v *RulePackValidator) checkVar(w *walkState, args []interface{}, path string, scope varScope) {
+	if scope == nil || len(args) == 0 {
+		return
+	}
+	varPath, ok := args[0].(string)
+	if !ok || varPath == "" {
+		return
+	}
+
+	parts := strings.Split(varPath, ".")
+	root, ok := scope[parts[0]]
+	if !ok {
+		w.report(path, "var %q: raiz %q não existe neste contexto", varPath, parts[0])
+		return
+	}
+	if !typeHasPath(root, parts[1:]) {
+		w.report(path, "var %q não existe em %s", varPath, root.Name())
+	}
+}
+
+// typeHasPath percorre os campos pelo nome da tag json; mapas aceitam qualquer chave e slices índices numéricos.
+func typeHasPath(t reflect.Type, parts []string) bool {
+	for _, part := range parts {
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByJSONName(t, part)
+			if !ok {
+				return false
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Slice:
+			if _, err := strconv.Atoi(part); err != nil {
+				return false
+			}
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if strings.Split(f.Tag.Get("json"), ",")[0] == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// branchType devolve o tipo comum dos ramos de um if: [cond, then, cond, then, ..., else].
+func branchType(argTypes []domain.ArgType) domain.ArgType {
+	var branches []domain.ArgType
+	for i := 1; i < len(argTypes); i += 2 {
+		branches = append(branches, argTypes[i])
+	}
+	if len(argTypes)%2 == 1 {
+		branches = append(branches, argTypes[len(argTypes)-1])
+	}
+	return commonType(branches)
+}
+
+func commonType(types []domain.ArgType) domain.ArgType {
+	if len(types) == 0 {
+		return domain.ArgAny
+	}
+	for _, t := range types[1:] {
+		if t != types[0] {
+			return domain.ArgAny
+		}
+	}
+	return types[0]
+}
+
+func pathKey(key string) string {
+	if identifierKey.MatchString(key) {
+		return "." + key
+	}
+	return fmt.Sprintf("[%q]", key)
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/data"
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+func newTestValidator() *RulePackValidator {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	return NewRulePackValidator(executor).(*RulePackValidator)
+}
+
+func TestRulePackValidator_Diagnostics(t *testing.T) {
+	raw := `{
+	  "version": "vTest",
+	  "rules": [
+	    {"id": "R_BASE", "phase": "baseline",
+	     "logic": {"*": [{"var": "order.BaseValue"}, {"round": [{"var": "order.discountPercentage"}, 2]}]},
+	     "output_key": "order.baseValue"},
+	    {"id": "R_BASE", "phase": "taxes",
+	     "logic": {"pow": [{"var": "order.baseValue"}, 2]},
+	     "output_key": "order.AppliedTaxes.VAT"},
+	    {"id": "R_GUARD", "phase": "guards",
+	     "logic": {"+": [{"var": "order.baseValue"}, 1]}},
+	    {"id": "R_ITEMS", "phase": "itemAdjust",
+	     "logic": {"foreach": [{"var": "order.items"}, {"*": [{"var": "item.price"}, {"var": "item.qty"}]}]},
+	     "output_key": "order.baseValue"}
+	  ]
+	}`
+	var def domain.RulePackDefinition
+	if err := json.Unmarshal([]byte(raw), &def); err != nil {
+		t.Fatal(err)
+	}
+
+	diags := newTestValidator().Validate(&def)
+
+	expected := map[string]string{
+		"$.rules[0].logic[\"*\"][0].var":            "R_BASE",
+		"$.rules[0].logic[\"*\"][1].round":          "R_BASE",
+		"$.rules[1].id":                             "R_BASE",
+		"$.rules[1].logic.pow":                      "R_BASE",
+		"$.rules[1].output_key":                     "R_BASE",
+		"$.rules[2].logic":                          "R_GUARD",
+		"$.rules[3].phase":                          "R_ITEMS",
+		"$.rules[3].logic.foreach[1][\"*\"][0].var": "R_ITEMS",
+	}
+	got := make(map[string]string)
+	for _, d := range diags {
+		got[d.Path] = d.RuleID
+	}
+	for path, ruleID := range expected {
+		if got[path] != ruleID {
+			t.Errorf("diagnóstico em falta para %s (%s); recebidos: %v", path, ruleID, diags)
+		}
+	}
+	if len(diags) != len(expected) {
+		t.Errorf("esperados %d diagnósticos, recebidos %d: %v", len(expected), len(diags), diags)
+	}
+}
+
+func TestRulePackValidator_ShippedPacks(t *testing.T) {
+	embedded, _ := fs.Sub(data.RulePacks, "rules")
+	loaders := map[string]interfaces.RulePackLoader{
+		"disco":    infrastructure.NewFileRuleLoader(rulesDir, newTestValidator()),
+		"embutido": infrastructure.NewFSRuleLoader(embedded, "embed:rules", newTestValidator()),
+	}
+
+	for name, loader := range loaders {
+		for _, version := range []string{"v1.1", "v1.2", "v1.3"} {
+			if _, err := loader.Load(t.Context(), version); err != nil {
+				t.Errorf("%s %s: %v", name, version, err)
+			}
+		}
+		// A v1.0 fica como foi publicada, com order.BaseValue; a correção é a v1.3
+		if _, err := loader.Load(t.Context(), "v1.0"); !errors.Is(err, domain.ErrInvalidRulePack) {
+			t.Errorf("%s v1.0: esperado ErrInvalidRulePack, recebido %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// DetectRulesDrift compara o hash guardado em cada venda com o do pack que hoje responde
+// pela mesma versão. Vendas antigas sem hash são ignoradas: não há termo de comparação.
+func DetectRulesDrift(ctx context.Context, loader interfaces.RulePackLoader, sales []domain.Order) []domain.RulesDrift {
+	type key struct{ version, hash string }
+	groups := make(map[key][]string)
+	for _, sale := range sales {
+		if sale.RulesHash == "" {
+			continue
+		}
+		version := sale.RulesVersion
+		if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		k := key{version, sale.RulesHash}
+		groups[k] = append(groups[k], sale.ID)
+	}
+
+	var drifts []domain.RulesDrift
+	for k, ids := range groups {
+		var current string
+		if pack, err := loader.Load(ctx, k.version); err == nil {
+			current = pack.ContentHash
+		}
+		if current == k.hash {
+			continue
+		}
+		drifts = append(drifts, domain.RulesDrift{Version: k.version, StoredHash: k.hash, CurrentHash: current, SaleIDs: ids})
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Version != drifts[j].Version {
+			return drifts[i].Version < drifts[j].Version
+		}
+		return drifts[i].StoredHash < drifts[j].StoredHash
+	})
+	return drifts
+}
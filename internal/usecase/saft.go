@@ -0,0 +1,376 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// Consumidor final: as vendas não identificam o cliente
+const (
+	saftFinalConsumerID    = "CF"
+	saftFinalConsumerTaxID = "999999999"
+	saftUnknown            = "Desconhecido"
+	saftCountry            = "AO"
+	saftCurrency           = "AOA"
+	saftDateTime           = "2006-01-02T15:04:05"
+	saftReasonLength       = 50 // Comprimento máximo de Reason no XSD
+)
+
+// Motivo das linhas sem imposto: o XSD exige o texto e o código AGT em conjunto
+const (
+	saftNotSubjectReason = "Transmissão de bens e serviço não sujeita"
+	saftNotSubjectCode   = "M02"
+)
+
+// Diferença máxima aceite entre totais recalculados (arredondamentos de cêntimos)
+const saftTolerance = 0.01
+
+// SAFTService gera o SAF-T (AO) a partir dos documentos gravados no repositório de vendas.
+type SAFTService struct {
+	sales     interfaces.SalesRepository
+	master    interfaces.MasterData
+	validator interfaces.XMLValidator
+	now       func() time.Time
+}
+
+// NewSAFTService cria o exportador. Sem validator (nil) o ficheiro não é verificado contra o
+// XSD: é a opção explícita saft_skip_validation, e as exportações saem com SchemaValidated false.
+func NewSAFTService(sales interfaces.SalesRepository, master interfaces.MasterData, validator interfaces.XMLValidator) interfaces.SAFTExporter {
+	return &SAFTService{sales: sales, master: master, validator: validator, now: time.Now}
+}
+
+// Export valida o ficheiro gerado duas vezes: primeiro as regras que o XSD não cobre
+// (totais, artigos e clientes referenciados), depois o próprio XSD. Se o validador não
+// encontrar o XSD ou o xmllint, a exportação falha com domain.ErrSchemaUnavailable; sem
+// validador (nil) o ficheiro é devolvido com SchemaValidated false.
+func (s *SAFTService) Export(ctx context.Context, from, to time.Time) (domain.SAFTExport, error) {
+	if !from.Before(to) {
+		return domain.SAFTExport{}, fmt.Errorf("%w: o período tem de terminar depois de começar", domain.ErrInvalidSalesQuery)
+	}
+	company, err := s.master.Company(ctx)
+	if err != nil {
+		return domain.SAFTExport{}, err
+	}
+	products, err := s.master.Products(ctx)
+	if err != nil {
+		return domain.SAFTExport{}, err
+	}
+	taxes, err := s.master.Taxes(ctx)
+	if err != nil {
+		return domain.SAFTExport{}, err
+	}
+	all, err := s.sales.All(ctx)
+	if err != nil {
+		return domain.SAFTExport{}, err
+	}
+
+	period := domain.SalesQuery{From: &from, To: &to}
+	var docs []domain.Sale
+	for _, sale := range all {
+		if sale.Document != nil && period.Matches(sale) {
+			docs = append(docs, sale)
+		}
+	}
+	slices.SortFunc(docs, func(a, b domain.Sale) int {
+		return cmp.Or(strings.Compare(a.Document.SeriesKey(), b.Document.SeriesKey()), cmp.Compare(a.Document.Number, b.Document.Number))
+	})
+
+	file, problems := buildAuditFile(company, products, taxes, docs, from, to, s.now())
+	problems = append(problems, validateAuditFile(file)...)
+	if len(problems) > 0 {
+		return domain.SAFTExport{}, fmt.Errorf("%w: %s", domain.ErrInvalidSAFT, strings.Join(problems, "; "))
+	}
+	raw, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return domain.SAFTExport{}, err
+	}
+
+	export := domain.SAFTExport{XML: append([]byte(xml.Header), raw...), Documents: len(docs)}
+	if s.validator == nil {
+		return export, nil
+	}
+	if err := s.validator.Validate(ctx, export.XML); err != nil {
+		return domain.SAFTExport{}, err
+	}
+	export.SchemaValidated = true
+	return export, nil
+}
+
+// buildAuditFile monta o ficheiro e devolve também os problemas dos documentos que o
+// impedem de ser válido, como vendas noutra moeda ou impostos sem registo.
+func buildAuditFile(company domain.Company, products []domain.Product, taxes []domain.Tax, docs []domain.Sale, from, to, now time.Time) (domain.AuditFile, []string) {
+	productIndex := make(map[string]domain.Product, len(products))
+	for _, p := range products {
+		productIndex[p.SKU] = p
+	}
+	taxIndex := make(map[string]domain.Tax, len(taxes))
+	table := &domain.SAFTTaxTable{}
+	for _, tax := range taxes {
+		taxIndex[tax.ID] = tax
+		line := saftTax(tax)
+		table.Entries = append(table.Entries, domain.SAFTTaxTableEntry{
+			TaxType: line.TaxType, TaxCountryRegion: saftCountry, TaxCode: line.TaxCode,
+			Description: tax.Name, TaxPercentage: line.TaxPercentage,
+		})
+	}
+
+	var problems []string
+	sold := make(map[string]bool)
+	invoices := domain.SAFTSalesInvoices{}
+	for _, sale := range docs {
+		invoice, errs := saftInvoice(sale, productIndex, taxIndex)
+		problems = append(problems, errs...)
+		for _, line := range invoice.Lines {
+			sold[line.ProductCode] = true
+		}
+		invoices.Invoices = append(invoices.Invoices, invoice)
+		invoices.NumberOfEntries++
+		// Os documentos anulados constam do ficheiro, mas não dos totais
+		if sale.Void != nil {
+			continue
+		}
+		if sale.Document.Type == domain.DocumentCreditNote {
+			invoices.TotalDebit += invoice.DocumentTotals.NetTotal
+		} else {
+			invoices.TotalCredit += invoice.DocumentTotals.NetTotal
+		}
+	}
+
+	var saftProducts []domain.SAFTProduct
+	for _, sku := range slices.Sorted(maps.Keys(sold)) {
+		p, ok := productIndex[sku]
+		if !ok {
+			continue // Reportado por validateAuditFile
+		}
+		saftProducts = append(saftProducts, domain.SAFTProduct{
+			ProductType: "P", ProductCode: p.SKU, ProductGroup: p.Category,
+			ProductDescription: p.Name, ProductNumberCode: p.SKU,
+		})
+	}
+
+	return domain.AuditFile{
+		Xmlns: domain.SAFTNamespace,
+		Header: domain.SAFTHeader{
+			AuditFileVersion:      domain.SAFTVersion,
+			CompanyID:             company.TaxID,
+			TaxRegistrationNumber: company.TaxID,
+			TaxAccountingBasis:    "F",
+			CompanyName:           company.Name,
+			BusinessName:          company.BusinessName,
+			CompanyAddress: domain.SAFTAddress{
+				AddressDetail: company.Address, City: company.City,
+				PostalCode: company.PostalCode, Province: company.Province, Country: saftCountry,
+			},
+			FiscalYear:               from.Year(),
+			StartDate:                from.Format(time.DateOnly),
+			EndDate:                  to.Add(-time.Nanosecond).Format(time.DateOnly),
+			CurrencyCode:             saftCurrency,
+			DateCreated:              now.Format(time.DateOnly),
+			TaxEntity:                "Global",
+			ProductCompanyTaxID:      company.ProductCompanyTaxID,
+			SoftwareValidationNumber: company.SoftwareValidationNumber,
+			ProductID:                company.ProductID,
+			ProductVersion:           company.ProductVersion,
+		},
+		MasterFiles: domain.SAFTMasterFiles{
+			Customers: []domain.SAFTCustomer{{
+				CustomerID:     saftFinalConsumerID,
+				AccountID:      saftUnknown,
+				CustomerTaxID:  saftFinalConsumerTaxID,
+				CompanyName:    "Consumidor final",
+				BillingAddress: domain.SAFTAddress{AddressDetail: saftUnknown, City: saftUnknown, Country: saftCountry},
+			}},
+			Products: saftProducts,
+			TaxTable: table,
+		},
+		SourceDocuments: &domain.SAFTSourceDocuments{SalesInvoices: invoices},
+	}, problems
+}
+
+// saftInvoice converte uma venda num documento. As linhas recebem o total sem impostos
+// repartido pelo valor bruto de cada item, já com o desconto aplicado pelo motor; o
+// imposto da linha é o de maior valor aplicado à venda.
+func saftInvoice(sale domain.Sale, products map[string]domain.Product, taxes map[string]domain.Tax) (domain.SAFTInvoice, []string) {
+	doc := sale.Document
+	var problems []string
+	if sale.Currency != "" && sale.Currency != saftCurrency {
+		problems = append(problems, fmt.Sprintf("%s em %s: o SAF-T exige valores em %s", doc.No, sale.Currency, saftCurrency))
+	}
+
+	var taxPayable float64
+	var primary string
+	for _, id := range slices.Sorted(maps.Keys(sale.AppliedTaxes)) {
+		taxPayable += sale.AppliedTaxes[id]
+		if sale.AppliedTaxes[id] > 0 && (primary == "" || sale.AppliedTaxes[id] > sale.AppliedTaxes[primary]) {
+			primary = id
+		}
+	}
+	lineTax := domain.SAFTTax{TaxType: "NS", TaxCountryRegion: saftCountry, TaxCode: "NS"}
+	var exemption, exemptionCode string
+	if primary == "" {
+		exemption, exemptionCode = saftNotSubjectReason, saftNotSubjectCode
+	} else if tax, ok := taxes[primary]; ok {
+		lineTax = saftTax(tax)
+	} else {
+		problems = append(problems, fmt.Sprintf("%s: imposto %s ausente de taxs.json", doc.No, primary))
+	}
+	net := round2(sale.TotalValue - taxPayable)
+
+	var weight float64
+	for _, item := range sale.Items {
+		weight += item.Value * float64(item.Qty)
+	}
+	if len(sale.Items) == 0 || weight == 0 {
+		problems = append(problems, fmt.Sprintf("%s sem linhas com valor", doc.No))
+	}
+
+	at := sale.CreatedAt.Format(saftDateTime)
+	invoice := domain.SAFTInvoice{
+		InvoiceNo: doc.No,
+		DocumentStatus: domain.SAFTDocumentStatus{
+			InvoiceStatus: "N", InvoiceStatusDate: at, SourceID: doc.TerminalID, SourceBilling: "P",
+		},
+		Hash:            cmp.Or(doc.Hash, "0"),
+		HashControl:     cmp.Or(doc.HashControl, "0"),
+		Period:          int(sale.CreatedAt.Month()),
+		InvoiceDate:     sale.CreatedAt.Format(time.DateOnly),
+		InvoiceType:     string(doc.Type),
+		SourceID:        doc.TerminalID,
+		SystemEntryDate: at,
+		CustomerID:      saftFinalConsumerID,
+		DocumentTotals: domain.SAFTDocumentTotals{
+			TaxPayable: domain.Money(taxPayable),
+			NetTotal:   domain.Money(net),
+			GrossTotal: domain.Money(sale.TotalValue),
+		},
+	}
+
+	if void := sale.Void; void != nil {
+		invoice.DocumentStatus.InvoiceStatus = "A"
+		invoice.DocumentStatus.InvoiceStatusDate = void.At.Format(saftDateTime)
+		invoice.DocumentStatus.Reason = truncateRunes(void.Reason, saftReasonLength)
+		invoice.DocumentStatus.SourceID = void.UserID
+	}
+
+	remaining := net
+	for i, item := range sale.Items {
+		amount := remaining
+		if i < len(sale.Items)-1 && weight > 0 {
+			amount = round2(net * item.Value * float64(item.Qty) / weight)
+		}
+		remaining = round2(remaining - amount)
+
+		var unitPrice float64
+		if item.Qty > 0 {
+			unitPrice = amount / float64(item.Qty)
+		}
+		money := domain.Money(amount)
+		line := domain.SAFTLine{
+			LineNumber:         i + 1,
+			ProductCode:        item.SKU,
+			ProductDescription: products[item.SKU].Name,
+			Quantity:           domain.Decimal(item.Qty),
+			UnitOfMeasure:      "UN",
+			UnitPrice:          domain.Decimal(unitPrice),
+			TaxPointDate:       sale.CreatedAt.Format(time.DateOnly),
+			Description:        cmp.Or(products[item.SKU].Name, item.SKU),
+			Tax:                lineTax,
+			TaxExemptionReason: exemption,
+			TaxExemptionCode:   exemptionCode,
+		}
+		if doc.Type == domain.DocumentCreditNote {
+			line.DebitAmount = &money
+			line.References = []domain.SAFTReference{{Reference: sale.OriginalDocumentNo, Reason: truncateRunes(sale.ReturnReason, saftReasonLength)}}
+		} else {
+			line.CreditAmount = &money
+		}
+		invoice.Lines = append(invoice.Lines, line)
+	}
+	return invoice, problems
+}
+
+// saftTax traduz um imposto de taxs.json: o IVA (VAT) usa a taxa normal; os restantes
+// impostos ficam como imposto de selo com o próprio ID como código.
+func saftTax(tax domain.Tax) domain.SAFTTax {
+	line := domain.SAFTTax{TaxType: "IS", TaxCountryRegion: saftCountry, TaxCode: tax.ID, TaxPercentage: domain.Decimal(tax.Rate * 100)}
+	if tax.ID == "VAT" {
+		line.TaxType, line.TaxCode = "IVA", "NOR"
+	}
+	return line
+}
+
+// validateAuditFile confirma as regras que o XSD não verifica: dados obrigatórios do
+// emissor, totais coerentes e artigos e clientes presentes nos dados mestre.
+func validateAuditFile(file domain.AuditFile) []string {
+	var problems []string
+	h := file.Header
+	for name, value := range map[string]string{
+		"taxId": h.TaxRegistrationNumber, "name": h.CompanyName, "address": h.CompanyAddress.AddressDetail,
+		"city": h.CompanyAddress.City, "softwareValidationNumber": h.SoftwareValidationNumber,
+		"productCompanyTaxId": h.ProductCompanyTaxID, "productId": h.ProductID, "productVersion": h.ProductVersion,
+	} {
+		if value == "" {
+			problems = append(problems, "company.json sem "+name)
+		}
+	}
+	slices.Sort(problems)
+
+	customers := make(map[string]bool)
+	for _, c := range file.MasterFiles.Customers {
+		customers[c.CustomerID] = true
+	}
+	products := make(map[string]bool)
+	for _, p := range file.MasterFiles.Products {
+		products[p.ProductCode] = true
+	}
+	if file.SourceDocuments == nil {
+		return problems
+	}
+	for _, inv := range file.SourceDocuments.SalesInvoices.Invoices {
+		totals := inv.DocumentTotals
+		if math.Abs(float64(totals.NetTotal+totals.TaxPayable-totals.GrossTotal)) > saftTolerance {
+			problems = append(problems, fmt.Sprintf("%s: NetTotal + TaxPayable difere de GrossTotal", inv.InvoiceNo))
+		}
+		var lines float64
+		for _, line := range inv.Lines {
+			if line.CreditAmount != nil {
+				lines += float64(*line.CreditAmount)
+			}
+			if line.DebitAmount != nil {
+				lines += float64(*line.DebitAmount)
+			}
+			if !products[line.ProductCode] {
+				problems = append(problems, fmt.Sprintf("%s: linha %d com artigo %s ausente de products.json", inv.InvoiceNo, line.LineNumber, line.ProductCode))
+			}
+		}
+		if math.Abs(lines-float64(totals.NetTotal)) > saftTolerance {
+			problems = append(problems, fmt.Sprintf("%s: a soma das linhas difere de NetTotal", inv.InvoiceNo))
+		}
+		if !customers[inv.CustomerID] {
+			problems = append(problems, fmt.Sprintf("%s: cliente %s sem registo", inv.InvoiceNo, inv.CustomerID))
+		}
+	}
+	return problems
+}
+
+// truncateRunes corta s a n caracteres, para os campos com comprimento máximo no XSD.
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/data"
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+)
+
+func TestSAFTService_Export(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "company.json"), []byte(`{"taxId": "5000000000", "name": "Empresa", "address": "Rua 1", "city": "Luanda",
+		"softwareValidationNumber": "1/AGT/2026", "productCompanyTaxId": "5000000000", "productId": "service-commercial/Empresa", "productVersion": "1.0"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "products.json"), []byte(`[{"sku": "A", "name": "Arroz", "value": 100, "category": "Alimento"}, {"sku": "B", "name": "Óleo", "value": 50}]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "taxs.json"), []byte(`[{"id": "VAT", "name": "IVA", "rate": 0.14}]`), 0o644)
+
+	repo, err := infrastructure.NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	at := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	doc := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A", TerminalID: "1"}
+	sale := domain.Sale{
+		Order: domain.Order{
+			ID: "SALE-1", Currency: "AOA", TotalValue: 285,
+			Items:        []domain.OrderItem{{SKU: "A", Value: 100, Qty: 2}, {SKU: "B", Value: 50, Qty: 1}},
+			AppliedTaxes: map[string]float64{"VAT": 35},
+		},
+		CreatedAt: at, Document: &doc,
+	}
+	if _, err := repo.Issue(ctx, sale, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Fora do período: não é exportada
+	sale.ID, sale.CreatedAt = "SALE-2", at.AddDate(0, 1, 0)
+	repo.Issue(ctx, sale, nil)
+
+	from, to := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	// Sem o XSD a exportação é recusada; só a opção explícita a dispensa
+	missing := NewSAFTService(repo, infrastructure.NewFileMasterData(dir), infrastructure.NewXMLLintValidator(filepath.Join(dir, "inexistente.xsd")))
+	if _, err := missing.Export(ctx, from, to); !errors.Is(err, domain.ErrSchemaUnavailable) {
+		t.Fatalf("esperado ErrSchemaUnavailable, recebido %v", err)
+	}
+	svc := NewSAFTService(repo, infrastructure.NewFileMasterData(dir), nil)
+	export, err := svc.Export(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xml := string(export.XML)
+	for _, want := range []string{
+		`<AuditFile xmlns="urn:OECD:StandardAuditFile-Tax:AO_1.01_01">`,
+		"<EndDate>2026-01-31</EndDate>",
+		"<InvoiceNo>FT A/1</InvoiceNo>",
+		"<CreditAmount>200.00</CreditAmount>", // 250 sem impostos, repartidos 200/50
+		"<TaxPercentage>14</TaxPercentage>",
+		"<NetTotal>250.00</NetTotal>",
+		"<TotalCredit>250.00</TotalCredit>",
+		"<ProductDescription>Óleo</ProductDescription>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("SAF-T sem %s", want)
+		}
+	}
+	if export.Documents != 1 || export.SchemaValidated {
+		t.Fatalf("esperado 1 documento, não validado pelo XSD: %d, %v", export.Documents, export.SchemaValidated)
+	}
+
+	// Um documento anulado continua no ficheiro com estado A, mas fora dos totais
+	if _, err := repo.Void(ctx, "SALE-1", domain.SaleVoid{Reason: "Engano", UserID: "u1", At: at.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if export, err = svc.Export(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	xml = string(export.XML)
+	for _, want := range []string{
+		"<InvoiceStatus>A</InvoiceStatus>",
+		"<InvoiceStatusDate>2026-01-15T11:00:00</InvoiceStatusDate>",
+		"<Reason>Engano</Reason>",
+		"<NumberOfEntries>1</NumberOfEntries>",
+		"<TotalCredit>0.00</TotalCredit>",
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("SAF-T sem %s", want)
+		}
+	}
+
+	// Um artigo vendido que não consta de products.json torna o ficheiro inválido
+	os.WriteFile(filepath.Join(dir, "products.json"), []byte(`[{"sku": "A", "name": "Arroz"}]`), 0o644)
+	if _, err := svc.Export(ctx, from, to); !errors.Is(err, domain.ErrInvalidSAFT) || !strings.Contains(err.Error(), "artigo B") {
+		t.Fatalf("esperado ErrInvalidSAFT pelo artigo B, recebido %v", err)
+	}
+}
+
+func TestSAFTService_ExportSchema(t *testing.T) {
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		t.Skip("xmllint indisponível")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "company.json"), []byte(`{"taxId": "5000000000", "name": "Empresa", "address": "Rua 1", "city": "Luanda",
+		"softwareValidationNumber": "1/AGT/2026", "productCompanyTaxId": "5000000000", "productId": "x/Empresa", "productVersion": "1.0"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "products.json"), []byte(`[]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "taxs.json"), []byte(`[]`), 0o644)
+	// Esquema mínimo que só aceita um AuditFile sem SourceDocuments
+	xsd := filepath.Join(dir, "saft.xsd")
+	os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:OECD:StandardAuditFile-Tax:AO_1.01_01" elementFormDefault="qualified">
+  <xs:element name="AuditFile"><xs:complexType><xs:sequence>
+    <xs:element name="Header"><xs:complexType><xs:sequence><xs:any processContents="skip" maxOccurs="unbounded"/></xs:sequence></xs:complexType></xs:element>
+    <xs:element name="MasterFiles"><xs:complexType><xs:sequence><xs:any processContents="skip" minOccurs="0" maxOccurs="unbounded"/></xs:sequence></xs:complexType></xs:element>
+  </xs:sequence></xs:complexType></xs:element>
+</xs:schema>`), 0o644)
+
+	repo, err := infrastructure.NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	svc := NewSAFTService(repo, infrastructure.NewFileMasterData(dir), infrastructure.NewXMLLintValidator(xsd))
+	_, err = svc.Export(context.Background(), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, domain.ErrInvalidSAFT) || !strings.Contains(err.Error(), "SourceDocuments") {
+		t.Fatalf("esperada a recusa de SourceDocuments pelo XSD, recebido %v", err)
+	}
+}
+
+func TestSAFTService_ExportAGTSchema(t *testing.T) {
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		t.Skip("xmllint indisponível")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "company.json"), []byte(`{"taxId": "5000000000", "name": "Empresa", "address": "Rua 1", "city": "Luanda", "province": "Luanda",
+		"softwareValidationNumber": "1/AGT/2026", "productCompanyTaxId": "5000000000", "productId": "service-commercial/Empresa", "productVersion": "1.0"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "products.json"), []byte(`[{"sku": "A", "name": "Arroz", "value": 100, "category": "Alimento"}, {"sku": "B", "name": "Óleo", "value": 50}]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "taxs.json"), []byte(`[{"id": "VAT", "name": "IVA", "rate": 0.14}]`), 0o644)
+
+	repo, err := infrastructure.NewFileSalesRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	at := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	issue := func(sale domain.Sale) {
+		t.Helper()
+		if _, err := repo.Issue(ctx, sale, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Fatura com IVA, fatura-recibo sem imposto (motivo de não sujeição), nota de crédito
+	// sobre a primeira e uma fatura anulada
+	issue(domain.Sale{
+		Order: domain.Order{
+			ID: "SALE-1", Currency: "AOA", TotalValue: 285,
+			Items:        []domain.OrderItem{{SKU: "A", Value: 100, Qty: 2}, {SKU: "B", Value: 50, Qty: 1}},
+			AppliedTaxes: map[string]float64{"VAT": 35},
+		},
+		CreatedAt: at, Document: &domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A", TerminalID: "1"},
+	})
+	issue(domain.Sale{
+		Order:     domain.Order{ID: "SALE-2", Currency: "AOA", TotalValue: 50, Items: []domain.OrderItem{{SKU: "B", Value: 50, Qty: 1}}},
+		CreatedAt: at.Add(time.Minute), Document: &domain.FiscalDocument{Type: domain.DocumentInvoiceReceipt, Series: "A", TerminalID: "1"},
+	})
+	issue(domain.Sale{
+		Order: domain.Order{
+			ID: "RET-1", Currency: "AOA", TotalValue: 114,
+			Items:        []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}},
+			AppliedTaxes: map[string]float64{"VAT": 14},
+		},
+		CreatedAt: at.Add(time.Hour), OriginalSaleID: "SALE-1", OriginalDocumentNo: "FT A/1", ReturnReason: "Produto danificado",
+		Document: &domain.FiscalDocument{Type: domain.DocumentCreditNote, Series: "A", TerminalID: "1"},
+	})
+	issue(domain.Sale{
+		Order:     domain.Order{ID: "SALE-3", Currency: "AOA", TotalValue: 100, Items: []domain.OrderItem{{SKU: "A", Value: 100, Qty: 1}}},
+		CreatedAt: at.Add(2 * time.Hour), Document: &domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A", TerminalID: "1"},
+	})
+	if _, err := repo.Void(ctx, "SALE-3", domain.SaleVoid{Reason: "Engano", UserID: "u1", At: at.Add(3 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// O XSD embutido em data/saft, o mesmo que o servidor usa sem saft_xsd
+	svc := NewSAFTService(repo, infrastructure.NewFileMasterData(dir), infrastructure.NewXMLLintValidatorFS(data.SAFTSchema, "saft/SAFTAO1.01_01.xsd"))
+	export, err := svc.Export(ctx, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Documents != 4 || !export.SchemaValidated {
+		t.Fatalf("esperados 4 documentos validados pelo XSD: %d, %v", export.Documents, export.SchemaValidated)
+	}
+	xml := string(export.XML)
+	for _, want := range []string{"<InvoiceType>NC</InvoiceType>", "<Reference>FT A/1</Reference>", "<TaxExemptionCode>M02</TaxExemptionCode>"} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("SAF-T sem %s", want)
+		}
+	}
+}
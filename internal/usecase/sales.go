@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// NewSale constrói a venda a partir do resultado do motor: o pedido gravado é o
+// StateFragment, com a versão e o hash do pack efetivamente usados e um ID novo.
+func NewSale(result *domain.EngineResult, tenantID string, at time.Time) (domain.Sale, error) {
+	raw, err := json.Marshal(result.StateFragment)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	var order domain.Order
+	if err := json.Unmarshal(raw, &order); err != nil {
+		return domain.Sale{}, fmt.Errorf("estado calculado inválido: %w", err)
+	}
+	order.RulesVersion = result.RulesVersion
+	order.RulesHash = result.RulesHash
+
+	order.ID = newSaleID(at)
+
+	return domain.Sale{
+		Order:              order,
+		TenantID:           tenantID,
+		CreatedAt:          at,
+		Revision:           result.Revision,
+		ExecutionLogDigest: ExecutionLogDigest(result.ExecutionLog),
+		Guards:             result.Guards,
+		Warnings:           result.Warnings,
+	}, nil
+}
+
+// newSaleID mantém o prefixo com data e hora das vendas antigas e acrescenta um sufixo
+// aleatório, para que duas vendas no mesmo segundo não colidam.
+func newSaleID(at time.Time) string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return "SALE-" + at.Format("20060102150405") + "-" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// ExecutionLogDigest resume o log de execução em "sha256:<hex>", no formato dos hashes de pack.
+func ExecutionLogDigest(log []domain.ExecutionStep) string {
+	raw, _ := json.Marshal(log)
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// CheckSeries confirma que cada série de documentos vai de 1 ao último número emitido,
+// sem falhas nem números repetidos. Devolve as séries com problemas, por ordem de nome.
+func CheckSeries(sales []domain.Sale) []domain.SeriesGap {
+	numbers := make(map[string]map[int64]int)
+	for _, sale := range sales {
+		if sale.Document == nil {
+			continue
+		}
+		key := sale.Document.SeriesKey()
+		if numbers[key] == nil {
+			numbers[key] = make(map[int64]int)
+		}
+		numbers[key][sale.Document.Number]++
+	}
+
+	var gaps []domain.SeriesGap
+	for _, key := range slices.Sorted(maps.Keys(numbers)) {
+		seen := numbers[key]
+		issued := slices.Sorted(maps.Keys(seen))
+		gap := domain.SeriesGap{Series: key}
+		for _, n := range issued {
+			if seen[n] > 1 {
+				gap.Repeated = append(gap.Repeated, n)
+			}
+		}
+		for n := int64(1); n <= issued[len(issued)-1]; n++ {
+			if seen[n] == 0 {
+				gap.Missing = append(gap.Missing, n)
+			}
+		}
+		if len(gap.Missing) > 0 || len(gap.Repeated) > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps
+}
+
+// VerifySignatureChain percorre cada série por ordem de número e confirma que cada
+// documento foi assinado sobre os seus dados e o hash do anterior. Um documento sem
+// assinatura depois de um assinado também quebra a cadeia.
+func VerifySignatureChain(sales []domain.Sale, verifier interfaces.DocumentVerifier) []domain.ChainBreak {
+	bySeries := make(map[string][]domain.Sale)
+	for _, sale := range sales {
+		if sale.Document != nil {
+			bySeries[sale.Document.SeriesKey()] = append(bySeries[sale.Document.SeriesKey()], sale)
+		}
+	}
+
+	var breaks []domain.ChainBreak
+	for _, key := range slices.Sorted(maps.Keys(bySeries)) {
+		docs := bySeries[key]
+		slices.SortStableFunc(docs, func(a, b domain.Sale) int { return cmp.Compare(a.Document.Number, b.Document.Number) })
+		previous := ""
+		for _, sale := range docs {
+			var reason string
+			switch doc := sale.Document; {
+			case doc.Hash == "" && previous != "":
+				reason = "documento sem assinatura depois de um documento assinado"
+			case doc.Hash == "":
+			default:
+				if err := verifier.Verify(domain.SignatureMessage(sale, previous), doc.Hash); err != nil {
+					reason = "a assinatura não confere com o documento e o hash anterior"
+				}
+			}
+			if reason != "" {
+				breaks = append(breaks, domain.ChainBreak{SaleID: sale.ID, DocumentNo: sale.Document.No, Reason: reason})
+			}
+			previous = sale.Document.Hash
+		}
+	}
+	return breaks
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+)
+
+func TestNewSale_PersistsComputedOrder(t *testing.T) {
+	executor := infrastructure.NewJsonLogicExecutor()
+	executor.RegisterCustomOperator(infrastructure.AllocateOperator)
+	executor.RegisterCustomOperator(infrastructure.RoundOperator)
+	engine := NewEngineService(infrastructure.NewFileRuleLoader(rulesDir, nil), executor)
+
+	// Valores do cliente desatualizados: a venda tem de ficar com os do motor
+	order := domain.Order{
+		Currency:     "BRL",
+		BaseValue:    6.71,
+		TotalValue:   1,
+		Items:        []domain.OrderItem{{SKU: "PROD-001", Value: 1200, Qty: 1}},
+		RulesVersion: "1.2",
+	}
+	result, err := engine.RunEngine(context.Background(), order, order.RulesVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale, err := NewSale(result, "T1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sale.BaseValue != result.StateFragment["baseValue"] || sale.TotalValue != result.StateFragment["totalValue"] || sale.BaseValue == 6.71 {
+		t.Fatalf("a venda deveria ter os valores calculados: %+v", sale.Order)
+	}
+	if sale.RulesVersion != "v1.2" || sale.RulesHash == "" || sale.Revision != result.Revision || sale.TenantID != "T1" {
+		t.Fatalf("metadados do motor em falta: %+v", sale)
+	}
+	if sale.ExecutionLogDigest != ExecutionLogDigest(result.ExecutionLog) {
+		t.Fatal("o digest do log deveria ser determinístico")
+	}
+
+	// v1.1 tem duas guardas: ficam registadas as que passaram e as que bloquearam
+	small := order
+	small.Items = []domain.OrderItem{{SKU: "PROD-001", Value: 10, Qty: 1}}
+	guarded, err := engine.RunEngine(context.Background(), small, "v1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sale, _ = NewSale(guarded, "T1", time.Now())
+	if len(sale.Guards) != 2 || len(guarded.GuardsHit) != 1 || sale.Guards[1].Passed || sale.Guards[1].RuleID != guarded.GuardsHit[0].RuleID {
+		t.Fatalf("as guardas avaliadas deveriam ficar registadas: %+v", sale.Guards)
+	}
+}
+
+func TestCheckSeries(t *testing.T) {
+	doc := func(n int64) *domain.FiscalDocument {
+		d := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}.Numbered(n)
+		return &d
+	}
+	sales := []domain.Sale{{Document: doc(1)}, {Document: doc(2)}, {Document: doc(4)}, {Document: doc(4)}, {}}
+	gaps := CheckSeries(sales)
+	if len(gaps) != 1 || fmt.Sprint(gaps[0].Missing) != "[3]" || fmt.Sprint(gaps[0].Repeated) != "[4]" {
+		t.Fatalf("esperada a falta do 3 e o 4 repetido, recebido %+v", gaps)
+	}
+	if gaps := CheckSeries(sales[:2]); len(gaps) != 0 {
+		t.Fatalf("série sequencial reportada com falhas: %+v", gaps)
+	}
+}
+
+func TestVerifySignatureChain(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600)
+	signer, err := infrastructure.LoadDocumentSigner(keyPath, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := infrastructure.LoadDocumentVerifier(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := infrastructure.NewFileSalesRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	doc := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	var sales []domain.Sale
+	for i := 0; i < 3; i++ {
+		sale, err := repo.Issue(context.Background(), domain.Sale{Order: domain.Order{ID: fmt.Sprint("SALE-", i), TotalValue: 100}, CreatedAt: at, Document: &doc}, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sales = append(sales, sale)
+	}
+	if sales[1].Document.HashControl != "1" || sales[1].Document.Hash == sales[0].Document.Hash {
+		t.Fatalf("documento mal assinado: %+v", sales[1].Document)
+	}
+	if breaks := VerifySignatureChain(sales, verifier); len(breaks) != 0 {
+		t.Fatalf("cadeia íntegra reportada com quebras: %+v", breaks)
+	}
+
+	// Alterar o total de um documento já assinado quebra a sua assinatura
+	sales[1].TotalValue = 90
+	breaks := VerifySignatureChain(sales, verifier)
+	if len(breaks) != 1 || breaks[0].DocumentNo != "FT A/2" {
+		t.Fatalf("esperada a quebra em FT A/2, recebido %+v", breaks)
+	}
+}
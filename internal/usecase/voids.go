@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/interfaces"
+)
+
+// VoidService anula vendas feitas por engano. A venda não é apagada nem renumerada: fica
+// gravada com o motivo, o utilizador e a hora da anulação, e o número continua ocupado.
+type VoidService struct {
+	sales       interfaces.SalesRepository
+	authz       interfaces.Authorizer
+	corrections sync.Locker
+	now         func() time.Time
+}
+
+// NewVoidService cria o serviço de anulações; corrections é o lock partilhado com o
+// ReturnService (ver NewReturnService).
+func NewVoidService(sales interfaces.SalesRepository, authz interfaces.Authorizer, corrections sync.Locker) interfaces.SaleVoids {
+	return &VoidService{sales: sales, authz: authz, corrections: corrections, now: time.Now}
+}
+
+func (s *VoidService) Void(ctx context.Context, saleID, reason string) (domain.Sale, error) {
+	if _, err := authorize(ctx, s.authz, domain.PermissionVoidSale); err != nil {
+		return domain.Sale{}, err
+	}
+	caller := CallerFrom(ctx)
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return domain.Sale{}, fmt.Errorf("%w: o motivo da anulação é obrigatório", domain.ErrInvalidVoid)
+	}
+	s.corrections.Lock()
+	defer s.corrections.Unlock()
+
+	// Uma venda com devoluções só pode ser anulada depois das respetivas notas de crédito
+	notes, err := creditNotes(ctx, s.sales, saleID)
+	if err != nil {
+		return domain.Sale{}, err
+	}
+	if len(notes) > 0 {
+		numbers := make([]string, 0, len(notes))
+		for _, note := range notes {
+			numbers = append(numbers, documentNo(note))
+		}
+		return domain.Sale{}, fmt.Errorf("%w: anule primeiro as notas de crédito %s", domain.ErrInvalidVoid, strings.Join(numbers, ", "))
+	}
+	return s.sales.Void(ctx, saleID, domain.SaleVoid{Reason: reason, UserID: caller.UserID, At: s.now()})
+}
+
+// documentNo identifica a venda pelo número do documento ou, sem documento, pelo ID.
+func documentNo(sale domain.Sale) string {
+	if sale.Document != nil {
+		return sale.Document.No
+	}
+	return sale.ID
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Victor-armando18/service-commercial/internal/domain"
+	"github.com/Victor-armando18/service-commercial/internal/infrastructure"
+)
+
+func TestVoidService_Void(t *testing.T) {
+	repo, err := infrastructure.NewFileSalesRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	ft := domain.FiscalDocument{Type: domain.DocumentInvoice, Series: "A"}
+	nc := domain.FiscalDocument{Type: domain.DocumentCreditNote, Series: "A"}
+	repo.Issue(ctx, domain.Sale{Order: domain.Order{ID: "SALE-1", TotalValue: 100}, Document: &ft}, nil)
+	repo.Issue(ctx, domain.Sale{Order: domain.Order{ID: "SALE-2", TotalValue: 40}, Document: &nc, OriginalSaleID: "SALE-1"}, nil)
+	corrections := &sync.Mutex{}
+	voids := NewVoidService(repo, testAuthorizer(t), corrections)
+	manager := WithCaller(ctx, domain.Caller{UserID: "gerente", Permissions: []domain.Permission{domain.PermissionVoidSale}})
+
+	if _, err := voids.Void(ctx, "SALE-1", "Engano"); !errors.Is(err, domain.ErrUnauthenticated) {
+		t.Fatalf("um pedido anónimo não deveria anular, recebido %v", err)
+	}
+	if _, err := voids.Void(WithCaller(ctx, domain.Caller{UserID: "caixa"}), "SALE-1", "Engano"); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("um utilizador sem sales.void não deveria anular, recebido %v", err)
+	}
+	if _, err := voids.Void(manager, "SALE-1", " "); !errors.Is(err, domain.ErrInvalidVoid) {
+		t.Fatalf("sem motivo deveria dar ErrInvalidVoid, recebido %v", err)
+	}
+	if _, err := voids.Void(manager, "SALE-1", "Engano"); !errors.Is(err, domain.ErrInvalidVoid) {
+		t.Fatalf("uma venda com nota de crédito ativa não deveria ser anulada, recebido %v", err)
+	}
+
+	// Anulada a nota de crédito, a venda já pode ser anulada
+	if _, err := voids.Void(manager, "SALE-2", "Devolução registada por engano"); err != nil {
+		t.Fatal(err)
+	}
+	sale, err := voids.Void(manager, "SALE-1", "Engano")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sale.Void == nil || sale.Void.UserID != "gerente" || sale.Void.Reason != "Engano" || sale.Void.At.IsZero() {
+		t.Fatalf("anulação mal registada: %+v", sale.Void)
+	}
+	if _, err := voids.Void(manager, "SALE-1", "Engano"); !errors.Is(err, domain.ErrSaleVoided) {
+		t.Fatalf("esperado ErrSaleVoided, recebido %v", err)
+	}
+
+	returns := NewReturnService(nil, repo, domain.SeriesCatalog{}, nil, corrections)
+	if _, err := returns.Return(ctx, "SALE-1", domain.ReturnRequest{Reason: "x"}); !errors.Is(err, domain.ErrSaleVoided) {
+		t.Fatalf("uma venda anulada não deveria ser devolvida, recebido %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+)
+
+// PackDiff é a comparação semântica entre duas versões de um RulePack, regra a regra (por ID).
+type PackDiff struct {
+	From          string        `json:"from"`
+	To            string        `json:"to"`
+	Added         []RuleConfig  `json:"added"`
+	Removed       []RuleConfig  `json:"removed"`
+	Moved         []PhaseMove   `json:"moved"`
+	LogicChanges  []LogicChange `json:"logicChanges"`
+	OutputChanges []ValueChange `json:"outputChanges"`
+	GuardMessages []ValueChange `json:"guardMessages"`
+}
+
+// PhaseMove é uma regra que passou a executar noutra fase.
+type PhaseMove struct {
+	RuleID string `json:"ruleId"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// LogicChange agrupa as diferenças estruturais da lógica JsonLogic de uma regra.
+type LogicChange struct {
+	RuleID string      `json:"ruleId"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+	Edits  []LogicEdit `json:"edits"`
+}
+
+// Tipos de alteração numa árvore JsonLogic.
+const (
+	EditAdded   = "added"
+	EditRemoved = "removed"
+	EditChanged = "changed"
+)
+
+// LogicEdit é uma diferença num nó da árvore, identificado pelo caminho (ex: `["*"][1].var`).
+type LogicEdit struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ValueChange é a alteração de um campo textual de uma regra (output_key, error_message).
+type ValueChange struct {
+	RuleID string `json:"ruleId"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Empty indica que as duas versões têm exatamente as mesmas regras.
+func (d PackDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Moved)+len(d.LogicChanges)+len(d.OutputChanges)+len(d.GuardMessages) == 0
+}
+
+// DiffPacks compara from e to. A lógica é comparada depois da compilação de expr,
+// por isso reescrever uma regra em expr sem alterar o significado não conta como alteração.
+func DiffPacks(from, to *RulePack) PackDiff {
+	// Listas vazias e não nulas, para que o JSON da API tenha sempre [] e não null
+	diff := PackDiff{
+		From: from.Version, To: to.Version,
+		Added: []RuleConfig{}, Removed: []RuleConfig{}, Moved: []PhaseMove{},
+		LogicChanges: []LogicChange{}, OutputChanges: []ValueChange{}, GuardMessages: []ValueChange{},
+	}
+
+	before := make(map[string]RuleConfig, len(from.Rules))
+	for _, rule := range from.Rules {
+		before[rule.ID] = rule
+	}
+	present := make(map[string]bool, len(to.Rules))
+	for _, rule := range to.Rules {
+		present[rule.ID] = true
+		old, ok := before[rule.ID]
+		if !ok {
+			diff.Added = append(diff.Added, rule)
+			continue
+		}
+		if old.Phase != rule.Phase {
+			diff.Moved = append(diff.Moved, PhaseMove{RuleID: rule.ID, From: old.Phase, To: rule.Phase})
+		}
+		if edits := DiffLogic(old.Logic, rule.Logic); len(edits) > 0 {
+			diff.LogicChanges = append(diff.LogicChanges, LogicChange{RuleID: rule.ID, Before: old.Logic, After: rule.Logic, Edits: edits})
+		}
+		if old.OutputKey != rule.OutputKey && rule.Phase != "guards" {
+			diff.OutputChanges = append(diff.OutputChanges, ValueChange{RuleID: rule.ID, From: old.OutputKey, To: rule.OutputKey})
+		}
+		if old.ErrorMessage != rule.ErrorMessage && (old.Phase == "guards" || rule.Phase == "guards") {
+			diff.GuardMessages = append(diff.GuardMessages, ValueChange{RuleID: rule.ID, From: old.ErrorMessage, To: rule.ErrorMessage})
+		}
+	}
+	for _, rule := range from.Rules {
+		if !present[rule.ID] {
+			diff.Removed = append(diff.Removed, rule)
+		}
+	}
+	return diff
+}
+
+// DiffLogic devolve as diferenças estruturais entre duas árvores JsonLogic. Quando um
+// nó muda de forma (ex: um número passa a ser uma chamada a round), a diferença é
+// reportada nesse nó e não descemos nos filhos.
+func DiffLogic(a, b interface{}) []LogicEdit {
+	var edits []LogicEdit
+	diffNode("", a, b, &edits)
+	return edits
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func childPath(parent, key string) string {
+	if identifier.MatchString(key) {
+		if parent == "" {
+			return key
+		}
+		return parent + "." + key
+	}
+	return fmt.Sprintf("%s[%q]", parent, key)
+}
+
+func diffNode(path string, a, b interface{}, edits *[]LogicEdit) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || !sameOperator(av, bv) {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			oldChild, inA := av[k]
+			newChild, inB := bv[k]
+			switch {
+			case !inA:
+				*edits = append(*edits, LogicEdit{Path: childPath(path, k), Kind: EditAdded, New: newChild})
+			case !inB:
+				*edits = append(*edits, LogicEdit{Path: childPath(path, k), Kind: EditRemoved, Old: oldChild})
+			default:
+				diffNode(childPath(path, k), oldChild, newChild, edits)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(av):
+				*edits = append(*edits, LogicEdit{Path: p, Kind: EditAdded, New: bv[i]})
+			case i >= len(bv):
+				*edits = append(*edits, LogicEdit{Path: p, Kind: EditRemoved, Old: av[i]})
+			default:
+				diffNode(p, av[i], bv[i], edits)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*edits = append(*edits, LogicEdit{Path: path, Kind: EditChanged, Old: a, New: b})
+	}
+}
+
+// sameOperator indica se dois nós JsonLogic chamam o mesmo operador (a mesma chave
+// única); só nesse caso faz sentido comparar os argumentos posição a posição.
+func sameOperator(a, b map[string]interface{}) bool {
+	if len(a) != 1 || len(b) != 1 {
+		return true
+	}
+	for k := range a {
+		_, ok := b[k]
+		return ok
+	}
+	return true
+}
+
+// OrderImpact compara o resultado de um pedido de exemplo nas duas versões.
+type OrderImpact struct {
+	OrderID      string   `json:"orderId"`
+	Currency     string   `json:"currency"`
+	Before       float64  `json:"before"`
+	After        float64  `json:"after"`
+	Delta        float64  `json:"delta"`
+	GuardsBefore []string `json:"guardsBefore,omitempty"`
+	GuardsAfter  []string `json:"guardsAfter,omitempty"`
+	Err          string   `json:"error,omitempty"`
+}
+
+// CurrencyImpact soma o impacto dos pedidos de exemplo de uma moeda.
+type CurrencyImpact struct {
+	Currency string  `json:"currency"`
+	Orders   int     `json:"orders"`
+	Changed  int     `json:"changed"`
+	Before   float64 `json:"before"`
+	After    float64 `json:"after"`
+	Delta    float64 `json:"delta"`
+}
+
+// Changed indica se o total ou as guardas disparadas diferem entre as versões.
+func (i OrderImpact) Changed() bool {
+	return math.Abs(i.Delta) > floatTolerance || !reflect.DeepEqual(i.GuardsBefore, i.GuardsAfter)
+}
+
+// CompareOrders executa cada pedido nas duas versões e devolve o impacto por pedido e
+// o resumo por moeda (as moedas não se somam entre si).
+func CompareOrders(ctx context.Context, svc *EngineService, from, to string, orders []Order) ([]OrderImpact, []CurrencyImpact) {
+	impacts := make([]OrderImpact, 0, len(orders))
+	byCurrency := make(map[string]*CurrencyImpact)
+
+	for _, order := range orders {
+		impact := OrderImpact{OrderID: order.ID, Currency: order.Currency}
+		before, err := svc.RunEngine(ctx, order, from)
+		if err == nil {
+			var after *EngineResult
+			if after, err = svc.RunEngine(ctx, order, to); err == nil {
+				impact.Before, _ = before.StateFragment["totalValue"].(float64)
+				impact.After, _ = after.StateFragment["totalValue"].(float64)
+				impact.Delta = impact.After - impact.Before
+				impact.GuardsBefore = guardIDs(before.GuardsHit)
+				impact.GuardsAfter = guardIDs(after.GuardsHit)
+			}
+		}
+		if err != nil {
+			impact.Err = err.Error()
+			impacts = append(impacts, impact)
+			continue
+		}
+		impacts = append(impacts, impact)
+
+		sum, ok := byCurrency[order.Currency]
+		if !ok {
+			sum = &CurrencyImpact{Currency: order.Currency}
+			byCurrency[order.Currency] = sum
+		}
+		sum.Orders++
+		sum.Before += impact.Before
+		sum.After += impact.After
+		sum.Delta += impact.Delta
+		if impact.Changed() {
+			sum.Changed++
+		}
+	}
+
+	summary := make([]CurrencyImpact, 0, len(byCurrency))
+	for _, sum := range byCurrency {
+		summary = append(summary, *sum)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Currency < summary[j].Currency })
+	return impacts, summary
+}
+
+func guardIDs(hits []GuardViolation) []string {
+	if len(hits) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(hits))
+	for _, g := range hits {
+		ids = append(ids, g.RuleID)
+	}
+	sort.Strings(ids)
+	return ids
+}
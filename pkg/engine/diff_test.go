@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffPacks_ShippedVersions(t *testing.T) {
+	loader := dirLoader{dir: filepath.Join("..", "..", "data", "rules")}
+	from, _ := loader.Load(context.Background(), "v1.1")
+	to, _ := loader.Load(context.Background(), "v1.2")
+
+	diff := DiffPacks(from, to)
+	if len(diff.Added) != 2 || len(diff.Removed) != 3 {
+		t.Fatalf("adicionadas/removidas inesperadas: %+v %+v", diff.Added, diff.Removed)
+	}
+	if len(diff.Moved) != 1 || diff.Moved[0] != (PhaseMove{RuleID: "R_APPLY_DISCOUNT", From: "baseline", To: "orderAdjust"}) {
+		t.Fatalf("movidas inesperadas: %+v", diff.Moved)
+	}
+	if len(diff.LogicChanges) != 1 || diff.LogicChanges[0].RuleID != "R_APPLY_DISCOUNT" {
+		t.Fatalf("alterações de lógica inesperadas: %+v", diff.LogicChanges)
+	}
+	if d := DiffPacks(to, to); !d.Empty() {
+		t.Fatalf("um pack comparado consigo mesmo não tem diferenças: %+v", d)
+	}
+}
+
+func TestDiffLogic_StructuralEdits(t *testing.T) {
+	before := map[string]interface{}{"if": []interface{}{
+		map[string]interface{}{"==": []interface{}{map[string]interface{}{"var": "order.currency"}, "AOA"}},
+		map[string]interface{}{"*": []interface{}{map[string]interface{}{"var": "order.baseValue"}, 0.14}},
+		0.0,
+	}}
+	after := map[string]interface{}{"if": []interface{}{
+		map[string]interface{}{"==": []interface{}{map[string]interface{}{"var": "order.currency"}, "AOA"}},
+		map[string]interface{}{"*": []interface{}{map[string]interface{}{"var": "order.baseValue"}, 0.16}},
+		map[string]interface{}{"round": []interface{}{1.0, 2.0}},
+		"extra",
+	}}
+
+	edits := DiffLogic(before, after)
+	want := []LogicEdit{
+		{Path: `if[1]["*"][1]`, Kind: EditChanged, Old: 0.14, New: 0.16},
+		{Path: "if[2]", Kind: EditChanged, Old: 0.0, New: after["if"].([]interface{})[2]},
+		{Path: "if[3]", Kind: EditAdded, New: "extra"},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("esperadas %d alterações, obtidas %+v", len(want), edits)
+	}
+	for i := range want {
+		if edits[i].Path != want[i].Path || edits[i].Kind != want[i].Kind {
+			t.Errorf("alteração %d: esperado %+v, obtido %+v", i, want[i], edits[i])
+		}
+	}
+}
+
+func TestDiffPacks_GuardMessage(t *testing.T) {
+	guard := RuleConfig{ID: "G", Phase: "guards", Logic: map[string]interface{}{">": []interface{}{1.0, 2.0}}, ErrorMessage: "antigo"}
+	changed := guard
+	changed.ErrorMessage = "novo"
+
+	diff := DiffPacks(&RulePack{Version: "a", Rules: []RuleConfig{guard}}, &RulePack{Version: "b", Rules: []RuleConfig{changed}})
+	if len(diff.GuardMessages) != 1 || diff.GuardMessages[0].To != "novo" || len(diff.LogicChanges) != 0 {
+		t.Fatalf("diff inesperado: %+v", diff)
+	}
+}
+
+func TestCompareOrders_Impact(t *testing.T) {
+	loader := dirLoader{dir: filepath.Join("..", "..", "data", "rules")}
+	svc := NewEngineService(loader, NewJsonLogicExecutor())
+	orders := []Order{
+		{ID: "A", Currency: "AOA", Items: []OrderItem{{SKU: "X", Value: 1000, Qty: 1}}},
+		{ID: "B", Currency: "AOA", Items: []OrderItem{{SKU: "X", Value: 500, Qty: 2}}},
+	}
+
+	impacts, summary := CompareOrders(context.Background(), svc, "v1.2", "v1.2", orders)
+	if len(impacts) != 2 || len(summary) != 1 || summary[0].Changed != 0 || summary[0].Delta != 0 {
+		t.Fatalf("mesma versão não deve ter impacto: %+v %+v", impacts, summary)
+	}
+	if summary[0].Before != impacts[0].Before+impacts[1].Before {
+		t.Fatalf("resumo por moeda inconsistente: %+v", summary)
+	}
+}
@@ -4,37 +4,62 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"math"
 	"strings"
 
 	"github.com/diegoholiveira/jsonlogic/v3"
 )
 
+var RoundOperator = OperatorSpec{
+	Name:     "round",
+	MinArgs:  1,
+	MaxArgs:  2,
+	ArgTypes: []ArgType{ArgNumber, ArgNumber},
+	Returns:  ArgNumber,
+	Fn:       CustomRound,
+}
+
+var AllocateOperator = OperatorSpec{
+	Name:     "allocate",
+	MinArgs:  2,
+	MaxArgs:  2,
+	ArgTypes: []ArgType{ArgNumber, ArgNumber},
+	Returns:  ArgNumber,
+	Fn:       CustomAllocate,
+}
+
 type JsonLogicExecutor struct {
-	customOps map[string]func(args ...interface{}) interface{}
+	customOps map[string]OperatorSpec
 }
 
 func NewJsonLogicExecutor() *JsonLogicExecutor {
 	j := &JsonLogicExecutor{
-		customOps: make(map[string]func(args ...interface{}) interface{}),
+		customOps: make(map[string]OperatorSpec),
 	}
-	j.RegisterCustomOperator("round", CustomRound)
-	j.RegisterCustomOperator("allocate", CustomAllocate)
+	j.RegisterCustomOperator(RoundOperator)
+	j.RegisterCustomOperator(AllocateOperator)
 	return j
 }
 
-func (j *JsonLogicExecutor) RegisterCustomOperator(name string, logic func(args ...interface{}) interface{}) {
-	j.customOps[name] = logic
+func (j *JsonLogicExecutor) RegisterCustomOperator(spec OperatorSpec) {
+	j.customOps[spec.Name] = spec
+}
+
+// Operator devolve a especificação de um operador customizado registado.
+func (j *JsonLogicExecutor) Operator(name string) (OperatorSpec, bool) {
+	spec, ok := j.customOps[name]
+	return spec, ok
 }
 
 func (j *JsonLogicExecutor) Execute(ctx context.Context, ruleData map[string]interface{}, contextVars map[string]interface{}) (interface{}, error) {
 	if _, ok := ruleData["foreach"]; ok {
-		return j.handleForeach(ruleData["foreach"], contextVars), nil
+		return j.handleForeach(ctx, ruleData["foreach"], contextVars)
 	}
 
-	for opName, fn := range j.customOps {
+	for opName, spec := range j.customOps {
 		if args, ok := ruleData[opName]; ok {
-			return j.handleManualEval(args, contextVars, fn), nil
+			return j.handleManualEval(ctx, args, contextVars, spec)
 		}
 	}
 
@@ -60,10 +85,81 @@ func (j *JsonLogicExecutor) Execute(ctx context.Context, ruleData map[string]int
 	return j.finalizeValue(res), nil
 }
 
-func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]interface{}) interface{} {
+// ValidatePack valida as chamadas a operadores customizados de todas as regras do pack.
+// Os loaders chamam-no ao ler o pack (ver RulePackLoader), para que RunEngine não repita a
+// validação a cada pedido.
+func (j *JsonLogicExecutor) ValidatePack(pack *RulePack) error {
+	for _, rule := range pack.Rules {
+		if err := j.ValidateLogic(rule.Logic); err != nil {
+			return fmt.Errorf("rulepack %s, regra %s: %w", pack.Version, rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// ValidateLogic valida a aridade e os argumentos literais de cada operador customizado da
+// regra, e que só aparece onde Execute o avalia: na raiz, no corpo de foreach ou como
+// argumento de outro operador customizado. Dentro de um operador JsonLogic padrão falharia
+// só na execução, porque a biblioteca não o conhece.
+func (j *JsonLogicExecutor) ValidateLogic(ruleData map[string]interface{}) error {
+	return j.validateNode(ruleData, true)
+}
+
+func (j *JsonLogicExecutor) validateNode(node interface{}, customAllowed bool) error {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, item := range n {
+			if err := j.validateNode(item, customAllowed); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for opName, args := range n {
+			spec, custom := j.customOps[opName]
+			if (custom || opName == "foreach") && !customAllowed {
+				err := fmt.Errorf("%w: não pode ser usado dentro de um operador JsonLogic padrão", ErrInvalidOperatorCall)
+				return &OperatorError{Operator: opName, Err: err}
+			}
+			switch {
+			case opName == "foreach":
+				list := asArgList(args)
+				for i, arg := range list {
+					// O corpo (2º argumento) é avaliado por Execute para cada item
+					if err := j.validateNode(arg, i == 1); err != nil {
+						return err
+					}
+				}
+			case custom:
+				list := asArgList(args)
+				if err := spec.CheckArity(len(list)); err != nil {
+					return &OperatorError{Operator: opName, Err: err}
+				}
+				for i, arg := range list {
+					if _, isRule := arg.(map[string]interface{}); isRule {
+						if err := j.validateNode(arg, true); err != nil {
+							return err
+						}
+						continue
+					}
+					if _, ok := coerceArg(spec.TypeOf(i), arg); !ok {
+						err := fmt.Errorf("%w: argumento %d deve ser %s, recebeu %v", ErrInvalidOperatorCall, i, spec.TypeOf(i), arg)
+						return &OperatorError{Operator: opName, Err: err}
+					}
+				}
+			default:
+				if err := j.validateNode(args, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (j *JsonLogicExecutor) handleForeach(ctx context.Context, args interface{}, data map[string]interface{}) (interface{}, error) {
 	params, ok := args.([]interface{})
 	if !ok || len(params) < 2 {
-		return 0.0
+		return 0.0, nil
 	}
 
 	collection := j.resolveVar(params[0], data)
@@ -73,7 +169,7 @@ func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]inte
 
 	logic, ok := params[1].(map[string]interface{})
 	if !ok {
-		return 0.0
+		return 0.0, nil
 	}
 
 	var total float64
@@ -82,29 +178,49 @@ func (j *JsonLogicExecutor) handleForeach(args interface{}, data map[string]inte
 			"item":  item,
 			"order": data["order"],
 		}
-		res, _ := j.Execute(context.Background(), logic, itemCtx)
+		res, err := j.Execute(ctx, logic, itemCtx)
+		if err != nil {
+			return nil, err
+		}
 		if f, ok := anyToFloat(res); ok {
 			total += f
 		}
 	}
-	return total
+	return total, nil
 }
 
-func (j *JsonLogicExecutor) handleManualEval(args interface{}, data map[string]interface{}, fn func(args ...interface{}) interface{}) interface{} {
-	var params []interface{}
-	if list, ok := args.([]interface{}); ok {
-		for _, item := range list {
-			if subRule, isRule := item.(map[string]interface{}); isRule {
-				res, _ := j.Execute(context.Background(), subRule, data)
-				params = append(params, res)
-			} else {
-				params = append(params, j.resolveVar(item, data))
+func (j *JsonLogicExecutor) handleManualEval(ctx context.Context, args interface{}, data map[string]interface{}, spec OperatorSpec) (interface{}, error) {
+	list := asArgList(args)
+	if err := spec.CheckArity(len(list)); err != nil {
+		return nil, &OperatorError{Operator: spec.Name, Err: err}
+	}
+
+	params := make(OperatorArgs, 0, len(list))
+	for i, item := range list {
+		var val interface{}
+		if subRule, isRule := item.(map[string]interface{}); isRule {
+			res, err := j.Execute(ctx, subRule, data)
+			if err != nil {
+				return nil, err
 			}
+			val = res
+		} else {
+			val = item
 		}
-	} else {
-		params = append(params, j.resolveVar(args, data))
+
+		typed, ok := coerceArg(spec.TypeOf(i), val)
+		if !ok {
+			err := fmt.Errorf("%w: argumento %d deve ser %s, recebeu %v", ErrInvalidOperatorCall, i, spec.TypeOf(i), val)
+			return nil, &OperatorError{Operator: spec.Name, Err: err}
+		}
+		params = append(params, typed)
+	}
+
+	out, err := spec.Fn(ctx, params)
+	if err != nil {
+		return nil, &OperatorError{Operator: spec.Name, Err: err}
 	}
-	return fn(params...)
+	return out, nil
 }
 
 func (j *JsonLogicExecutor) resolveVar(arg interface{}, data map[string]interface{}) interface{} {
@@ -139,31 +255,41 @@ func (j *JsonLogicExecutor) finalizeValue(val interface{}) interface{} {
 	return val
 }
 
-func CustomRound(args ...interface{}) interface{} {
-	if len(args) == 0 {
-		return 0.0
-	}
-	val, _ := anyToFloat(args[0])
-	precision := 0
-	if len(args) > 1 {
-		if p, ok := anyToFloat(args[1]); ok {
-			precision = int(p)
-		}
+func CustomRound(ctx context.Context, args OperatorArgs) (interface{}, error) {
+	ratio := math.Pow(10, float64(int(args.Number(1))))
+	return math.Round(args.Number(0)*ratio) / ratio, nil
+}
+
+func CustomAllocate(ctx context.Context, args OperatorArgs) (interface{}, error) {
+	parts := args.Number(1)
+	if parts == 0 {
+		return nil, fmt.Errorf("divisão por zero")
 	}
-	ratio := math.Pow(10, float64(precision))
-	return math.Round(val*ratio) / ratio
+	return args.Number(0) / parts, nil
 }
 
-func CustomAllocate(args ...interface{}) interface{} {
-	if len(args) < 2 {
-		return 0.0
+func asArgList(args interface{}) []interface{} {
+	if list, ok := args.([]interface{}); ok {
+		return list
 	}
-	val, _ := anyToFloat(args[0])
-	parts, _ := anyToFloat(args[1])
-	if parts == 0 {
-		return 0.0
+	return []interface{}{args}
+}
+
+func coerceArg(t ArgType, v interface{}) (interface{}, bool) {
+	switch t {
+	case ArgNumber:
+		return anyToFloat(v)
+	case ArgString:
+		s, ok := v.(string)
+		return s, ok
+	case ArgBool:
+		b, ok := v.(bool)
+		return b, ok
+	case ArgList:
+		l, ok := v.([]interface{})
+		return l, ok
 	}
-	return val / parts
+	return v, true
 }
 
 func anyToFloat(i interface{}) (float64, bool) {
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExecutor_ValidateLogic(t *testing.T) {
+	executor := NewJsonLogicExecutor()
+
+	valid := map[string]interface{}{
+		"round": []interface{}{map[string]interface{}{"foreach": []interface{}{
+			map[string]interface{}{"var": "order.items"},
+			map[string]interface{}{"allocate": []interface{}{map[string]interface{}{"var": "item.value"}, 2.0}},
+		}}, 2.0},
+	}
+	if err := executor.ValidateLogic(valid); err != nil {
+		t.Fatalf("regra válida rejeitada: %v", err)
+	}
+
+	for name, logic := range map[string]map[string]interface{}{
+		"tipo literal": {"round": []interface{}{"dois", 2.0}},
+		"aridade":      {"round": []interface{}{1.0, 2.0, 3.0}},
+		// Passaria a validação e falharia só na execução: o JsonLogic padrão não conhece round
+		"aninhado": {"if": []interface{}{true, map[string]interface{}{"round": []interface{}{1.234, 2.0}}}},
+	} {
+		if err := executor.ValidateLogic(logic); !errors.Is(err, ErrInvalidOperatorCall) {
+			t.Errorf("%s: esperado ErrInvalidOperatorCall, recebido %v", name, err)
+		}
+	}
+
+	// A regra aninhada falha de facto na execução, confirmando que tem de ser recusada antes
+	nested := map[string]interface{}{"if": []interface{}{true, map[string]interface{}{"round": []interface{}{1.234, 2.0}}}}
+	if _, err := executor.Execute(context.Background(), nested, map[string]interface{}{}); err == nil {
+		t.Fatal("esperado erro na execução de round aninhado")
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// floatTolerance absorve ruído de vírgula flutuante na comparação de valores monetários
+const floatTolerance = 1e-6
+
+// TestOutcome é o resultado de um caso de teste embutido num RulePack.
+type TestOutcome struct {
+	Name  string   `json:"name"`
+	Pass  bool     `json:"pass"`
+	Diffs []string `json:"diffs,omitempty"`
+	Err   error    `json:"-"`
+}
+
+// RunPackTests executa cada caso de teste do pack através do motor e compara o
+// StateFragment e as guardas disparadas com os valores esperados.
+func RunPackTests(ctx context.Context, svc *EngineService, pack *RulePack) []TestOutcome {
+	outcomes := make([]TestOutcome, 0, len(pack.Tests))
+	for _, tc := range pack.Tests {
+		outcome := TestOutcome{Name: tc.Name}
+
+		res, err := svc.RunEngine(ctx, tc.Order, pack.Version)
+		if err != nil {
+			outcome.Err = err
+			outcomes = append(outcomes, outcome)
+			continue
+		}
+
+		outcome.Diffs = append(outcome.Diffs, diffValues("stateFragment", tc.ExpectedState, res.StateFragment)...)
+
+		hit := make([]string, 0, len(res.GuardsHit))
+		for _, g := range res.GuardsHit {
+			hit = append(hit, g.RuleID)
+		}
+		expectedGuards := append([]string{}, tc.ExpectedGuards...)
+		sort.Strings(hit)
+		sort.Strings(expectedGuards)
+		if !reflect.DeepEqual(hit, expectedGuards) {
+			outcome.Diffs = append(outcome.Diffs, fmt.Sprintf("guardsHit: esperado %v, obtido %v", expectedGuards, hit))
+		}
+
+		outcome.Pass = len(outcome.Diffs) == 0
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// diffValues compara apenas as chaves presentes no valor esperado; mapas são comparados recursivamente.
+func diffValues(path string, expected, actual interface{}) []string {
+	if expMap, ok := expected.(map[string]interface{}); ok {
+		actMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: esperado objeto, obtido %v", path, actual)}
+		}
+		keys := make([]string, 0, len(expMap))
+		for k := range expMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var diffs []string
+		for _, k := range keys {
+			actVal, present := actMap[k]
+			if !present {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: esperado %v, ausente", path, k, expMap[k]))
+				continue
+			}
+			diffs = append(diffs, diffValues(path+"."+k, expMap[k], actVal)...)
+		}
+		return diffs
+	}
+
+	expNum, expIsNum := anyToFloat(expected)
+	actNum, actIsNum := anyToFloat(actual)
+	if expIsNum && actIsNum {
+		if math.Abs(expNum-actNum) > floatTolerance {
+			return []string{fmt.Sprintf("%s: esperado %v, obtido %v (Δ %+.6g)", path, expNum, actNum, actNum-expNum)}
+		}
+		return nil
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return []string{fmt.Sprintf("%s: esperado %v, obtido %v", path, expected, actual)}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type dirLoader struct {
+	dir string
+}
+
+func (l dirLoader) Load(ctx context.Context, version string) (*RulePack, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, fmt.Sprintf("%s_rules.json", version)))
+	if err != nil {
+		return nil, err
+	}
+	var pack RulePack
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return nil, err
+	}
+	return &pack, CompileExpressions(&pack)
+}
+
+// Os packs publicados trazem exemplos com valores esperados; qualquer alteração de preço tem de os atualizar.
+func TestShippedPacks_EmbeddedCases(t *testing.T) {
+	loader := dirLoader{dir: filepath.Join("..", "..", "data", "rules")}
+	svc := NewEngineService(loader, NewJsonLogicExecutor())
+
+	for _, version := range []string{"v1.1", "v1.2", "v1.3"} {
+		pack, err := loader.Load(context.Background(), version)
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		if len(pack.Tests) == 0 {
+			t.Errorf("%s: pack sem casos de teste", version)
+		}
+		for _, outcome := range RunPackTests(context.Background(), svc, pack) {
+			if outcome.Err != nil {
+				t.Errorf("%s / %s: %v", version, outcome.Name, outcome.Err)
+			}
+			for _, d := range outcome.Diffs {
+				t.Errorf("%s / %s: %s", version, outcome.Name, d)
+			}
+		}
+	}
+}
@@ -2,13 +2,29 @@ package engine
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
 
-	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/Victor-armando18/service-commercial/pkg/jsondiff"
 )
 
+type deltaToleranceKey struct{}
+
+// WithDeltaTolerance define, para o pedido, a diferença numérica abaixo da qual uma
+// alteração do servidor é marcada como cosmética (ex: 0.01 para arredondamentos ao cêntimo).
+func WithDeltaTolerance(ctx context.Context, tolerance float64) context.Context {
+	return context.WithValue(ctx, deltaToleranceKey{}, tolerance)
+}
+
+// DeltaTolerance devolve a tolerância definida com WithDeltaTolerance (0 se nenhuma).
+func DeltaTolerance(ctx context.Context) float64 {
+	tolerance, _ := ctx.Value(deltaToleranceKey{}).(float64)
+	return tolerance
+}
+
 type EngineService struct {
 	loader   RulePackLoader
 	executor *JsonLogicExecutor
@@ -28,13 +44,18 @@ func (e *EngineService) RunEngine(ctx context.Context, initialOrder Order, versi
 		return nil, err
 	}
 
+	// Os valores calculados partem sempre do zero: um total, base ou imposto enviado pelo
+	// cliente nunca entra no cálculo (igual ao motor do servidor)
 	workingOrder := initialOrder
 	workingOrder.RulesVersion = version
+	workingOrder.BaseValue, workingOrder.TotalValue, workingOrder.TotalItems = 0, 0, 0
+	workingOrder.AppliedTaxes = nil
 	e.hydrateData(&workingOrder)
 
 	initialJSON, _ := json.Marshal(initialOrder)
 	executionLog := []ExecutionStep{}
 	guardsHit := []GuardViolation{}
+	guards := []GuardResult{}
 
 	phases := []string{"baseline", "orderAdjust", "allocation", "taxes", "totals", "guards"}
 
@@ -42,12 +63,17 @@ func (e *EngineService) RunEngine(ctx context.Context, initialOrder Order, versi
 		rules := e.getRules(rulePack.Rules, phase)
 		for _, rule := range rules {
 			out, err := e.executor.Execute(ctx, rule.Logic, map[string]interface{}{"order": workingOrder})
-			if err != nil || out == nil {
+			if err != nil {
+				return nil, fmt.Errorf("%w: regra %s (fase %s): %w", ErrRuleExecutionFailed, rule.ID, phase, err)
+			}
+			if out == nil {
 				continue
 			}
 
 			if phase == "guards" {
-				if v, ok := out.(bool); ok && v {
+				v, ok := out.(bool)
+				guards = append(guards, GuardResult{RuleID: rule.ID, Passed: !(ok && v)})
+				if ok && v {
 					guardsHit = append(guardsHit, GuardViolation{
 						RuleID:  rule.ID,
 						Reason:  "Violation Detected",
@@ -74,17 +100,27 @@ func (e *EngineService) RunEngine(ctx context.Context, initialOrder Order, versi
 	workingOrder.TotalValue = workingOrder.BaseValue + taxTotal
 
 	finalJSON, _ := json.Marshal(workingOrder)
-	patch, _ := jsonpatch.CreateMergePatch(initialJSON, finalJSON)
+	changes, _ := jsondiff.Diff(initialJSON, finalJSON, DeltaTolerance(ctx))
 
 	var stateFragment map[string]interface{}
 	json.Unmarshal(finalJSON, &stateFragment)
 
+	var warnings []string
+	if initialOrder.RulesHash != "" && initialOrder.RulesHash != rulePack.ContentHash {
+		warnings = append(warnings, fmt.Sprintf("o conteúdo do rulepack %s mudou: pedido calculado com %s, pack atual %s", version, initialOrder.RulesHash, rulePack.ContentHash))
+	}
+
 	return &EngineResult{
 		StateFragment: stateFragment,
-		ServerDelta:   len(patch) > 2,
+		ServerDelta:   jsondiff.HasChanges(changes),
+		Changes:       changes,
 		RulesVersion:  version,
+		RulesHash:     rulePack.ContentHash,
+		Revision:      stateRevision(finalJSON, rulePack.ContentHash),
 		ExecutionLog:  executionLog,
 		GuardsHit:     guardsHit,
+		Guards:        guards,
+		Warnings:      warnings,
 	}, nil
 }
 
@@ -96,9 +132,8 @@ func (e *EngineService) hydrateData(order *Order) {
 		v += i.Value * float64(i.Qty)
 	}
 	order.TotalItems = q
-	if order.BaseValue == 0 {
-		order.BaseValue = v
-	}
+	// Valor inicial da base; as regras de desconto substituem-no
+	order.BaseValue = v
 }
 
 func (e *EngineService) getRules(rules []RuleConfig, phase string) []RuleConfig {
@@ -130,3 +165,10 @@ func (e *EngineService) applyUpdate(key string, val interface{}, order *Order) {
 		order.TotalValue = f
 	}
 }
+
+// stateRevision identifica o estado calculado: muda se o pedido resultante ou o conteúdo
+// das regras mudar, e é igual para o mesmo cálculo em qualquer servidor.
+func stateRevision(state []byte, rulesHash string) string {
+	sum := sha256.Sum256(append(append(state, '\n'), rulesHash...))
+	return hex.EncodeToString(sum[:16])
+}
@@ -1,6 +1,16 @@
 package engine
 
-import "context"
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Victor-armando18/service-commercial/pkg/expr"
+	"github.com/Victor-armando18/service-commercial/pkg/jsondiff"
+)
 
 type OrderItem struct {
 	SKU   string  `json:"sku"`
@@ -13,6 +23,7 @@ type Order struct {
 	Currency           string             `json:"currency"`
 	CorrelationId      string             `json:"correlationId"`
 	RulesVersion       string             `json:"rulesVersion"`
+	RulesHash          string             `json:"rulesHash,omitempty"`
 	Items              []OrderItem        `json:"items"`
 	BaseValue          float64            `json:"baseValue"`
 	TotalValue         float64            `json:"totalValue"`
@@ -25,16 +36,32 @@ type RuleConfig struct {
 	ID           string                 `json:"id"`
 	Phase        string                 `json:"phase"`
 	Logic        map[string]interface{} `json:"logic"`
+	Expr         string                 `json:"expr,omitempty"`
 	OutputKey    string                 `json:"output_key"`
 	ErrorMessage string                 `json:"error_message"`
 }
 
 type RulePack struct {
-	Version     string       `json:"version"`
-	Description string       `json:"description"`
-	Rules       []RuleConfig `json:"rules"`
+	Schema      string         `json:"$schema,omitempty"`
+	Version     string         `json:"version"`
+	Description string         `json:"description"`
+	Rules       []RuleConfig   `json:"rules"`
+	Tests       []RuleTestCase `json:"tests,omitempty"`
+	ContentHash string         `json:"-"` // Preenchido pelo loader com ContentHash
+}
+
+// RuleTestCase é um exemplo embutido no pack: um pedido e o resultado que o motor deve produzir.
+// ExpectedState é comparado parcialmente (só as chaves indicadas); ExpectedGuards tem de coincidir exatamente.
+type RuleTestCase struct {
+	Name           string                 `json:"name"`
+	Order          Order                  `json:"order"`
+	ExpectedState  map[string]interface{} `json:"expected_state"`
+	ExpectedGuards []string               `json:"expected_guards,omitempty"`
 }
 
+// FieldChange é uma operação RFC 6902 com o valor do cliente e a marca cosmetic.
+type FieldChange = jsondiff.Operation
+
 type ExecutionStep struct {
 	Phase   string `json:"phase"`
 	RuleID  string `json:"ruleId"`
@@ -48,14 +75,64 @@ type GuardViolation struct {
 	Context string `json:"context"`
 }
 
+// GuardResult regista o resultado de uma guarda avaliada (Passed = não bloqueou).
+type GuardResult struct {
+	RuleID string `json:"ruleId"`
+	Passed bool   `json:"passed"`
+}
+
 type EngineResult struct {
 	StateFragment map[string]interface{} `json:"stateFragment"`
 	ServerDelta   bool                   `json:"serverDelta"`
+	Changes       []FieldChange          `json:"changes"`
 	RulesVersion  string                 `json:"rulesVersion"`
+	RulesHash     string                 `json:"rulesHash"`
+	Revision      string                 `json:"revision"`
 	ExecutionLog  []ExecutionStep        `json:"executionLog"`
 	GuardsHit     []GuardViolation       `json:"guardsHit"`
+	Guards        []GuardResult          `json:"guards"` // Todas as guardas avaliadas, incluindo as que passaram
+	Warnings      []string               `json:"warnings,omitempty"`
 }
 
+// RulePackLoader devolve os packs já validados: o loader chama JsonLogicExecutor.ValidatePack
+// ao ler cada pack, e RunEngine executa as regras sem as voltar a validar.
 type RulePackLoader interface {
 	Load(ctx context.Context, version string) (*RulePack, error)
 }
+
+// ContentHash calcula o hash canónico de um pack (SHA-256 do JSON com chaves ordenadas
+// e sem espaços). Loaders externos devem guardá-lo em RulePack.ContentHash.
+func ContentHash(raw []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// CompileExpressions converte o campo Expr de cada regra em Logic. Loaders externos
+// devem chamá-la depois de ler o pack.
+func CompileExpressions(pack *RulePack) error {
+	for i := range pack.Rules {
+		rule := &pack.Rules[i]
+		if rule.Expr == "" {
+			continue
+		}
+		if rule.Logic != nil {
+			return fmt.Errorf("regra %s: logic e expr não podem ser usados em simultâneo", rule.ID)
+		}
+		logic, err := expr.Compile(rule.Expr)
+		if err != nil {
+			return fmt.Errorf("regra %s: expr inválida: %w", rule.ID, err)
+		}
+		rule.Logic = logic
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	cases := map[string]string{
+		`round(order.baseValue * (1 - order.discountPercentage), 2)`:                `{"round":[{"*":[{"var":"order.baseValue"},{"-":[1,{"var":"order.discountPercentage"}]}]},2]}`,
+		`order.currency == "AOA" ? order.baseValue * 0.14 : order.baseValue * 0.20`: `{"if":[{"==":[{"var":"order.currency"},"AOA"]},{"*":[{"var":"order.baseValue"},0.14]},{"*":[{"var":"order.baseValue"},0.2]}]}`,
+		`foreach(order.items, item.value * item.qty)`:                               `{"foreach":[{"var":"order.items"},{"*":[{"var":"item.value"},{"var":"item.qty"}]}]}`,
+		`a + b + c - d`:                      `{"-":[{"+":[{"var":"a"},{"var":"b"},{"var":"c"}]},{"var":"d"}]}`,
+		`(a + b) + c`:                        `{"+":[{"+":[{"var":"a"},{"var":"b"}]},{"var":"c"}]}`,
+		`a > 1 and not b || c in ["x", 'y']`: `{"or":[{"and":[{">":[{"var":"a"},1]},{"!":[{"var":"b"}]}]},{"in":[{"var":"c"},["x","y"]]}]}`,
+		`-order.items.0.qty * -2`:            `{"*":[{"-":[{"var":"order.items.0.qty"}]},-2]}`,
+	}
+
+	for src, want := range cases {
+		got, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		var expected map[string]interface{}
+		json.Unmarshal([]byte(want), &expected)
+		if !reflect.DeepEqual(got, expected) {
+			gotJSON, _ := json.Marshal(got)
+			t.Errorf("%s:\n  recebido %s\n  esperado %s", src, gotJSON, want)
+		}
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	raw, err := os.ReadFile("../../data/rules/v1.2_rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pack struct {
+		Rules []struct {
+			ID    string                 `json:"id"`
+			Logic map[string]interface{} `json:"logic"`
+		} `json:"rules"`
+	}
+	json.Unmarshal(raw, &pack)
+
+	extra := []string{
+		`{"-":[{"-":[1,2]},{"-":[3,-4]}]}`,
+		`{"+":[{"+":[1,2]},3]}`,
+		`{"!":[{"==":[{"var":"a"},{"if":[true,1,2]}]}]}`,
+		`{"if":[{"if":[{"var":"a"},true,false]},{"var":["b",0]},"x\"y"]}`,
+		// Caracteres de controlo e barras: Format usa os escapes do Go, que o lexer descodifica
+		`{"==":[{"var":"order.note"},"a\nb\tc\r\u0000\u001b\\d\u2028"]}`,
+		`{"in":["'\\n'",["\\","\""]]}`,
+	}
+	for _, e := range extra {
+		var logic map[string]interface{}
+		json.Unmarshal([]byte(e), &logic)
+		pack.Rules = append(pack.Rules, struct {
+			ID    string                 `json:"id"`
+			Logic map[string]interface{} `json:"logic"`
+		}{ID: e, Logic: logic})
+	}
+
+	for _, rule := range pack.Rules {
+		text, err := Format(rule.Logic)
+		if err != nil {
+			t.Errorf("%s: %v", rule.ID, err)
+			continue
+		}
+		back, err := Compile(text)
+		if err != nil {
+			t.Errorf("%s: %q não compila: %v", rule.ID, text, err)
+			continue
+		}
+		if !reflect.DeepEqual(back, rule.Logic) {
+			t.Errorf("%s: round-trip diverge via %q", rule.ID, text)
+		}
+	}
+}
+
+func TestCompile_SyntaxErrors(t *testing.T) {
+	cases := []struct {
+		src       string
+		line, col int
+	}{
+		{`round(order.baseValue, 2`, 1, 25},
+		{"order.baseValue *\n  (1 - )", 2, 8},
+		{`a ? b`, 1, 6},
+		{`"sem fim`, 1, 1},
+		{`a @ b`, 1, 3},
+	}
+
+	for _, c := range cases {
+		_, err := Compile(c.src)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: esperado SyntaxError, recebido %v", c.src, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || syntaxErr.Column != c.col {
+			t.Errorf("%q: posição %d:%d, esperado %d:%d (%v)", c.src, syntaxErr.Line, syntaxErr.Column, c.line, c.col, err)
+		}
+	}
+}
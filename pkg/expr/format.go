@@ -0,0 +1,180 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const precPrimary = 7
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	pathPattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)*$`)
+)
+
+// Format converte uma árvore JsonLogic para a sintaxe de expressões, de forma a que
+// Compile(Format(logic)) produza a mesma árvore. Falha para nós sem representação
+// textual, como objetos literais com várias chaves.
+func Format(logic interface{}) (string, error) {
+	var sb strings.Builder
+	if err := format(&sb, logic); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func format(sb *strings.Builder, node interface{}) error {
+	switch n := node.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(n))
+	case float64:
+		sb.WriteString(strconv.FormatFloat(n, 'f', -1, 64))
+	case int:
+		sb.WriteString(strconv.Itoa(n))
+	case string:
+		sb.WriteString(strconv.Quote(n))
+	case []interface{}:
+		sb.WriteString("[")
+		if err := formatList(sb, n); err != nil {
+			return err
+		}
+		sb.WriteString("]")
+	case map[string]interface{}:
+		return formatOperation(sb, n)
+	default:
+		return fmt.Errorf("valor %v (%T) não tem representação textual", node, node)
+	}
+	return nil
+}
+
+func formatOperation(sb *strings.Builder, node map[string]interface{}) error {
+	if len(node) != 1 {
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("objeto com várias chaves %v não tem representação textual", keys)
+	}
+
+	for op, rawArgs := range node {
+		args, isList := rawArgs.([]interface{})
+		if !isList {
+			args = []interface{}{rawArgs}
+		}
+
+		if path, ok := rawArgs.(string); ok && op == "var" && pathPattern.MatchString(path) {
+			sb.WriteString(path)
+			return nil
+		}
+
+		prec, isBinary := binaryPrecedence[op]
+		switch {
+		case isBinary && len(args) == 2, isBinary && variadicOperators[op] && len(args) > 2:
+			for i, arg := range args {
+				if i > 0 {
+					sb.WriteString(" " + op + " ")
+				}
+				// O primeiro operando só precisa de parênteses se ligar mais fraco ou se,
+				// sendo o mesmo operador variádico, fosse fundido na releitura.
+				child, childOp := precedenceOf(arg)
+				needsParens := child <= prec
+				if i == 0 {
+					needsParens = child < prec || (childOp == op && variadicOperators[op])
+				}
+				if err := formatOperand(sb, arg, needsParens); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		case (op == "-" || op == "!") && len(args) == 1:
+			sb.WriteString(op)
+			child, _ := precedenceOf(args[0])
+			// -(-1) não pode ser escrito --1, que seria lido como dois operadores
+			needsParens := child < precPrimary
+			if n, ok := args[0].(float64); ok && n < 0 {
+				needsParens = true
+			}
+			return formatOperand(sb, args[0], needsParens)
+
+		case op == "if" && len(args) == 3:
+			cond, _ := precedenceOf(args[0])
+			if err := formatOperand(sb, args[0], cond == precTernary); err != nil {
+				return err
+			}
+			sb.WriteString(" ? ")
+			if err := format(sb, args[1]); err != nil {
+				return err
+			}
+			sb.WriteString(" : ")
+			return format(sb, args[2])
+		}
+
+		if !identifierPattern.MatchString(op) {
+			return fmt.Errorf("operador %q não tem representação textual com %d argumentos", op, len(args))
+		}
+		sb.WriteString(op + "(")
+		if err := formatList(sb, args); err != nil {
+			return err
+		}
+		sb.WriteString(")")
+	}
+	return nil
+}
+
+func formatOperand(sb *strings.Builder, node interface{}, parens bool) error {
+	if !parens {
+		return format(sb, node)
+	}
+	sb.WriteString("(")
+	if err := format(sb, node); err != nil {
+		return err
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+func formatList(sb *strings.Builder, items []interface{}) error {
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if err := format(sb, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// precedenceOf devolve a precedência com que o nó será impresso e o seu operador.
+func precedenceOf(node interface{}) (int, string) {
+	m, ok := node.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		if n, ok := node.(float64); ok && n < 0 {
+			return precUnary, ""
+		}
+		return precPrimary, ""
+	}
+	for op, rawArgs := range m {
+		args, isList := rawArgs.([]interface{})
+		if !isList {
+			args = []interface{}{rawArgs}
+		}
+		prec, isBinary := binaryPrecedence[op]
+		switch {
+		case isBinary && len(args) == 2, isBinary && variadicOperators[op] && len(args) > 2:
+			return prec, op
+		case (op == "-" || op == "!") && len(args) == 1:
+			return precUnary, op
+		case op == "if" && len(args) == 3:
+			return precTernary, op
+		}
+	}
+	return precPrimary, ""
+}
@@ -0,0 +1,181 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+// SyntaxError indica a posição (1-based) de um erro na expressão.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("linha %d, coluna %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Operadores de vários caracteres têm de aparecer antes dos seus prefixos
+var operatorTokens = []string{
+	"===", "!==", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ",", ".",
+}
+
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+}
+
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: []rune(src), line: 1, col: 1}
+	var tokens []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+offset]
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.pos]
+	l.pos++
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...interface{}) error {
+	return &SyntaxError{Line: line, Column: col, Message: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.src) {
+		switch r := l.peek(0); {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '#':
+			// Comentário até ao fim da linha
+			for l.pos < len(l.src) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpace()
+	line, col := l.line, l.col
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: line, col: col}, nil
+	}
+
+	r := l.peek(0)
+
+	switch {
+	case unicode.IsDigit(r):
+		var sb strings.Builder
+		for unicode.IsDigit(l.peek(0)) {
+			sb.WriteRune(l.advance())
+		}
+		if l.peek(0) == '.' && unicode.IsDigit(l.peek(1)) {
+			sb.WriteRune(l.advance())
+			for unicode.IsDigit(l.peek(0)) {
+				sb.WriteRune(l.advance())
+			}
+		}
+		return token{kind: tokNumber, text: sb.String(), line: line, col: col}, nil
+
+	case r == '"' || r == '\'':
+		quote := l.advance()
+		var sb strings.Builder
+		for {
+			if l.pos >= len(l.src) || l.peek(0) == '\n' {
+				return token{}, l.errorf(line, col, "string não terminada")
+			}
+			c := l.advance()
+			if c == quote {
+				break
+			}
+			if c == '\\' && l.pos < len(l.src) {
+				c = l.escape(quote)
+			}
+			sb.WriteRune(c)
+		}
+		return token{kind: tokString, text: sb.String(), line: line, col: col}, nil
+
+	case unicode.IsLetter(r) || r == '_':
+		var sb strings.Builder
+		for unicode.IsLetter(l.peek(0)) || unicode.IsDigit(l.peek(0)) || l.peek(0) == '_' {
+			sb.WriteRune(l.advance())
+		}
+		return token{kind: tokIdent, text: sb.String(), line: line, col: col}, nil
+	}
+
+	rest := string(l.src[l.pos:])
+	for _, op := range operatorTokens {
+		if strings.HasPrefix(rest, op) {
+			for range op {
+				l.advance()
+			}
+			return token{kind: tokOp, text: op, line: line, col: col}, nil
+		}
+	}
+	return token{}, l.errorf(line, col, "caractere inesperado %q", r)
+}
+
+// escape lê o que se segue a uma barra numa string. Os escapes do Go (\n, \t, \x00,
+// \u2028...), que Format emite com strconv.Quote, são descodificados; noutro caso o
+// caractere seguinte é lido tal como está (\" e \\ incluídos).
+func (l *lexer) escape(quote rune) rune {
+	rest := "\\" + string(l.src[l.pos:])
+	value, _, tail, err := strconv.UnquoteChar(rest, byte(quote))
+	if err != nil {
+		return l.advance()
+	}
+	// A barra já tinha sido consumida
+	for n := utf8.RuneCountInString(rest[:len(rest)-len(tail)]) - 1; n > 0; n-- {
+		l.advance()
+	}
+	return value
+}
@@ -0,0 +1,281 @@
+// Package expr compila uma sintaxe de fórmulas legível para a árvore JsonLogic
+// executada pelo motor, e formata essa árvore de volta para texto.
+//
+//	round(order.baseValue * (1 - order.discountPercentage), 2)
+//	order.currency == "AOA" ? order.baseValue * 0.14 : order.baseValue * 0.20
+//	foreach(order.items, item.value * item.qty)
+//
+// Caminhos (order.baseValue) compilam para {"var": ...}, chamadas name(args) para
+// {"name": [args]} e os operadores infixos para os operadores JsonLogic equivalentes.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Precedência dos operadores binários (maior liga mais forte)
+var binaryPrecedence = map[string]int{
+	"or": 1, "and": 2,
+	"==": 3, "!=": 3, "===": 3, "!==": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "in": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+// Sinónimos aceites na sintaxe para os operadores JsonLogic
+var operatorAliases = map[string]string{"&&": "and", "||": "or"}
+
+// Operadores associativos: a + b + c compila para {"+": [a, b, c]}
+var variadicOperators = map[string]bool{"+": true, "*": true, "and": true, "or": true}
+
+const (
+	precTernary = 0
+	precUnary   = 6
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Compile converte a expressão em lógica JsonLogic. Erros de sintaxe são devolvidos como *SyntaxError.
+func Compile(src string) (map[string]interface{}, error) {
+	node, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	logic, ok := node.(map[string]interface{})
+	if !ok {
+		// Um literal isolado não é uma regra; envolvemo-lo para que o executor o aceite
+		logic = map[string]interface{}{"if": []interface{}{true, node}}
+	}
+	return logic, nil
+}
+
+// Parse converte a expressão num nó JsonLogic, que pode ser um literal.
+func Parse(src string) (interface{}, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseExpr(precTernary)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok, "token inesperado %q", tok.text)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorAt(tok token, format string, args ...interface{}) error {
+	return &SyntaxError{Line: tok.line, Column: tok.col, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(text string) error {
+	tok := p.peek()
+	if tok.kind != tokOp || tok.text != text {
+		if tok.kind == tokEOF {
+			return p.errorAt(tok, "esperado %q, fim da expressão", text)
+		}
+		return p.errorAt(tok, "esperado %q, encontrado %q", text, tok.text)
+	}
+	p.advance()
+	return nil
+}
+
+// binaryOperator devolve o operador JsonLogic do token atual, se for binário.
+func (p *parser) binaryOperator() (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return "", false
+	}
+	op := tok.text
+	if alias, ok := operatorAliases[op]; ok {
+		op = alias
+	}
+	_, ok := binaryPrecedence[op]
+	return op, ok
+}
+
+// parseExpr implementa precedence climbing; minPrec delimita os operadores que podem continuar a expressão.
+func (p *parser) parseExpr(minPrec int) (interface{}, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	// lastOp é o operador do nó criado neste ciclo; só esse pode ser estendido,
+	// para que (a + b) + c entre parênteses não seja fundido.
+	lastOp := ""
+	for {
+		op, ok := p.binaryOperator()
+		if !ok || binaryPrecedence[op] < minPrec {
+			break
+		}
+		prec := binaryPrecedence[op]
+		p.advance()
+
+		right, err := p.parseExpr(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == lastOp && variadicOperators[op] {
+			node := left.(map[string]interface{})
+			node[op] = append(node[op].([]interface{}), right)
+			continue
+		}
+		left = map[string]interface{}{op: []interface{}{left, right}}
+		lastOp = op
+	}
+
+	if minPrec <= precTernary && p.peek().kind == tokOp && p.peek().text == "?" {
+		p.advance()
+		then, err := p.parseExpr(precTernary)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		otherwise, err := p.parseExpr(precTernary)
+		if err != nil {
+			return nil, err
+		}
+		left = map[string]interface{}{"if": []interface{}{left, then, otherwise}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (interface{}, error) {
+	tok := p.peek()
+	if (tok.kind == tokOp && (tok.text == "!" || tok.text == "-")) || (tok.kind == tokIdent && tok.text == "not") {
+		p.advance()
+		operand, err := p.parseExpr(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		if tok.text == "-" {
+			if n, ok := operand.(float64); ok {
+				return -n, nil
+			}
+			return map[string]interface{}{"-": []interface{}{operand}}, nil
+		}
+		return map[string]interface{}{"!": []interface{}{operand}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (interface{}, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokEOF:
+		return nil, p.errorAt(tok, "fim inesperado da expressão")
+
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorAt(tok, "número inválido %q", tok.text)
+		}
+		return n, nil
+
+	case tokString:
+		return tok.text, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if next := p.peek(); next.kind == tokOp && next.text == "(" {
+			return p.parseCall(tok)
+		}
+		return p.parsePath(tok)
+
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseExpr(precTernary)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return items, nil
+		}
+	}
+	return nil, p.errorAt(tok, "token inesperado %q", tok.text)
+}
+
+func (p *parser) parsePath(first token) (interface{}, error) {
+	parts := []string{first.text}
+	for p.peek().kind == tokOp && p.peek().text == "." {
+		p.advance()
+		tok := p.advance()
+		if tok.kind != tokIdent && tok.kind != tokNumber {
+			return nil, p.errorAt(tok, "esperado nome de campo após '.'")
+		}
+		parts = append(parts, tok.text)
+	}
+	return map[string]interface{}{"var": strings.Join(parts, ".")}, nil
+}
+
+func (p *parser) parseCall(name token) (interface{}, error) {
+	p.advance() // "("
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{name.text: args}, nil
+}
+
+func (p *parser) parseList(closing string) ([]interface{}, error) {
+	args := []interface{}{}
+	if tok := p.peek(); tok.kind == tokOp && tok.text == closing {
+		p.advance()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr(precTernary)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		tok := p.peek()
+		if tok.kind == tokOp && tok.text == "," {
+			p.advance()
+			continue
+		}
+		if err := p.expect(closing); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
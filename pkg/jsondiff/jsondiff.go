@@ -0,0 +1,145 @@
+// Package jsondiff gera o patch RFC 6902 que transforma um documento JSON noutro.
+// Cada operação leva também o valor original (clientValue), membro extra que os
+// aplicadores de RFC 6902 ignoram, e pode ser marcada como cosmética quando a
+// diferença numérica fica abaixo de uma tolerância.
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Operation é uma operação RFC 6902 com o valor que o documento original tinha.
+type Operation struct {
+	Op          string      `json:"op"`
+	Path        string      `json:"path"`
+	Value       interface{} `json:"value,omitempty"`
+	ClientValue interface{} `json:"clientValue,omitempty"`
+	Cosmetic    bool        `json:"cosmetic,omitempty"`
+}
+
+// Diff compara dois documentos JSON. Diferenças numéricas com valor absoluto menor ou
+// igual a tolerance (se > 0) são marcadas como cosméticas. Arrays de tamanho diferente
+// são substituídos por inteiro, para que o patch continue válido sem depender de índices.
+func Diff(original, modified []byte, tolerance float64) ([]Operation, error) {
+	var a, b interface{}
+	if err := json.Unmarshal(original, &a); err != nil {
+		return nil, fmt.Errorf("documento original: %w", err)
+	}
+	if err := json.Unmarshal(modified, &b); err != nil {
+		return nil, fmt.Errorf("documento modificado: %w", err)
+	}
+	ops := []Operation{}
+	diff("", a, b, tolerance, &ops)
+	return ops, nil
+}
+
+// MarshalJSON inclui sempre value em add/replace e clientValue em replace/remove,
+// mesmo quando são zero ou null: omiti-los tornaria a operação inválida ou ambígua.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	switch o.Op {
+	case OpAdd:
+		return json.Marshal(struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}{o.Op, o.Path, o.Value})
+	case OpRemove:
+		return json.Marshal(struct {
+			Op          string      `json:"op"`
+			Path        string      `json:"path"`
+			ClientValue interface{} `json:"clientValue"`
+		}{o.Op, o.Path, o.ClientValue})
+	default:
+		return json.Marshal(struct {
+			Op          string      `json:"op"`
+			Path        string      `json:"path"`
+			Value       interface{} `json:"value"`
+			ClientValue interface{} `json:"clientValue"`
+			Cosmetic    bool        `json:"cosmetic,omitempty"`
+		}{o.Op, o.Path, o.Value, o.ClientValue, o.Cosmetic})
+	}
+}
+
+// HasChanges indica se há alguma operação que não seja cosmética.
+func HasChanges(ops []Operation) bool {
+	for _, op := range ops {
+		if !op.Cosmetic {
+			return true
+		}
+	}
+	return false
+}
+
+// EscapePointer escapa um segmento de JSON Pointer (RFC 6901).
+func EscapePointer(segment string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1")
+}
+
+func diff(path string, a, b interface{}, tolerance float64, ops *[]Operation) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "/" + EscapePointer(k)
+			oldValue, inA := av[k]
+			newValue, inB := bv[k]
+			switch {
+			case !inA:
+				*ops = append(*ops, Operation{Op: OpAdd, Path: child, Value: newValue})
+			case !inB:
+				*ops = append(*ops, Operation{Op: OpRemove, Path: child, ClientValue: oldValue})
+			default:
+				diff(child, oldValue, newValue, tolerance, ops)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			break
+		}
+		for i := range av {
+			diff(fmt.Sprintf("%s/%d", path, i), av[i], bv[i], tolerance, ops)
+		}
+		return
+	case float64:
+		if bv, ok := b.(float64); ok && av != bv {
+			*ops = append(*ops, Operation{
+				Op:          OpReplace,
+				Path:        path,
+				Value:       bv,
+				ClientValue: av,
+				Cosmetic:    tolerance > 0 && math.Abs(av-bv) <= tolerance,
+			})
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, Operation{Op: OpReplace, Path: path, Value: b, ClientValue: a})
+	}
+}
@@ -0,0 +1,55 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+func TestDiff_PatchAppliesAndKeepsClientValues(t *testing.T) {
+	original := []byte(`{"baseValue": 200.03, "discount": 0.1, "appliedTaxes": null, "items": [{"qty": 1}], "a/b": 1, "gone": "x"}`)
+	modified := []byte(`{"baseValue": 200, "discount": 0, "appliedTaxes": {"VAT": 28}, "items": [{"qty": 2}], "a/b": 2, "new": false}`)
+
+	ops, err := Diff(original, modified, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// O patch serializado tem de ser aplicável por uma implementação RFC 6902 padrão
+	raw, _ := json.Marshal(ops)
+	patch, err := jsonpatch.DecodePatch(raw)
+	if err != nil {
+		t.Fatalf("patch inválido %s: %v", raw, err)
+	}
+	applied, err := patch.Apply(original)
+	if err != nil {
+		t.Fatalf("falha ao aplicar %s: %v", raw, err)
+	}
+	if !jsonpatch.Equal(applied, modified) {
+		t.Fatalf("patch não reproduz o documento: %s", applied)
+	}
+
+	byPath := make(map[string]Operation)
+	for _, op := range ops {
+		byPath[op.Path] = op
+	}
+	if op := byPath["/baseValue"]; !op.Cosmetic || op.ClientValue != 200.03 {
+		t.Errorf("baseValue deveria ser cosmético com o valor do cliente: %+v", op)
+	}
+	if op := byPath["/discount"]; op.Op != OpReplace || op.Value != 0.0 || op.Cosmetic {
+		t.Errorf("discount 0.1 → 0 excede a tolerância: %+v", op)
+	}
+	if op := byPath["/a~1b"]; op.Op != OpReplace {
+		t.Errorf("chave com / deve ser escapada no pointer: %+v", ops)
+	}
+	if op := byPath["/gone"]; op.Op != OpRemove || op.ClientValue != "x" {
+		t.Errorf("remoção sem valor do cliente: %+v", op)
+	}
+	if !HasChanges(ops) {
+		t.Error("há alterações não cosméticas")
+	}
+	if cosmetic, _ := Diff([]byte(`{"v": 1.004}`), []byte(`{"v": 1}`), 0.01); HasChanges(cosmetic) {
+		t.Errorf("só diferenças cosméticas: %+v", cosmetic)
+	}
+}
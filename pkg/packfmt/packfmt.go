@@ -0,0 +1,95 @@
+// Package packfmt normaliza os ficheiros de RulePack (JSON ou YAML) e descodifica-os
+// de forma estrita: um campo desconhecido, como "outputKey" em vez de "output_key",
+// é um erro e não um valor ignorado em silêncio.
+package packfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions lista as extensões aceites, pela ordem de preferência.
+var Extensions = []string{".json", ".yaml", ".yml"}
+
+// IsYAML indica se o nome do ficheiro corresponde a um pack em YAML.
+func IsYAML(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ToJSON devolve o conteúdo em JSON. Packs YAML (comentários incluídos) são convertidos
+// para a mesma representação que o JSON equivalente; packs JSON são devolvidos tal como estão.
+func ToJSON(name string, raw []byte) ([]byte, error) {
+	if !IsYAML(name) {
+		return raw, nil
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("YAML inválido: %w", err)
+	}
+	normalized, err := normalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalized)
+}
+
+// DecodeStrict descodifica um único documento JSON em v, rejeitando campos desconhecidos.
+func DecodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("conteúdo extra depois do documento")
+	}
+	return nil
+}
+
+// normalize garante que os mapas YAML têm chaves textuais, como exige o JSON.
+func normalize(node interface{}) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			child, err := normalize(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			n[k] = child
+		}
+		return n, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("chave %v não é texto", k)
+			}
+			child, err := normalize(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = child
+		}
+		return out, nil
+	case []interface{}:
+		for i, v := range n {
+			child, err := normalize(v)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			n[i] = child
+		}
+		return n, nil
+	default:
+		return n, nil
+	}
+}
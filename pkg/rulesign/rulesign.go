@@ -0,0 +1,141 @@
+// Package rulesign assina e verifica RulePacks com Ed25519. A assinatura é destacada:
+// fica num ficheiro "<pack>.sig" ao lado do pack e cobre os bytes exatos do ficheiro.
+package rulesign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	Algorithm       = "ed25519"
+	SignatureSuffix = ".sig"
+)
+
+var (
+	ErrUnsigned     = errors.New("rulepack sem assinatura")
+	ErrUnknownKey   = errors.New("assinatura feita com chave não confiável")
+	ErrBadSignature = errors.New("assinatura inválida: o pack foi alterado depois de assinado")
+)
+
+// Signature é o conteúdo de um ficheiro .sig.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	Value     string `json:"signature"` // base64
+}
+
+// KeyID identifica uma chave pública pelos primeiros 8 bytes do seu SHA-256.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// Sign produz a assinatura destacada, já serializada para gravar no ficheiro .sig.
+func Sign(priv ed25519.PrivateKey, content []byte) ([]byte, error) {
+	sig := Signature{
+		Algorithm: Algorithm,
+		KeyID:     KeyID(priv.Public().(ed25519.PublicKey)),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content)),
+	}
+	return json.MarshalIndent(sig, "", "  ")
+}
+
+// Keyring é o conjunto de chaves públicas confiáveis, indexado por KeyID.
+type Keyring map[string]ed25519.PublicKey
+
+func NewKeyring(keys ...ed25519.PublicKey) Keyring {
+	k := make(Keyring, len(keys))
+	for _, pub := range keys {
+		k[KeyID(pub)] = pub
+	}
+	return k
+}
+
+// Verify valida a assinatura destacada (conteúdo do ficheiro .sig) contra o conteúdo do pack.
+func (k Keyring) Verify(content, signature []byte) error {
+	if len(signature) == 0 {
+		return ErrUnsigned
+	}
+	var sig Signature
+	if err := json.Unmarshal(signature, &sig); err != nil {
+		return fmt.Errorf("ficheiro de assinatura inválido: %w", err)
+	}
+	if sig.Algorithm != Algorithm {
+		return fmt.Errorf("algoritmo de assinatura não suportado: %q", sig.Algorithm)
+	}
+	pub, ok := k[sig.KeyID]
+	if !ok {
+		return fmt.Errorf("%w (key_id %s)", ErrUnknownKey, sig.KeyID)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("assinatura mal codificada: %w", err)
+	}
+	if !ed25519.Verify(pub, content, raw) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// MarshalPublicKey serializa a chave em PEM (PKIX), compatível com openssl.
+func MarshalPublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// MarshalPrivateKey serializa a chave em PEM (PKCS#8).
+func MarshalPrivateKey(priv ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func ParsePublicKey(pemBytes []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("chave pública PEM não encontrada")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("chave pública não é %s", Algorithm)
+	}
+	return pub, nil
+}
+
+func ParsePrivateKey(pemBytes []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("chave privada PEM não encontrada")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("chave privada não é %s", Algorithm)
+	}
+	return priv, nil
+}
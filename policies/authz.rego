@@ -16,16 +16,27 @@ action_specs := {
     }
 }
 
+# Ações que o serviço pede à política (domain.Permission); uma permissão fora desta
+# lista não autoriza nada
+actions := {
+    "order.discount.apply", # aplicar desconto, até max_discount_pct
+    "sales.void",           # anular vendas e notas de crédito
+    "rules.manage",         # publicar RulePacks e ativá-los por tenant
+}
+
 # Regra principal de permissão (RBAC)
 allow if {
-    input.subject.permissions[_] == input.action
+    input.action in actions
+    input.action in input.subject.permissions
 }
 
 # Geração de Capabilities e Constraints para o Front-end
 capabilities := { action: info |
     some action, spec in action_specs
     info := {
-        "allowed": input.subject.permissions[_] == action,
+        # "in" e não permissions[_] == action: com várias permissões, a comparação daria
+        # true e false para a mesma chave e a avaliação falharia
+        "allowed": action in input.subject.permissions,
         "constraints": { spec.constraint_key: get_limit(action) }
     }
 }
@@ -0,0 +1,10 @@
+// Package policies expõe a política de autorização embutida no binário, usada quando a
+// configuração não indica outra.
+package policies
+
+import _ "embed"
+
+// Authz é o módulo Rego de policies/authz.rego.
+//
+//go:embed authz.rego
+var Authz string